@@ -0,0 +1,94 @@
+package analysis
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/diag"
+	"monkey/evaluator"
+)
+
+// BuiltinCallArity reports every call of a builtin with a number of
+// arguments the builtin doesn't take, like `len(a, b)`, which would fail
+// when it runs.
+//
+// A name that the program binds anywhere, by let, as a parameter or as a
+// loop variable, may not refer to the builtin, so calls of it are left alone.
+// So are calls of user functions in general, and calls with a spread
+// argument, `len(...a)`, whose number of arguments depends on the array.
+func BuiltinCallArity(p *ast.Program) []diag.Diagnostic {
+	bound := map[string]bool{}
+	ast.Walk(p, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.LetStatement:
+			for _, name := range boundNames(n) {
+				bound[name.Value] = true
+			}
+		case *ast.FunctionLiteral:
+			for _, param := range n.Parameters {
+				bound[param.Value] = true
+			}
+		case *ast.ForExpression:
+			bound[n.Variable.Value] = true
+		}
+		return true
+	})
+
+	diagnostics := []diag.Diagnostic{}
+	check := func(call *ast.CallExpression) {
+		name, ok := call.Function.(*ast.Identifier)
+		if !ok || bound[name.Value] || hasSpread(call.Arguments) {
+			return
+		}
+		min, max, ok := evaluator.BuiltinArity(name.Value)
+		if got := len(call.Arguments); ok && (got < min || max >= 0 && got > max) {
+			msg := fmt.Sprintf("%s expects %s, got %d", name.Value, argumentCount(min, max), got)
+			diagnostics = append(diagnostics, diag.Diagnostic{Pos: name.Pos(), Message: msg})
+		}
+	}
+	// the call after |> is checked with the argument the pipe passes it,
+	// when the pipe is reached, which is before the call.
+	piped := map[ast.Node]bool{}
+	ast.Walk(p, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.PipeExpression:
+			piped[ast.Unparen(n.Right)] = true
+			check(n.Piped())
+		case *ast.CallExpression:
+			if !piped[n] {
+				check(n)
+			}
+		}
+		return true
+	})
+	return diagnostics
+}
+
+func hasSpread(args []ast.Expression) bool {
+	for _, arg := range args {
+		if _, ok := arg.(*ast.SpreadElement); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// argumentCount describes the number of arguments from min to max.
+func argumentCount(min, max int) string {
+	switch {
+	case max < 0:
+		return fmt.Sprintf("at least %d %s", min, plural(min))
+	case min == max:
+		return fmt.Sprintf("%d %s", min, plural(min))
+	case max == min+1:
+		return fmt.Sprintf("%d or %d arguments", min, max)
+	default:
+		return fmt.Sprintf("%d to %d arguments", min, max)
+	}
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return "argument"
+	}
+	return "arguments"
+}
@@ -0,0 +1,49 @@
+package analysis
+
+import "testing"
+
+func TestBuiltinCallArity(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`let a = [1]; len(a, a)`, []string{"1:14: len expects 1 argument, got 2"}},
+		{`let a = [1]; len(a)`, []string{}},
+		{`len()`, []string{"1:1: len expects 1 argument, got 0"}},
+		{"let f = fn(x) {\n  slice(x)\n}", []string{"2:3: slice expects 2 or 3 arguments, got 1"}},
+		{`range(1, 2, 3, 4); pqNew(1)`, []string{
+			"1:1: range expects 1 to 3 arguments, got 4",
+			"1:20: pqNew expects 0 arguments, got 1",
+		}},
+		{`concat(); concat([1], [2], [3])`, []string{"1:1: concat expects at least 1 argument, got 0"}},
+		{`puts(); puts(1, 2, 3)`, []string{}},
+		{`puts(len(1, 2))`, []string{"1:6: len expects 1 argument, got 2"}},
+		// user functions, also when they hide a builtin
+		{`let add = fn(a, b) { a + b }; add(1)`, []string{}},
+		{`let len = fn(a, b) { a }; len(1, 2)`, []string{}},
+		{`let f = fn(first) { first(1, 2) }`, []string{}},
+		{`for (len in [fn(a, b) { a }]) { len(1, 2) }`, []string{}},
+		{`unknown(1, 2, 3)`, []string{}},
+		{`let a = [[1]]; len(...a); slice(...a)`, []string{}},
+		// pipes pass the value as an argument
+		{`[1] |> len(); [1] |> len; [1] |> slice(0)`, []string{}},
+		{`[1] |> len(2); 3 |> range(1, _, 2, 4)`, []string{
+			"1:8: len expects 1 argument, got 2",
+			"1:21: range expects 1 to 3 arguments, got 4",
+		}},
+	}
+
+	for _, tt := range tests {
+		diagnostics := BuiltinCallArity(parse(t, tt.input))
+
+		if len(diagnostics) != len(tt.expected) {
+			t.Errorf("%q: wrong number of diagnostics. expected=%q, got=%v", tt.input, tt.expected, diagnostics)
+			continue
+		}
+		for i, d := range diagnostics {
+			if d.String() != tt.expected[i] {
+				t.Errorf("%q: diagnostics[%d] wrong. expected=%q, got=%q", tt.input, i, tt.expected[i], d.String())
+			}
+		}
+	}
+}
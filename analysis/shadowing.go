@@ -0,0 +1,131 @@
+package analysis
+
+import (
+	"monkey/ast"
+	"monkey/token"
+)
+
+// Shadowing reports the position of every let statement binding a name that
+// is already bound in an enclosing scope, hiding the outer binding. A let
+// binding a name again in the same scope just rebinds it and isn't reported.
+//
+// The scopes are those of the evaluator: the program, function bodies,
+// blocks used as expressions and for loop bodies. The branches of an if and
+// the body of a while belong to the scope around them. Parameters are bound
+// in a scope of their own around the function body, so a let in the body
+// binding a parameter's name is reported, and the same goes for the variable
+// of a for loop.
+func Shadowing(p *ast.Program) []token.Position {
+	c := &shadowChecker{positions: []token.Position{}}
+	c.push()
+	c.visit(p)
+	return c.positions
+}
+
+type shadowChecker struct {
+	scopes    []map[string]bool // innermost last
+	positions []token.Position
+}
+
+func (c *shadowChecker) push() { c.scopes = append(c.scopes, map[string]bool{}) }
+func (c *shadowChecker) pop()  { c.scopes = c.scopes[:len(c.scopes)-1] }
+
+func (c *shadowChecker) bind(name *ast.Identifier) {
+	c.scopes[len(c.scopes)-1][name.Value] = true
+}
+
+// boundOutside reports whether name is bound in a scope enclosing the current one.
+func (c *shadowChecker) boundOutside(name *ast.Identifier) bool {
+	for _, scope := range c.scopes[:len(c.scopes)-1] {
+		if scope[name.Value] {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *shadowChecker) visit(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.LetStatement:
+		// the value is evaluated before the names are bound
+		if n.Value != nil {
+			c.visit(n.Value)
+		}
+		names := boundNames(n)
+		for _, name := range names {
+			if c.boundOutside(name) {
+				c.positions = append(c.positions, n.Pos())
+				break
+			}
+		}
+		for _, name := range names {
+			c.bind(name)
+		}
+		return
+	case *ast.FunctionLiteral:
+		c.push()
+		for _, param := range n.Parameters {
+			c.bind(param)
+		}
+		for _, def := range n.Defaults {
+			if def != nil {
+				c.visit(def)
+			}
+		}
+		c.push()
+		c.visit(n.Body)
+		c.pop()
+		c.pop()
+		return
+	case *ast.ForExpression:
+		c.visit(n.Iterable)
+		c.push()
+		c.bind(n.Variable)
+		c.push()
+		c.visit(n.Body)
+		c.pop()
+		c.pop()
+		return
+	case *ast.BlockExpression:
+		c.push()
+		c.visit(n.Block)
+		c.pop()
+		return
+	}
+
+	for _, child := range ast.Children(node) {
+		c.visit(child)
+	}
+}
+
+// boundNames returns the names a let statement binds, the identifiers of a
+// destructuring pattern included.
+func boundNames(let *ast.LetStatement) []*ast.Identifier {
+	if let.Pattern != nil {
+		return patternNames(let.Pattern)
+	}
+	if let.Name != nil {
+		return []*ast.Identifier{let.Name}
+	}
+	return nil
+}
+
+func patternNames(pattern ast.Expression) []*ast.Identifier {
+	switch p := pattern.(type) {
+	case *ast.Identifier:
+		return []*ast.Identifier{p}
+	case *ast.ArrayPattern:
+		names := []*ast.Identifier{}
+		for _, el := range p.Elements {
+			names = append(names, patternNames(el)...)
+		}
+		return names
+	case *ast.HashPattern:
+		names := []*ast.Identifier{}
+		for _, value := range p.Values {
+			names = append(names, patternNames(value)...)
+		}
+		return names
+	}
+	return nil
+}
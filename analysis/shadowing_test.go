@@ -0,0 +1,65 @@
+package analysis
+
+import (
+	"monkey/token"
+	"testing"
+)
+
+func TestShadowing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Position
+	}{
+		{
+			"let x = 1;\nlet f = fn() {\n  let x = 2;\n  x\n};",
+			[]token.Position{{Offset: 28, Line: 3, Column: 3}},
+		},
+		{
+			// sibling functions each have their own x
+			"let f = fn() { let x = 1; x };\nlet g = fn() { let x = 2; x };",
+			[]token.Position{},
+		},
+		{
+			// rebinding in the same scope isn't shadowing, nor is a let in
+			// an if branch, which shares the scope around it
+			"let x = 1;\nlet x = 2;\nif (x) { let x = 3; }",
+			[]token.Position{},
+		},
+		{
+			// the body of a function is nested in the scope of its parameters
+			"let f = fn(x) {\n  let x = x * 2;\n  x\n};",
+			[]token.Position{{Offset: 18, Line: 2, Column: 3}},
+		},
+		{
+			// a parameter may hide an outer name, only lets are reported
+			"let x = 1;\nlet f = fn(x) { x };",
+			[]token.Position{},
+		},
+		{
+			"let a = 1;\nlet g = fn() { let [b, a] = [1, 2]; { let b = 3; b } };",
+			[]token.Position{{Offset: 26, Line: 2, Column: 16}, {Offset: 49, Line: 2, Column: 39}},
+		},
+		{
+			"for (i in [1, 2]) { let i = 0; }",
+			[]token.Position{{Offset: 20, Line: 1, Column: 21}},
+		},
+		{
+			// names bound after the function was written aren't visible to it
+			"let f = fn() { let y = 1; y };\nlet y = 2;",
+			[]token.Position{},
+		},
+	}
+
+	for i, tt := range tests {
+		positions := Shadowing(parse(t, tt.input))
+
+		if len(positions) != len(tt.expected) {
+			t.Fatalf("tests[%d] - wrong number of positions. expected=%+v, got=%+v", i, tt.expected, positions)
+		}
+		for j, pos := range positions {
+			if pos != tt.expected[j] {
+				t.Errorf("tests[%d] - position %d wrong. expected=%+v, got=%+v", i, j, tt.expected[j], pos)
+			}
+		}
+	}
+}
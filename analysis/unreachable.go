@@ -0,0 +1,41 @@
+// Package analysis contains static checks over the AST, like a linter would run them.
+package analysis
+
+import (
+	"monkey/ast"
+	"monkey/token"
+)
+
+// UnreachableAfterReturn reports the position of every statement that follows
+// a return statement in the same block (or at the top level of the program).
+// Only the block containing the return is considered: code after an if whose
+// branches return is not flagged.
+func UnreachableAfterReturn(p *ast.Program) []token.Position {
+	positions := []token.Position{}
+
+	check := func(stmts []ast.Statement) {
+		returned := false
+		for _, s := range stmts {
+			if _, ok := s.(*ast.CommentStatement); ok {
+				continue
+			}
+			if returned {
+				positions = append(positions, s.Pos())
+			}
+			if _, ok := s.(*ast.ReturnStatement); ok {
+				returned = true
+			}
+		}
+	}
+
+	ast.Walk(p, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.Program:
+			check(n.Statements)
+		case *ast.BlockStatement:
+			check(n.Statements)
+		}
+		return true
+	})
+	return positions
+}
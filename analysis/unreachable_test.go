@@ -0,0 +1,57 @@
+package analysis
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"testing"
+)
+
+func TestUnreachableAfterReturn(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Position
+	}{
+		{
+			"let f = fn() {\n  return 1;\n  let x = 2;\n  x;\n};",
+			[]token.Position{{Offset: 29, Line: 3, Column: 3}, {Offset: 42, Line: 4, Column: 3}},
+		},
+		{
+			"let f = fn(x) {\n  let y = x * 2;\n  return y;\n};",
+			[]token.Position{},
+		},
+		{
+			// the return only ends its own branch, the code after the if is reachable
+			"let f = fn(x) {\n  if (x) { return 1; 5 } else { return 2; }\n  3;\n};",
+			[]token.Position{{Offset: 37, Line: 2, Column: 22}},
+		},
+		{
+			"return 1; 2;",
+			[]token.Position{{Offset: 10, Line: 1, Column: 11}},
+		},
+	}
+
+	for i, tt := range tests {
+		positions := UnreachableAfterReturn(parse(t, tt.input))
+
+		if len(positions) != len(tt.expected) {
+			t.Fatalf("tests[%d] - wrong number of positions. expected=%+v, got=%+v", i, tt.expected, positions)
+		}
+		for j, pos := range positions {
+			if pos != tt.expected[j] {
+				t.Errorf("tests[%d] - position %d wrong. expected=%+v, got=%+v", i, j, tt.expected[j], pos)
+			}
+		}
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program
+}
@@ -0,0 +1,130 @@
+package analysis
+
+import (
+	"monkey/ast"
+	"monkey/token"
+)
+
+// UnusedBindings reports the position of every name bound by a let statement
+// that is never referenced. Each let is a binding of its own, so in
+// `let x = 1; let x = 2; x` only the first x is unused. Parameters and loop
+// variables aren't reported, but they hide outer names like lets do.
+//
+// Scopes are those used by Shadowing. A reference from inside a function
+// counts for a binding made after the function, in a scope around it, since
+// the function may be called once the binding exists. That also counts a
+// function referring to itself as used.
+func UnusedBindings(p *ast.Program) []token.Position {
+	c := &usageChecker{}
+	c.push()
+	c.visit(p)
+
+	positions := []token.Position{}
+	for _, b := range c.bindings {
+		if !b.used && b.reported {
+			positions = append(positions, b.name.Pos())
+		}
+	}
+	return positions
+}
+
+type binding struct {
+	name     *ast.Identifier
+	used     bool
+	reported bool // only lets are reported, not parameters
+}
+
+type usageScope struct {
+	names   map[string]*binding
+	pending map[string]bool // referenced from a nested function before being bound
+}
+
+type usageChecker struct {
+	scopes   []*usageScope // innermost last
+	bindings []*binding    // in source order
+	depth    int           // how many functions are open
+}
+
+func (c *usageChecker) push() {
+	c.scopes = append(c.scopes, &usageScope{names: map[string]*binding{}, pending: map[string]bool{}})
+}
+
+func (c *usageChecker) pop() { c.scopes = c.scopes[:len(c.scopes)-1] }
+
+func (c *usageChecker) bind(name *ast.Identifier, reported bool) {
+	scope := c.scopes[len(c.scopes)-1]
+	b := &binding{name: name, reported: reported, used: scope.pending[name.Value]}
+	delete(scope.pending, name.Value)
+	scope.names[name.Value] = b
+	c.bindings = append(c.bindings, b)
+}
+
+func (c *usageChecker) reference(name *ast.Identifier) {
+	for i := len(c.scopes) - 1; i >= 0; i-- {
+		if b, ok := c.scopes[i].names[name.Value]; ok {
+			b.used = true
+			return
+		}
+	}
+	// it can only be bound later if the reference is evaluated later
+	if c.depth > 0 {
+		for _, scope := range c.scopes {
+			scope.pending[name.Value] = true
+		}
+	}
+}
+
+func (c *usageChecker) visit(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.Identifier:
+		c.reference(n)
+		return
+	case *ast.LetStatement:
+		if n.Value != nil {
+			c.visit(n.Value)
+		}
+		for _, name := range boundNames(n) {
+			c.bind(name, true)
+		}
+		return
+	case *ast.NamedArgument:
+		// the name is a parameter of the called function
+		c.visit(n.Value)
+		return
+	case *ast.FunctionLiteral:
+		c.depth++
+		c.push()
+		for _, param := range n.Parameters {
+			c.bind(param, false)
+		}
+		for _, def := range n.Defaults {
+			if def != nil {
+				c.visit(def)
+			}
+		}
+		c.push()
+		c.visit(n.Body)
+		c.pop()
+		c.pop()
+		c.depth--
+		return
+	case *ast.ForExpression:
+		c.visit(n.Iterable)
+		c.push()
+		c.bind(n.Variable, false)
+		c.push()
+		c.visit(n.Body)
+		c.pop()
+		c.pop()
+		return
+	case *ast.BlockExpression:
+		c.push()
+		c.visit(n.Block)
+		c.pop()
+		return
+	}
+
+	for _, child := range ast.Children(node) {
+		c.visit(child)
+	}
+}
@@ -0,0 +1,73 @@
+package analysis
+
+import (
+	"monkey/token"
+	"testing"
+)
+
+func TestUnusedBindings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Position
+	}{
+		{
+			"let x = 1;\nlet y = 2;\ny;",
+			[]token.Position{{Offset: 4, Line: 1, Column: 5}},
+		},
+		{
+			// used from a nested function, and from a function returned out
+			// of the scope that binds it
+			"let x = 1;\nlet f = fn() { fn() { x } };\nf;",
+			[]token.Position{},
+		},
+		{
+			"let counter = fn() {\n  let n = 0;\n  fn() { n = n + 1 }\n};\ncounter;",
+			[]token.Position{},
+		},
+		{
+			// the parameter hides the outer x, which stays unused
+			"let x = 1;\nlet f = fn(x) { x * 2 };\nf;",
+			[]token.Position{{Offset: 4, Line: 1, Column: 5}},
+		},
+		{
+			// unused parameters aren't reported
+			"let f = fn(a, b) { a };\nf;",
+			[]token.Position{},
+		},
+		{
+			"let x = 1;\nlet x = 2;\nx;",
+			[]token.Position{{Offset: 4, Line: 1, Column: 5}},
+		},
+		{
+			// a function may use a binding made after it
+			"let f = fn() { g() };\nlet g = fn() { 1 };\nf();",
+			[]token.Position{},
+		},
+		{
+			"let [a, b] = [1, 2];\nlet {c} = {\"c\": 3};\na;",
+			[]token.Position{{Offset: 8, Line: 1, Column: 9}, {Offset: 26, Line: 2, Column: 6}},
+		},
+		{
+			"for (i in [1]) { let sq = i * i; }",
+			[]token.Position{{Offset: 21, Line: 1, Column: 22}},
+		},
+		{
+			// a named argument isn't a reference to the outer a
+			"let a = 1;\nlet f = fn(a) { a };\nf(a = 2);",
+			[]token.Position{{Offset: 4, Line: 1, Column: 5}},
+		},
+	}
+
+	for i, tt := range tests {
+		positions := UnusedBindings(parse(t, tt.input))
+
+		if len(positions) != len(tt.expected) {
+			t.Fatalf("tests[%d] - wrong number of positions. expected=%+v, got=%+v", i, tt.expected, positions)
+		}
+		for j, pos := range positions {
+			if pos != tt.expected[j] {
+				t.Errorf("tests[%d] - position %d wrong. expected=%+v, got=%+v", i, j, tt.expected[j], pos)
+			}
+		}
+	}
+}
@@ -0,0 +1,838 @@
+package ast
+
+import (
+	"bytes"
+	"monkey/token"
+	"strings"
+)
+
+// Node is implemented by every node of the abstract syntax tree.
+// TokenLiteral is used for debugging and testing, String prints the node
+// back as source-like text and Pos is the position of the token the node
+// was created from.
+type Node interface {
+	TokenLiteral() string
+	String() string
+	Pos() token.Position
+}
+
+// Statement is a node that does not produce a value (let, return, ...).
+type Statement interface {
+	Node
+	statementNode()
+}
+
+// Expression is a node that produces a value.
+type Expression interface {
+	Node
+	expressionNode()
+}
+
+// Program is the root node of every AST the parser produces.
+// A program is just a sequence of statements.
+type Program struct {
+	Statements []Statement
+	End        int // offset of the end of the source, just past its last byte
+
+	// with parser.PreserveTrivia, the whitespace and comments after the
+	// last statement
+	Trailing []token.Token
+}
+
+func (p *Program) TokenLiteral() string {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].TokenLiteral()
+	}
+	return ""
+}
+
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{Line: 1, Column: 1}
+}
+
+// str is node.String(), or "" for a node missing after a parse error, so
+// the trees of programs with errors can still be printed.
+func str(node Node) string {
+	if isNil(node) {
+		return ""
+	}
+	return node.String()
+}
+
+func (p *Program) String() string {
+	var out bytes.Buffer
+	for _, s := range p.Statements {
+		out.WriteString(str(s))
+	}
+	return out.String()
+}
+
+// Source returns the source text the program was parsed from, comments and
+// whitespace included, if it was parsed with parser.PreserveTrivia.
+// Statements without Trivia are written as String writes them.
+func (p *Program) Source() string {
+	var out bytes.Buffer
+	for _, s := range p.Statements {
+		trivia := TriviaOf(s)
+		if trivia == nil {
+			out.WriteString(str(s))
+			continue
+		}
+		writeTokens(&out, trivia.Leading)
+		out.WriteString(trivia.Source)
+		writeTokens(&out, trivia.Trailing)
+	}
+	writeTokens(&out, p.Trailing)
+	return out.String()
+}
+
+func writeTokens(out *bytes.Buffer, tokens []token.Token) {
+	for _, tok := range tokens {
+		out.WriteString(tok.Literal)
+	}
+}
+
+// Trivia is what String loses of a statement: the whitespace and comments
+// around it and the way it is spelled in the source. The parser records it
+// with PreserveTrivia.
+type Trivia struct {
+	Leading  []token.Token // the whitespace and comments before the statement
+	Source   string        // the statement as written
+	Trailing []token.Token // whitespace and a comment following it on its last line
+}
+
+// TrailingComment returns the text of the comment at the end of the
+// statement's last line, like "the count" for `let x = 5; // the count`. It
+// is "" if there is none. A comment on a line of its own belongs to the
+// Leading trivia of the statement after it instead.
+func (t *Trivia) TrailingComment() string {
+	for _, tok := range t.Trailing {
+		if tok.Type == token.COMMENT {
+			return strings.TrimSpace(strings.TrimPrefix(tok.Literal, "//"))
+		}
+	}
+	return ""
+}
+
+// TriviaOf returns the trivia recorded for s, or nil if there is none.
+func TriviaOf(s Statement) *Trivia {
+	switch s := s.(type) {
+	case *LetStatement:
+		return s.Trivia
+	case *ReturnStatement:
+		return s.Trivia
+	case *ExpressionStatement:
+		return s.Trivia
+	case *CommentStatement:
+		return s.Trivia
+	case *BreakStatement:
+		return s.Trivia
+	case *ContinueStatement:
+		return s.Trivia
+	}
+	return nil
+}
+
+// LetStatement binds the value of an expression to a name: let <name> = <value>;
+type LetStatement struct {
+	Token   token.Token // the token.LET token
+	Name    *Identifier
+	Pattern Expression // an ArrayPattern or HashPattern, set instead of Name for `let [a, b] = ...`
+	Value   Expression
+	Trivia  *Trivia // with parser.PreserveTrivia
+}
+
+func (ls *LetStatement) statementNode()       {}
+func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LetStatement) Pos() token.Position  { return ls.Token.Pos }
+func (ls *LetStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString(ls.TokenLiteral() + " ")
+	if ls.Pattern != nil {
+		out.WriteString(str(ls.Pattern))
+	} else {
+		out.WriteString(str(ls.Name))
+	}
+	out.WriteString(" = ")
+	if ls.Value != nil {
+		out.WriteString(str(ls.Value))
+	}
+	out.WriteString(";")
+	return out.String()
+}
+
+// ArrayPattern is the left side of `let [a, b] = [1, 2];`. Each element is
+// an Identifier or a nested pattern.
+type ArrayPattern struct {
+	Token    token.Token // the [ token
+	Elements []Expression
+	Rbracket token.Position // position of the closing ]
+}
+
+func (ap *ArrayPattern) expressionNode()      {}
+func (ap *ArrayPattern) TokenLiteral() string { return ap.Token.Literal }
+func (ap *ArrayPattern) Pos() token.Position  { return ap.Token.Pos }
+func (ap *ArrayPattern) String() string {
+	elements := []string{}
+	for _, el := range ap.Elements {
+		elements = append(elements, str(el))
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// HashPattern is the left side of `let {x, y: [a, b]} = h;`. The value
+// stored under each key, as a string, is bound to the pattern in Values at
+// the same index. For the shorthand {x} that is the key itself.
+type HashPattern struct {
+	Token  token.Token // the { token
+	Keys   []*Identifier
+	Values []Expression
+	Rbrace token.Position // position of the closing }
+}
+
+func (hp *HashPattern) expressionNode()      {}
+func (hp *HashPattern) TokenLiteral() string { return hp.Token.Literal }
+func (hp *HashPattern) Pos() token.Position  { return hp.Token.Pos }
+func (hp *HashPattern) String() string {
+	pairs := []string{}
+	for i, key := range hp.Keys {
+		if hp.Values[i] == Expression(key) {
+			pairs = append(pairs, str(key))
+		} else {
+			pairs = append(pairs, str(key)+": "+str(hp.Values[i]))
+		}
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// ReturnStatement returns a value from a function: return <value>;
+type ReturnStatement struct {
+	Token       token.Token // the token.RETURN token
+	ReturnValue Expression
+	Trivia      *Trivia // with parser.PreserveTrivia
+}
+
+func (rs *ReturnStatement) statementNode()       {}
+func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ReturnStatement) Pos() token.Position  { return rs.Token.Pos }
+func (rs *ReturnStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString(rs.TokenLiteral() + " ")
+	if rs.ReturnValue != nil {
+		out.WriteString(str(rs.ReturnValue))
+	}
+	out.WriteString(";")
+	return out.String()
+}
+
+// BreakStatement ends the innermost loop: break;
+type BreakStatement struct {
+	Token  token.Token // the token.BREAK token
+	Trivia *Trivia     // with parser.PreserveTrivia
+}
+
+func (bs *BreakStatement) statementNode()       {}
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BreakStatement) String() string       { return bs.TokenLiteral() + ";" }
+
+// ContinueStatement skips the rest of the body of the innermost loop: continue;
+type ContinueStatement struct {
+	Token  token.Token // the token.CONTINUE token
+	Trivia *Trivia     // with parser.PreserveTrivia
+}
+
+func (cs *ContinueStatement) statementNode()       {}
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatement) Pos() token.Position  { return cs.Token.Pos }
+func (cs *ContinueStatement) String() string       { return cs.TokenLiteral() + ";" }
+
+// CommentStatement is a comment between statements, kept in the AST with
+// parser.CommentStatements. It does nothing when evaluated.
+type CommentStatement struct {
+	Token  token.Token // the token.COMMENT token, its literal includes the //
+	Trivia *Trivia     // with parser.PreserveTrivia
+}
+
+func (cs *CommentStatement) statementNode()       {}
+func (cs *CommentStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *CommentStatement) Pos() token.Position  { return cs.Token.Pos }
+func (cs *CommentStatement) String() string       { return cs.Token.Literal }
+
+// Text returns the comment without the // and the spaces around it.
+func (cs *CommentStatement) Text() string {
+	return strings.TrimSpace(strings.TrimPrefix(cs.Token.Literal, "//"))
+}
+
+// ExpressionStatement wraps an expression so it can stand on its own
+// as a statement, e.g. `x + 10;`
+type ExpressionStatement struct {
+	Token      token.Token // the first token of the expression
+	Expression Expression
+	Trivia     *Trivia // with parser.PreserveTrivia
+}
+
+func (es *ExpressionStatement) statementNode()       {}
+func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Pos() token.Position  { return es.Token.Pos }
+func (es *ExpressionStatement) String() string {
+	if es.Expression != nil {
+		return str(es.Expression)
+	}
+	return ""
+}
+
+// BlockStatement is a sequence of statements enclosed in braces,
+// used as the body of if-expressions and functions.
+type BlockStatement struct {
+	Token      token.Token // the { token
+	Statements []Statement
+	Rbrace     token.Position // position of the closing }
+}
+
+func (bs *BlockStatement) statementNode()       {}
+func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BlockStatement) String() string {
+	var out bytes.Buffer
+	for _, s := range bs.Statements {
+		out.WriteString(str(s))
+	}
+	return out.String()
+}
+
+// Identifier is a name bound to a value, e.g. `x` in `let x = 5;`
+type Identifier struct {
+	Token token.Token // the token.IDENT token
+	Value string
+}
+
+func (i *Identifier) expressionNode()      {}
+func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
+func (i *Identifier) Pos() token.Position  { return i.Token.Pos }
+func (i *Identifier) String() string       { return i.Value }
+
+// IntegerLiteral is a literal integer such as `5`.
+type IntegerLiteral struct {
+	Token token.Token
+	Value int64
+}
+
+func (il *IntegerLiteral) expressionNode()      {}
+func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
+func (il *IntegerLiteral) Pos() token.Position  { return il.Token.Pos }
+func (il *IntegerLiteral) String() string       { return il.Token.Literal }
+
+// FloatLiteral is a literal floating point number such as `1.5` or `2e3`.
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) Pos() token.Position  { return fl.Token.Pos }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+
+// StringLiteral is a literal string such as `"hello"`. Value holds the
+// contents without the quotes.
+type StringLiteral struct {
+	Token token.Token
+	Value string
+}
+
+func (sl *StringLiteral) expressionNode()      {}
+func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) Pos() token.Position  { return sl.Token.Pos }
+func (sl *StringLiteral) String() string       { return sl.Token.Literal }
+
+// Boolean is either the `true` or the `false` literal.
+type Boolean struct {
+	Token token.Token
+	Value bool
+}
+
+func (b *Boolean) expressionNode()      {}
+func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
+func (b *Boolean) Pos() token.Position  { return b.Token.Pos }
+func (b *Boolean) String() string       { return b.Token.Literal }
+
+// PrefixExpression is an operator applied to a single operand: <operator><right>
+type PrefixExpression struct {
+	Token    token.Token // the prefix token, e.g. ! or -
+	Operator string
+	Right    Expression
+}
+
+func (pe *PrefixExpression) expressionNode()      {}
+func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() token.Position  { return pe.Token.Pos }
+func (pe *PrefixExpression) String() string {
+	return "(" + pe.Operator + str(pe.Right) + ")"
+}
+
+// InfixExpression is an operator between two operands: <left> <operator> <right>
+type InfixExpression struct {
+	Token    token.Token // the operator token, e.g. +
+	Left     Expression
+	Operator string
+	Right    Expression
+}
+
+func (ie *InfixExpression) expressionNode()      {}
+func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *InfixExpression) Pos() token.Position  { return ie.Token.Pos }
+func (ie *InfixExpression) String() string {
+	return "(" + str(ie.Left) + " " + ie.Operator + " " + str(ie.Right) + ")"
+}
+
+// GroupedExpression is an expression in parentheses: (<expression>). The
+// parser only creates it with parser.PreserveParens, otherwise parentheses
+// leave no trace in the AST.
+type GroupedExpression struct {
+	Token      token.Token // the ( token
+	Expression Expression
+	Rparen     token.Position // position of the closing )
+}
+
+func (ge *GroupedExpression) expressionNode()      {}
+func (ge *GroupedExpression) TokenLiteral() string { return ge.Token.Literal }
+func (ge *GroupedExpression) Pos() token.Position  { return ge.Token.Pos }
+func (ge *GroupedExpression) String() string       { return "(" + str(ge.Expression) + ")" }
+
+// Unparen returns exp without the GroupedExpressions around it, for code
+// that looks at the kind of an expression, like whether it is an identifier.
+func Unparen(exp Expression) Expression {
+	for {
+		grouped, ok := exp.(*GroupedExpression)
+		if !ok {
+			return exp
+		}
+		exp = grouped.Expression
+	}
+}
+
+// IfExpression is `if (<condition>) <consequence> else <alternative>`.
+// Alternative is nil when there is no else branch.
+type IfExpression struct {
+	Token       token.Token // the 'if' token
+	Condition   Expression
+	Consequence *BlockStatement
+	Alternative *BlockStatement
+}
+
+func (ie *IfExpression) expressionNode()      {}
+func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) Pos() token.Position  { return ie.Token.Pos }
+func (ie *IfExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("if")
+	out.WriteString(str(ie.Condition))
+	out.WriteString(" ")
+	out.WriteString(str(ie.Consequence))
+	if ie.Alternative != nil {
+		out.WriteString("else ")
+		out.WriteString(str(ie.Alternative))
+	}
+	return out.String()
+}
+
+// BlockExpression is a brace-delimited block used in expression position,
+// e.g. `let y = { let x = 1; x + 1 };`. Its value is the value of the last
+// expression statement in the block.
+type BlockExpression struct {
+	Token token.Token // the { token
+	Block *BlockStatement
+}
+
+func (be *BlockExpression) expressionNode()      {}
+func (be *BlockExpression) TokenLiteral() string { return be.Token.Literal }
+func (be *BlockExpression) Pos() token.Position  { return be.Token.Pos }
+func (be *BlockExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("{ ")
+	for i, s := range be.Block.Statements {
+		if i > 0 {
+			out.WriteString(" ")
+		}
+		out.WriteString(str(s))
+	}
+	out.WriteString(" }")
+	return out.String()
+}
+
+// FunctionLiteral is `fn(<parameters>) <body>`. Defaults holds the default
+// value of every parameter, nil where a parameter has none: `fn(x, y = 10)`
+// has the defaults [nil, 10].
+type FunctionLiteral struct {
+	Token      token.Token // the 'fn' token
+	Parameters []*Identifier
+	Defaults   []Expression
+	Body       *BlockStatement
+}
+
+func (fl *FunctionLiteral) expressionNode()      {}
+func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.Position  { return fl.Token.Pos }
+func (fl *FunctionLiteral) String() string {
+	var out bytes.Buffer
+	params := []string{}
+	for i, p := range fl.Parameters {
+		if i < len(fl.Defaults) && fl.Defaults[i] != nil {
+			params = append(params, str(p)+" = "+str(fl.Defaults[i]))
+		} else {
+			params = append(params, str(p))
+		}
+	}
+	out.WriteString(fl.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	out.WriteString(str(fl.Body))
+	return out.String()
+}
+
+// CallExpression is `<function>(<arguments>)` where function is either
+// an identifier or a function literal.
+type CallExpression struct {
+	Token     token.Token // the ( token
+	Function  Expression
+	Arguments []Expression
+	Rparen    token.Position // position of the closing )
+}
+
+func (ce *CallExpression) expressionNode()      {}
+func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Pos() token.Position  { return ce.Token.Pos }
+func (ce *CallExpression) String() string {
+	var out bytes.Buffer
+	args := []string{}
+	for _, a := range ce.Arguments {
+		args = append(args, str(a))
+	}
+	out.WriteString(str(ce.Function))
+	out.WriteString("(")
+	out.WriteString(strings.Join(args, ", "))
+	out.WriteString(")")
+	return out.String()
+}
+
+// PipeExpression is `<left> |> <right>`, which calls right with the value of
+// left. Right is usually a call, `x |> f(y)`, and then the value is passed as
+// the first argument, f(x, y), unless arguments are Placeholders, which
+// stand for the value instead: `x |> f(y, _)` is f(y, x). A placeholder
+// has to be an argument of that call itself, the parser rejects one nested
+// deeper, as in `x |> f(g(_))`. Any other right is called with the value
+// as the only argument.
+type PipeExpression struct {
+	Token token.Token // the |> token
+	Left  Expression
+	Right Expression
+}
+
+func (pe *PipeExpression) expressionNode()      {}
+func (pe *PipeExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PipeExpression) Pos() token.Position  { return pe.Token.Pos }
+func (pe *PipeExpression) String() string {
+	return "(" + str(pe.Left) + " |> " + str(pe.Right) + ")"
+}
+
+// Piped returns the call that right stands for, with the arguments the
+// value of left is passed as: the Placeholders, or else a Placeholder added
+// as the first argument. A right that isn't a call is called with just a
+// Placeholder.
+func (pe *PipeExpression) Piped() *CallExpression {
+	placeholder := &Placeholder{Token: token.Token{Type: token.UNDERSCORE, Literal: "_", Pos: pe.Token.Pos}}
+	call, ok := Unparen(pe.Right).(*CallExpression)
+	if !ok {
+		return &CallExpression{Token: pe.Token, Function: pe.Right, Arguments: []Expression{placeholder}}
+	}
+	for _, arg := range call.Arguments {
+		if _, ok := arg.(*Placeholder); ok {
+			return call
+		}
+	}
+	piped := *call
+	piped.Arguments = append([]Expression{placeholder}, call.Arguments...)
+	return &piped
+}
+
+// Placeholder is `_` among the arguments of a call after |>, see
+// PipeExpression.
+type Placeholder struct {
+	Token token.Token // the token.UNDERSCORE token
+}
+
+func (ph *Placeholder) expressionNode()      {}
+func (ph *Placeholder) TokenLiteral() string { return ph.Token.Literal }
+func (ph *Placeholder) Pos() token.Position  { return ph.Token.Pos }
+func (ph *Placeholder) String() string       { return "_" }
+
+// ArrayLiteral is `[<expression>, <expression>, ...]`.
+type ArrayLiteral struct {
+	Token    token.Token // the [ token
+	Elements []Expression
+	Rbracket token.Position // position of the closing ]
+}
+
+func (al *ArrayLiteral) expressionNode()      {}
+func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position  { return al.Token.Pos }
+func (al *ArrayLiteral) String() string {
+	var out bytes.Buffer
+	elements := []string{}
+	for _, el := range al.Elements {
+		elements = append(elements, str(el))
+	}
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+	return out.String()
+}
+
+// IndexExpression is `<left>[<index>]`, used for arrays and hashes.
+type IndexExpression struct {
+	Token    token.Token // the [ token
+	Left     Expression
+	Index    Expression
+	Rbracket token.Position // position of the closing ]
+}
+
+func (ie *IndexExpression) expressionNode()      {}
+func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position  { return ie.Token.Pos }
+func (ie *IndexExpression) String() string {
+	return "(" + str(ie.Left) + "[" + str(ie.Index) + "])"
+}
+
+// DotExpression is `<left>.<name>`. As the function of a call,
+// `<left>.<name>(<args>)` calls name with left as the first argument, like
+// `name(<left>, <args>)`. Anywhere else left must be a hash, and the name is
+// looked up as a string key, `<left>["<name>"]`, like `<left>?.<name>` does.
+type DotExpression struct {
+	Token token.Token // the . token
+	Left  Expression
+	Name  *Identifier
+}
+
+func (de *DotExpression) expressionNode()      {}
+func (de *DotExpression) TokenLiteral() string { return de.Token.Literal }
+func (de *DotExpression) Pos() token.Position  { return de.Token.Pos }
+func (de *DotExpression) String() string {
+	return "(" + str(de.Left) + "." + str(de.Name) + ")"
+}
+
+// OptionalIndexExpression is `<left>?.<name>` or `<left>?.<index>`, which
+// is null when left is null and otherwise looks up the name as a string key,
+// `<left>["<name>"]`, or the index, `<left>[<index>]`. Index is the
+// StringLiteral holding the name or the IntegerLiteral. Chained, as in
+// `a?.b?.c`, the first null receiver makes the rest null too.
+type OptionalIndexExpression struct {
+	Token token.Token // the ?. token
+	Left  Expression
+	Index Expression
+}
+
+func (oe *OptionalIndexExpression) expressionNode()      {}
+func (oe *OptionalIndexExpression) TokenLiteral() string { return oe.Token.Literal }
+func (oe *OptionalIndexExpression) Pos() token.Position  { return oe.Token.Pos }
+func (oe *OptionalIndexExpression) String() string {
+	return "(" + str(oe.Left) + "?." + oe.Index.TokenLiteral() + ")"
+}
+
+// HashPair is a single `<key>: <value>` entry of a hash literal. For a
+// spread entry, `...<hash>`, Key is a SpreadElement and Value is nil.
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+// HashLiteral is `{<key>: <value>, ...}`. Pairs are kept in source order.
+type HashLiteral struct {
+	Token  token.Token // the { token
+	Pairs  []HashPair
+	Rbrace token.Position // position of the closing }
+}
+
+func (hl *HashLiteral) expressionNode()      {}
+func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.Position  { return hl.Token.Pos }
+func (hl *HashLiteral) String() string {
+	var out bytes.Buffer
+	pairs := []string{}
+	for _, pair := range hl.Pairs {
+		if pair.Value == nil {
+			pairs = append(pairs, str(pair.Key))
+			continue
+		}
+		pairs = append(pairs, str(pair.Key)+": "+str(pair.Value))
+	}
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+	return out.String()
+}
+
+// SpreadElement is `...<expression>` in an array or hash literal or among
+// the arguments of a call. It stands for all the elements of an array or all
+// the pairs of a hash.
+type SpreadElement struct {
+	Token token.Token // the ... token
+	Value Expression
+}
+
+func (se *SpreadElement) expressionNode()      {}
+func (se *SpreadElement) TokenLiteral() string { return se.Token.Literal }
+func (se *SpreadElement) Pos() token.Position  { return se.Token.Pos }
+func (se *SpreadElement) String() string       { return "..." + str(se.Value) }
+
+// NamedArgument is a call argument bound by parameter name: `greet(name = "Sam")`.
+type NamedArgument struct {
+	Token token.Token // the name's token.IDENT token
+	Name  *Identifier
+	Value Expression
+}
+
+func (na *NamedArgument) expressionNode()      {}
+func (na *NamedArgument) TokenLiteral() string { return na.Token.Literal }
+func (na *NamedArgument) Pos() token.Position  { return na.Token.Pos }
+func (na *NamedArgument) String() string {
+	return str(na.Name) + " = " + str(na.Value)
+}
+
+// AssignExpression rebinds an existing name, `<name> = <value>`, or replaces
+// an element of an array or hash, `<array>[<index>] = <value>`. Its value is
+// the assigned value, so assignments can be chained.
+type AssignExpression struct {
+	Token token.Token // the = token
+	Name  *Identifier
+	Index *IndexExpression // set instead of Name for `a[i] = v`
+	Value Expression
+}
+
+func (ae *AssignExpression) expressionNode()      {}
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssignExpression) Pos() token.Position  { return ae.Token.Pos }
+func (ae *AssignExpression) String() string {
+	return "(" + str(ae.Target()) + " = " + str(ae.Value) + ")"
+}
+
+// Target returns what is assigned to, the Name or the Index.
+func (ae *AssignExpression) Target() Expression {
+	if ae.Index != nil {
+		return ae.Index
+	}
+	return ae.Name
+}
+
+// WhileExpression is `while (<condition>) <body>`. It evaluates to null.
+type WhileExpression struct {
+	Token     token.Token // the 'while' token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (we *WhileExpression) expressionNode()      {}
+func (we *WhileExpression) TokenLiteral() string { return we.Token.Literal }
+func (we *WhileExpression) Pos() token.Position  { return we.Token.Pos }
+func (we *WhileExpression) String() string {
+	return "while" + str(we.Condition) + " " + str(we.Body)
+}
+
+// DoWhileExpression is `do <body> while (<condition>)`. The body runs once
+// before the condition is first checked. Like a while loop it evaluates to
+// null.
+type DoWhileExpression struct {
+	Token     token.Token // the 'do' token
+	Body      *BlockStatement
+	Condition Expression
+	Rparen    token.Position // position of the ) closing the condition
+}
+
+func (dw *DoWhileExpression) expressionNode()      {}
+func (dw *DoWhileExpression) TokenLiteral() string { return dw.Token.Literal }
+func (dw *DoWhileExpression) Pos() token.Position  { return dw.Token.Pos }
+func (dw *DoWhileExpression) String() string {
+	return "do " + str(dw.Body) + " while" + str(dw.Condition)
+}
+
+// ForExpression is `for (<variable> in <iterable>) <body>`. Like a while
+// loop it evaluates to null.
+type ForExpression struct {
+	Token    token.Token // the 'for' token
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fe *ForExpression) expressionNode()      {}
+func (fe *ForExpression) TokenLiteral() string { return fe.Token.Literal }
+func (fe *ForExpression) Pos() token.Position  { return fe.Token.Pos }
+func (fe *ForExpression) String() string {
+	return "for (" + str(fe.Variable) + " in " + str(fe.Iterable) + ") " + str(fe.Body)
+}
+
+// MatchExpression is `match <subject> { <pattern> => <value>, ... }`. The
+// value of the first arm whose pattern equals the subject is the value of
+// the match.
+type MatchExpression struct {
+	Token   token.Token // the 'match' token
+	Subject Expression
+	Arms    []*MatchArm
+	Rbrace  token.Position // position of the closing }
+}
+
+func (me *MatchExpression) expressionNode()      {}
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MatchExpression) Pos() token.Position  { return me.Token.Pos }
+func (me *MatchExpression) String() string {
+	arms := []string{}
+	for _, arm := range me.Arms {
+		arms = append(arms, str(arm))
+	}
+	return "match " + str(me.Subject) + " { " + strings.Join(arms, ", ") + " }"
+}
+
+// MatchArm is one `<pattern> => <value>` of a match. Pattern is nil for the
+// wildcard _, which matches anything.
+type MatchArm struct {
+	Token   token.Token // the first token of the pattern
+	Pattern Expression
+	Value   Expression
+}
+
+func (ma *MatchArm) TokenLiteral() string { return ma.Token.Literal }
+func (ma *MatchArm) Pos() token.Position  { return ma.Token.Pos }
+func (ma *MatchArm) String() string {
+	pattern := "_"
+	if ma.Pattern != nil {
+		pattern = str(ma.Pattern)
+	}
+	return pattern + " => " + str(ma.Value)
+}
+
+// TemplateLiteral is a string with interpolations: `"Hello ${name}!"`.
+// Parts are the literal pieces around the interpolated Values, so there is
+// always one more part than there are values: ["Hello ", "!"] and [name].
+type TemplateLiteral struct {
+	Token  token.Token // the token.TEMPLATE token
+	Parts  []string
+	Values []Expression
+}
+
+func (tl *TemplateLiteral) expressionNode()      {}
+func (tl *TemplateLiteral) TokenLiteral() string { return tl.Token.Literal }
+func (tl *TemplateLiteral) Pos() token.Position  { return tl.Token.Pos }
+func (tl *TemplateLiteral) String() string {
+	var out bytes.Buffer
+	for i, part := range tl.Parts {
+		out.WriteString(part)
+		if i < len(tl.Values) {
+			out.WriteString("${" + str(tl.Values[i]) + "}")
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,68 @@
+package ast
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strconv"
+)
+
+// Fingerprint returns a stable hash of the structure of the program. It only
+// depends on the tree, not on the source text it was parsed from, so two
+// programs that differ in whitespace, semicolons or the spelling of a literal
+// (0x10 and 16) get the same fingerprint. Hosts can use it as a cache key.
+func Fingerprint(p *Program) string {
+	h := sha256.New()
+	writeFingerprint(h, p)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeFingerprint writes node in pre-order. Every node is written with its
+// type, its own values and its number of children, which makes the encoding
+// of the tree unambiguous.
+func writeFingerprint(h hash.Hash, node Node) {
+	children := Children(node)
+	fmt.Fprintf(h, "%T %q %d;", node, nodeValue(node), len(children))
+	for _, child := range children {
+		writeFingerprint(h, child)
+	}
+}
+
+// nodeValue returns the part of a node that isn't one of its children,
+// like the name of an identifier or the operator of an infix expression.
+func nodeValue(node Node) string {
+	switch n := node.(type) {
+	case *Identifier:
+		return n.Value
+	case *IntegerLiteral:
+		return strconv.FormatInt(n.Value, 10)
+	case *FloatLiteral:
+		return strconv.FormatFloat(n.Value, 'g', -1, 64)
+	case *StringLiteral:
+		return n.Value
+	case *TemplateLiteral:
+		return fmt.Sprintf("%q", n.Parts)
+	case *Boolean:
+		return strconv.FormatBool(n.Value)
+	case *PrefixExpression:
+		return n.Operator
+	case *InfixExpression:
+		return n.Operator
+	case *FunctionLiteral:
+		// defaults are children too, so fn(a, b = c) and fn(a, b, c) would
+		// look the same without the number of defaults
+		count := 0
+		for _, d := range n.Defaults {
+			if d != nil {
+				count++
+			}
+		}
+		return strconv.Itoa(count)
+	case *MatchArm:
+		if n.Pattern == nil {
+			return "_"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package ast_test
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"testing"
+)
+
+func TestFingerprintIgnoresFormatting(t *testing.T) {
+	a := `let add = fn(x, y) { x + y; }; add(1, 2);`
+	b := `
+let add = fn(x, y) {
+    x + y
+};
+
+add(1,
+    0x2)
+`
+	if fingerprint(t, a) != fingerprint(t, b) {
+		t.Errorf("programs differing only in formatting have different fingerprints")
+	}
+}
+
+func TestFingerprintDetectsChanges(t *testing.T) {
+	base := `let add = fn(x, y) { x + y; }; add(1, 2);`
+	changes := []string{
+		`let add = fn(x, y) { x - y; }; add(1, 2);`,
+		`let add = fn(x, z) { x + z; }; add(1, 2);`,
+		`let add = fn(x, y) { x + y; }; add(1, 3);`,
+		`let add = fn(x, y) { x + y; }; add("1", 2);`,
+		`let add = fn(x, y) { x + y; }; add([1, 2]);`,
+		`let add = fn(x, y) { x + y; }; add(1); 2;`,
+	}
+
+	for _, changed := range changes {
+		if fingerprint(t, base) == fingerprint(t, changed) {
+			t.Errorf("%q has the same fingerprint as %q", changed, base)
+		}
+	}
+}
+
+func TestFingerprintIsStable(t *testing.T) {
+	src := `{"a": [1, 2], "b": fn() { if (true) { 1 } else { 2 } }}`
+	if fingerprint(t, src) != fingerprint(t, src) {
+		t.Errorf("fingerprint of the same program changed")
+	}
+}
+
+func fingerprint(t *testing.T, input string) string {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return ast.Fingerprint(program)
+}
@@ -0,0 +1,142 @@
+package ast
+
+import (
+	"monkey/token"
+	"sort"
+)
+
+// Span is the part of the source a node was parsed from, from the offset
+// of its first byte up to but not including End.
+type Span struct {
+	Start int
+	End   int
+}
+
+// Contains reports whether the byte at offset is part of the span.
+func (s Span) Contains(offset int) bool {
+	return s.Start <= offset && offset < s.End
+}
+
+// NodeIndex answers which node of a program is at a position in its source,
+// for editor features like hovering or go to definition. Build it with
+// BuildIndex.
+type NodeIndex struct {
+	entries []indexEntry // by start, outer nodes before the nodes inside them
+}
+
+type indexEntry struct {
+	node Node
+	span Span
+}
+
+// BuildIndex computes the span of every node of p below the program itself.
+// A node spans from its first to its last token, closing brackets included,
+// which makes the spans nest like the nodes: a node's span contains the
+// spans of its children and the spans of siblings don't overlap.
+func BuildIndex(p *Program) *NodeIndex {
+	ix := &NodeIndex{}
+	for _, s := range p.Statements {
+		if !isNil(s) {
+			ix.add(s)
+		}
+	}
+	sort.SliceStable(ix.entries, func(i, j int) bool {
+		return ix.entries[i].span.Start < ix.entries[j].span.Start
+	})
+	return ix
+}
+
+// add records node and the nodes below it, and returns the span of node.
+func (ix *NodeIndex) add(node Node) Span {
+	start := node.Pos().Offset
+	span := Span{Start: start, End: start + tokenLength(node)}
+	i := len(ix.entries)
+	ix.entries = append(ix.entries, indexEntry{node: node})
+
+	for _, child := range Children(node) {
+		s := ix.add(child)
+		if s.Start < span.Start {
+			span.Start = s.Start
+		}
+		if s.End > span.End {
+			span.End = s.End
+		}
+	}
+	if closing, ok := closingDelimiter(node); ok && closing.Offset+1 > span.End {
+		span.End = closing.Offset + 1
+	}
+
+	ix.entries[i].span = span
+	return span
+}
+
+// At returns the innermost node whose span contains pos, or nil when pos is
+// outside every statement, like in the whitespace between two of them.
+// Only the offset of pos is looked at.
+func (ix *NodeIndex) At(pos token.Position) Node {
+	// the entries starting after pos can't contain it. Of the others, the
+	// last one that does is the innermost: any entry after it containing pos
+	// would have to be inside it.
+	n := sort.Search(len(ix.entries), func(i int) bool {
+		return ix.entries[i].span.Start > pos.Offset
+	})
+	for i := n - 1; i >= 0; i-- {
+		if ix.entries[i].span.Contains(pos.Offset) {
+			return ix.entries[i].node
+		}
+	}
+	return nil
+}
+
+// tokenLength returns the length in the source of the token node was created
+// from. The quotes around a string aren't part of its literal. A heredoc
+// is counted like a quoted string, so its span stops short of its closing
+// line.
+func tokenLength(node Node) int {
+	var tok token.Token
+	switch n := node.(type) {
+	case *StringLiteral:
+		tok = n.Token
+	case *TemplateLiteral:
+		tok = n.Token
+	case *IntegerLiteral:
+		return len(n.Token.Literal) + len(n.Token.Suffix)
+	case *FloatLiteral:
+		return len(n.Token.Literal) + len(n.Token.Suffix)
+	default:
+		return len(node.TokenLiteral())
+	}
+	switch tok.Type {
+	case token.STRING, token.TEMPLATE, token.RAW_STRING:
+		return len(tok.Literal) + 2
+	}
+	return len(tok.Literal)
+}
+
+// closingDelimiter returns the position of the bracket closing node, for the
+// nodes ending in one.
+func closingDelimiter(node Node) (token.Position, bool) {
+	switch n := node.(type) {
+	case *BlockStatement:
+		return n.Rbrace, true
+	case *CallExpression:
+		return n.Rparen, true
+	case *ArrayLiteral:
+		return n.Rbracket, true
+	case *IndexExpression:
+		return n.Rbracket, true
+	case *HashLiteral:
+		return n.Rbrace, true
+	case *MatchExpression:
+		return n.Rbrace, true
+	case *ArrayPattern:
+		return n.Rbracket, true
+	case *HashPattern:
+		return n.Rbrace, true
+	case *DoWhileExpression:
+		return n.Rparen, true
+	case *GroupedExpression:
+		return n.Rparen, true
+	}
+	return token.Position{}, false
+}
@@ -0,0 +1,66 @@
+package ast_test
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"strings"
+	"testing"
+)
+
+func TestNodeIndex(t *testing.T) {
+	input := "let f = fn(x) { [x, g(x + 1)] };\n\nf(\"ab\")[0]"
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	index := ast.BuildIndex(program)
+
+	tests := []struct {
+		at           string // the text at the position, the first match in input
+		expectedType string
+		expected     string // String of the node
+	}{
+		{"let", "*ast.LetStatement", "let f = fn(x) [x, g((x + 1))];"},
+		{"f =", "*ast.Identifier", "f"},
+		{"fn", "*ast.FunctionLiteral", "fn(x) [x, g((x + 1))]"},
+		{"{ [", "*ast.BlockStatement", "[x, g((x + 1))]"},
+		{"[x", "*ast.ArrayLiteral", "[x, g((x + 1))]"},
+		{"g(", "*ast.Identifier", "g"},
+		{"(x +", "*ast.CallExpression", "g((x + 1))"},
+		{"+", "*ast.InfixExpression", "(x + 1)"},
+		{"1", "*ast.IntegerLiteral", "1"},
+		{")]", "*ast.CallExpression", "g((x + 1))"},
+		{"] }", "*ast.ArrayLiteral", "[x, g((x + 1))]"},
+		{"};", "*ast.BlockStatement", "[x, g((x + 1))]"},
+		{"\"ab", "*ast.StringLiteral", "ab"},
+		{"b\"", "*ast.StringLiteral", "ab"},
+		{"\")", "*ast.StringLiteral", "ab"},
+		{")[", "*ast.CallExpression", "f(ab)"},
+		{"0", "*ast.IntegerLiteral", "0"},
+		{"0]", "*ast.IntegerLiteral", "0"},
+	}
+
+	for _, tt := range tests {
+		offset := strings.Index(input, tt.at)
+		node := index.At(token.OffsetToPosition(input, offset))
+		if fmt.Sprintf("%T", node) != tt.expectedType || node.String() != tt.expected {
+			t.Errorf("%q: expected %s %q, got %T %v", tt.at, tt.expectedType, tt.expected, node, node)
+		}
+	}
+
+	// the last ] closes the index expression
+	if node := index.At(token.OffsetToPosition(input, len(input)-1)); node == nil || node.String() != "(f(ab)[0])" {
+		t.Errorf("wrong node at the last ]. got=%v", node)
+	}
+
+	// outside of every statement
+	for _, offset := range []int{strings.Index(input, ";"), strings.Index(input, "\n") + 1, len(input), len(input) + 10} {
+		if node := index.At(token.Position{Offset: offset}); node != nil {
+			t.Errorf("expected no node at offset %d, got %T %q", offset, node, node.String())
+		}
+	}
+}
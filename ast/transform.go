@@ -0,0 +1,338 @@
+package ast
+
+// Transform rebuilds the tree rooted at node bottom-up. The children of a
+// node are transformed first, then fn is called with the node, and what fn
+// returns takes the node's place. Returning the node itself keeps it.
+//
+// Nodes are never modified: a node whose children changed is replaced by a
+// copy with the new children, so the parts of the tree fn left alone are
+// shared by the old and the new tree, and the old tree stays as it was.
+//
+// fn has to return a node that fits where the old one was: an expression
+// for an expression, a statement for a statement and a node of the same
+// type for the names, blocks and match arms stored with their own types.
+func Transform(node Node, fn func(Node) Node) Node {
+	if isNil(node) {
+		return node
+	}
+	t := transformer{fn}
+
+	switch n := node.(type) {
+	case *Program:
+		if stmts, ok := t.stmts(n.Statements); ok {
+			c := *n
+			c.Statements = stmts
+			node = &c
+		}
+	case *LetStatement:
+		name, ok1 := t.ident(n.Name)
+		pattern, ok2 := t.expr(n.Pattern)
+		value, ok3 := t.expr(n.Value)
+		if ok1 || ok2 || ok3 {
+			c := *n
+			c.Name, c.Pattern, c.Value = name, pattern, value
+			node = &c
+		}
+	case *ArrayPattern:
+		if elements, ok := t.exprs(n.Elements); ok {
+			c := *n
+			c.Elements = elements
+			node = &c
+		}
+	case *HashPattern:
+		changed := false
+		keys := make([]*Identifier, len(n.Keys))
+		values := make([]Expression, len(n.Values))
+		for i, key := range n.Keys {
+			var ok1, ok2 bool
+			keys[i], ok1 = t.ident(key)
+			if n.Values[i] == Expression(key) {
+				// the shorthand {x}, the key is the value
+				values[i] = keys[i]
+			} else {
+				values[i], ok2 = t.expr(n.Values[i])
+			}
+			changed = changed || ok1 || ok2
+		}
+		if changed {
+			c := *n
+			c.Keys, c.Values = keys, values
+			node = &c
+		}
+	case *ReturnStatement:
+		if value, ok := t.expr(n.ReturnValue); ok {
+			c := *n
+			c.ReturnValue = value
+			node = &c
+		}
+	case *ExpressionStatement:
+		if exp, ok := t.expr(n.Expression); ok {
+			c := *n
+			c.Expression = exp
+			node = &c
+		}
+	case *BlockStatement:
+		if stmts, ok := t.stmts(n.Statements); ok {
+			c := *n
+			c.Statements = stmts
+			node = &c
+		}
+	case *PrefixExpression:
+		if right, ok := t.expr(n.Right); ok {
+			c := *n
+			c.Right = right
+			node = &c
+		}
+	case *InfixExpression:
+		left, ok1 := t.expr(n.Left)
+		right, ok2 := t.expr(n.Right)
+		if ok1 || ok2 {
+			c := *n
+			c.Left, c.Right = left, right
+			node = &c
+		}
+	case *IfExpression:
+		condition, ok1 := t.expr(n.Condition)
+		consequence, ok2 := t.block(n.Consequence)
+		alternative, ok3 := t.block(n.Alternative)
+		if ok1 || ok2 || ok3 {
+			c := *n
+			c.Condition, c.Consequence, c.Alternative = condition, consequence, alternative
+			node = &c
+		}
+	case *BlockExpression:
+		if block, ok := t.block(n.Block); ok {
+			c := *n
+			c.Block = block
+			node = &c
+		}
+	case *FunctionLiteral:
+		changed := false
+		params := make([]*Identifier, len(n.Parameters))
+		for i, param := range n.Parameters {
+			var ok bool
+			params[i], ok = t.ident(param)
+			changed = changed || ok
+		}
+		defaults, ok1 := t.exprs(n.Defaults)
+		body, ok2 := t.block(n.Body)
+		if changed || ok1 || ok2 {
+			c := *n
+			c.Parameters, c.Defaults, c.Body = params, defaults, body
+			node = &c
+		}
+	case *CallExpression:
+		function, ok1 := t.expr(n.Function)
+		args, ok2 := t.exprs(n.Arguments)
+		if ok1 || ok2 {
+			c := *n
+			c.Function, c.Arguments = function, args
+			node = &c
+		}
+	case *NamedArgument:
+		name, ok1 := t.ident(n.Name)
+		value, ok2 := t.expr(n.Value)
+		if ok1 || ok2 {
+			c := *n
+			c.Name, c.Value = name, value
+			node = &c
+		}
+	case *SpreadElement:
+		if value, ok := t.expr(n.Value); ok {
+			c := *n
+			c.Value = value
+			node = &c
+		}
+	case *GroupedExpression:
+		if exp, ok := t.expr(n.Expression); ok {
+			c := *n
+			c.Expression = exp
+			node = &c
+		}
+	case *PipeExpression:
+		left, ok1 := t.expr(n.Left)
+		right, ok2 := t.expr(n.Right)
+		if ok1 || ok2 {
+			c := *n
+			c.Left, c.Right = left, right
+			node = &c
+		}
+	case *ArrayLiteral:
+		if elements, ok := t.exprs(n.Elements); ok {
+			c := *n
+			c.Elements = elements
+			node = &c
+		}
+	case *IndexExpression:
+		left, ok1 := t.expr(n.Left)
+		index, ok2 := t.expr(n.Index)
+		if ok1 || ok2 {
+			c := *n
+			c.Left, c.Index = left, index
+			node = &c
+		}
+	case *OptionalIndexExpression:
+		left, ok1 := t.expr(n.Left)
+		index, ok2 := t.expr(n.Index)
+		if ok1 || ok2 {
+			c := *n
+			c.Left, c.Index = left, index
+			node = &c
+		}
+	case *DotExpression:
+		left, ok1 := t.expr(n.Left)
+		name, ok2 := t.ident(n.Name)
+		if ok1 || ok2 {
+			c := *n
+			c.Left, c.Name = left, name
+			node = &c
+		}
+	case *HashLiteral:
+		changed := false
+		pairs := make([]HashPair, len(n.Pairs))
+		for i, pair := range n.Pairs {
+			key, ok1 := t.expr(pair.Key)
+			value, ok2 := t.expr(pair.Value)
+			pairs[i] = HashPair{Key: key, Value: value}
+			changed = changed || ok1 || ok2
+		}
+		if changed {
+			c := *n
+			c.Pairs = pairs
+			node = &c
+		}
+	case *AssignExpression:
+		name, ok1 := t.ident(n.Name)
+		var index *IndexExpression
+		var ok2 bool
+		if n.Index != nil {
+			index = Transform(n.Index, fn).(*IndexExpression)
+			ok2 = index != n.Index
+		}
+		value, ok3 := t.expr(n.Value)
+		if ok1 || ok2 || ok3 {
+			c := *n
+			c.Name, c.Index, c.Value = name, index, value
+			node = &c
+		}
+	case *WhileExpression:
+		condition, ok1 := t.expr(n.Condition)
+		body, ok2 := t.block(n.Body)
+		if ok1 || ok2 {
+			c := *n
+			c.Condition, c.Body = condition, body
+			node = &c
+		}
+	case *DoWhileExpression:
+		body, ok1 := t.block(n.Body)
+		condition, ok2 := t.expr(n.Condition)
+		if ok1 || ok2 {
+			c := *n
+			c.Body, c.Condition = body, condition
+			node = &c
+		}
+	case *ForExpression:
+		variable, ok1 := t.ident(n.Variable)
+		iterable, ok2 := t.expr(n.Iterable)
+		body, ok3 := t.block(n.Body)
+		if ok1 || ok2 || ok3 {
+			c := *n
+			c.Variable, c.Iterable, c.Body = variable, iterable, body
+			node = &c
+		}
+	case *TemplateLiteral:
+		if values, ok := t.exprs(n.Values); ok {
+			c := *n
+			c.Values = values
+			node = &c
+		}
+	case *MatchExpression:
+		subject, changed := t.expr(n.Subject)
+		arms := make([]*MatchArm, len(n.Arms))
+		for i, arm := range n.Arms {
+			arms[i] = Transform(arm, fn).(*MatchArm)
+			changed = changed || arms[i] != arm
+		}
+		if changed {
+			c := *n
+			c.Subject, c.Arms = subject, arms
+			node = &c
+		}
+	case *MatchArm:
+		pattern, ok1 := t.expr(n.Pattern)
+		value, ok2 := t.expr(n.Value)
+		if ok1 || ok2 {
+			c := *n
+			c.Pattern, c.Value = pattern, value
+			node = &c
+		}
+	}
+
+	return fn(node)
+}
+
+// transformer transforms the children of a node, each helper reports
+// whether the result differs from what it was given.
+type transformer struct {
+	fn func(Node) Node
+}
+
+func (t transformer) expr(e Expression) (Expression, bool) {
+	if e == nil {
+		return nil, false
+	}
+	out := Transform(e, t.fn).(Expression)
+	return out, out != e
+}
+
+func (t transformer) ident(i *Identifier) (*Identifier, bool) {
+	if i == nil {
+		return nil, false
+	}
+	out := Transform(i, t.fn).(*Identifier)
+	return out, out != i
+}
+
+func (t transformer) block(b *BlockStatement) (*BlockStatement, bool) {
+	if b == nil {
+		return nil, false
+	}
+	out := Transform(b, t.fn).(*BlockStatement)
+	return out, out != b
+}
+
+// exprs transforms a list of expressions, which may contain nils like the
+// defaults of a function. The list is only copied if an element changed.
+func (t transformer) exprs(es []Expression) ([]Expression, bool) {
+	var out []Expression
+	for i, e := range es {
+		transformed, ok := t.expr(e)
+		if ok && out == nil {
+			out = append([]Expression{}, es...)
+		}
+		if out != nil {
+			out[i] = transformed
+		}
+	}
+	if out == nil {
+		return es, false
+	}
+	return out, true
+}
+
+func (t transformer) stmts(ss []Statement) ([]Statement, bool) {
+	var out []Statement
+	for i, s := range ss {
+		transformed := Transform(s, t.fn).(Statement)
+		if transformed != s && out == nil {
+			out = append([]Statement{}, ss...)
+		}
+		if out != nil {
+			out[i] = transformed
+		}
+	}
+	if out == nil {
+		return ss, false
+	}
+	return out, true
+}
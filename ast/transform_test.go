@@ -0,0 +1,106 @@
+package ast_test
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"testing"
+)
+
+// simplify rewrites x + 0 to x and x * 1 to x.
+func simplify(node ast.Node) ast.Node {
+	infix, ok := node.(*ast.InfixExpression)
+	if !ok {
+		return node
+	}
+	right, ok := infix.Right.(*ast.IntegerLiteral)
+	if ok && (infix.Operator == "+" && right.Value == 0 || infix.Operator == "*" && right.Value == 1) {
+		return infix.Left
+	}
+	return node
+}
+
+func TestTransform(t *testing.T) {
+	p := parser.New(lexer.New(`let f = fn(x, y) { (x + 0) * (y * 1) - z }; f(a + 0, [b]); g(c)`))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	before := program.String()
+
+	transformed := ast.Transform(program, simplify).(*ast.Program)
+
+	expected := "let f = fn(x, y) ((x * y) - z);f(a, [b])g(c)"
+	if transformed.String() != expected {
+		t.Errorf("wrong result.\nexpected=%q\ngot=%q", expected, transformed.String())
+	}
+	if program.String() != before {
+		t.Errorf("original tree was modified. got=%q", program.String())
+	}
+
+	// the parts without rewrites are shared with the original tree
+	if transformed.Statements[2] != program.Statements[2] {
+		t.Errorf("unchanged statement was copied")
+	}
+	oldCall := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	newCall := transformed.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	if newCall == oldCall {
+		t.Fatalf("changed call wasn't copied")
+	}
+	if newCall.Arguments[1] != oldCall.Arguments[1] || newCall.Function != oldCall.Function {
+		t.Errorf("unchanged arguments were copied")
+	}
+	oldFn := program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	newFn := transformed.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	if newFn.Parameters[0] != oldFn.Parameters[0] {
+		t.Errorf("unchanged parameter was copied")
+	}
+}
+
+func TestTransformWithoutChanges(t *testing.T) {
+	p := parser.New(lexer.New(`let [a, {b}] = x; match a { 1 => b, _ => for (i in b) { i } }; h.f(n = 1)`))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	visited := 0
+	identity := func(node ast.Node) ast.Node {
+		visited++
+		return node
+	}
+	if ast.Transform(program, identity) != ast.Node(program) {
+		t.Errorf("tree was rebuilt although nothing changed")
+	}
+
+	walked := 0
+	ast.Walk(program, func(ast.Node) bool {
+		walked++
+		return true
+	})
+	if visited != walked {
+		t.Errorf("fn called %d times, but the tree has %d nodes", visited, walked)
+	}
+}
+
+func TestTransformReplacesLeaves(t *testing.T) {
+	p := parser.New(lexer.New(`let {k, v: w} = h; if (k) { w[k] = v }`))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	// renames every identifier, bindings included
+	rename := func(node ast.Node) ast.Node {
+		if ident, ok := node.(*ast.Identifier); ok {
+			return &ast.Identifier{Token: ident.Token, Value: ident.Value + "2"}
+		}
+		return node
+	}
+	transformed := ast.Transform(program, rename)
+
+	expected := "let {k2, v2: w2} = h2;ifk2 ((w2[k2]) = v2)"
+	if transformed.String() != expected {
+		t.Errorf("wrong result.\nexpected=%q\ngot=%q", expected, transformed.String())
+	}
+}
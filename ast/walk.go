@@ -0,0 +1,133 @@
+package ast
+
+// Walk traverses the tree rooted at node in depth-first order. It calls fn for
+// each node before visiting its children, and skips the children when fn
+// returns false.
+func Walk(node Node, fn func(Node) bool) {
+	if isNil(node) || !fn(node) {
+		return
+	}
+	for _, child := range Children(node) {
+		Walk(child, fn)
+	}
+}
+
+// Children returns the direct children of node in source order. Missing (nil)
+// children, as left behind by parse errors or an if without else, are left out.
+func Children(node Node) []Node {
+	children := []Node{}
+	add := func(nodes ...Node) {
+		for _, n := range nodes {
+			if !isNil(n) {
+				children = append(children, n)
+			}
+		}
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		for _, s := range n.Statements {
+			add(s)
+		}
+	case *LetStatement:
+		add(n.Name, n.Pattern, n.Value)
+	case *ArrayPattern:
+		for _, el := range n.Elements {
+			add(el)
+		}
+	case *HashPattern:
+		for i, key := range n.Keys {
+			if n.Values[i] == Node(key) {
+				add(key)
+			} else {
+				add(key, n.Values[i])
+			}
+		}
+	case *ReturnStatement:
+		add(n.ReturnValue)
+	case *ExpressionStatement:
+		add(n.Expression)
+	case *BlockStatement:
+		for _, s := range n.Statements {
+			add(s)
+		}
+	case *PrefixExpression:
+		add(n.Right)
+	case *InfixExpression:
+		add(n.Left, n.Right)
+	case *IfExpression:
+		add(n.Condition, n.Consequence, n.Alternative)
+	case *BlockExpression:
+		add(n.Block)
+	case *FunctionLiteral:
+		for i, p := range n.Parameters {
+			add(p)
+			if i < len(n.Defaults) {
+				add(n.Defaults[i])
+			}
+		}
+		add(n.Body)
+	case *CallExpression:
+		add(n.Function)
+		for _, a := range n.Arguments {
+			add(a)
+		}
+	case *NamedArgument:
+		add(n.Name, n.Value)
+	case *SpreadElement:
+		add(n.Value)
+	case *GroupedExpression:
+		add(n.Expression)
+	case *PipeExpression:
+		add(n.Left, n.Right)
+	case *ArrayLiteral:
+		for _, e := range n.Elements {
+			add(e)
+		}
+	case *IndexExpression:
+		add(n.Left, n.Index)
+	case *DotExpression:
+		add(n.Left, n.Name)
+	case *OptionalIndexExpression:
+		add(n.Left, n.Index)
+	case *HashLiteral:
+		for _, pair := range n.Pairs {
+			add(pair.Key, pair.Value)
+		}
+	case *AssignExpression:
+		add(n.Name, n.Index, n.Value)
+	case *WhileExpression:
+		add(n.Condition, n.Body)
+	case *DoWhileExpression:
+		add(n.Body, n.Condition)
+	case *ForExpression:
+		add(n.Variable, n.Iterable, n.Body)
+	case *TemplateLiteral:
+		for _, v := range n.Values {
+			add(v)
+		}
+	case *MatchExpression:
+		add(n.Subject)
+		for _, arm := range n.Arms {
+			add(arm)
+		}
+	case *MatchArm:
+		add(n.Pattern, n.Value)
+	}
+	return children
+}
+
+// isNil reports whether node is nil, including a nil pointer stored in the interface.
+func isNil(node Node) bool {
+	switch n := node.(type) {
+	case nil:
+		return true
+	case *BlockStatement:
+		return n == nil
+	case *Identifier:
+		return n == nil
+	case *IndexExpression:
+		return n == nil
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package ast_test
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"testing"
+)
+
+func TestWalkOrder(t *testing.T) {
+	p := parser.New(lexer.New(`let f = fn(x) { if (x) { x + 1 } }; f([2])`))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	var visited []string
+	ast.Walk(program, func(n ast.Node) bool {
+		visited = append(visited, fmt.Sprintf("%T", n))
+		return true
+	})
+
+	expected := []string{
+		"*ast.Program",
+		"*ast.LetStatement", "*ast.Identifier",
+		"*ast.FunctionLiteral", "*ast.Identifier", "*ast.BlockStatement",
+		"*ast.ExpressionStatement", "*ast.IfExpression", "*ast.Identifier", "*ast.BlockStatement",
+		"*ast.ExpressionStatement", "*ast.InfixExpression", "*ast.Identifier", "*ast.IntegerLiteral",
+		"*ast.ExpressionStatement", "*ast.CallExpression", "*ast.Identifier",
+		"*ast.ArrayLiteral", "*ast.IntegerLiteral",
+	}
+	if fmt.Sprint(visited) != fmt.Sprint(expected) {
+		t.Errorf("wrong order.\nexpected=%v\ngot=%v", expected, visited)
+	}
+}
+
+func TestWalkSkipsChildren(t *testing.T) {
+	p := parser.New(lexer.New(`fn(x) { x }; 1 + 2`))
+	program := p.ParseProgram()
+
+	count := 0
+	ast.Walk(program, func(n ast.Node) bool {
+		count++
+		_, isFunction := n.(*ast.FunctionLiteral)
+		return !isFunction
+	})
+
+	// Program, 2 ExpressionStatements, FunctionLiteral, InfixExpression and its 2 operands
+	if count != 7 {
+		t.Errorf("wrong number of visited nodes. expected=7, got=%d", count)
+	}
+}
@@ -0,0 +1,120 @@
+package code
+
+import "testing"
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		op       Opcode
+		operands []int
+		expected []byte
+	}{
+		{OpConstant, []int{65534}, []byte{byte(OpConstant), 255, 254}},
+		{OpAdd, []int{}, []byte{byte(OpAdd)}},
+		{opTestPair, []int{65535, 255}, []byte{byte(opTestPair), 255, 255, 255}},
+	}
+
+	defineTestPair(t)
+
+	for i, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+
+		if len(instruction) != len(tt.expected) {
+			t.Fatalf("tests[%d] - instruction has wrong length. want=%d, got=%d", i, len(tt.expected), len(instruction))
+		}
+		for j, b := range tt.expected {
+			if instruction[j] != b {
+				t.Errorf("tests[%d] - wrong byte at pos %d. want=%d, got=%d", i, j, b, instruction[j])
+			}
+		}
+	}
+}
+
+// opTestPair is an opcode with two operands of different widths, which no
+// real instruction has yet. Tests that use it call defineTestPair first.
+const opTestPair Opcode = 255
+
+func defineTestPair(t *testing.T) {
+	definitions[opTestPair] = &Definition{"OpTestPair", []int{2, 1}}
+	t.Cleanup(func() { delete(definitions, opTestPair) })
+}
+
+func TestDisassemble(t *testing.T) {
+	defineTestPair(t)
+
+	instructions := []Instructions{
+		Make(OpConstant, 1),
+		Make(OpAdd),
+		Make(OpConstant, 65535),
+		Make(OpJumpNotTruthy, 3),
+		Make(opTestPair, 513, 7),
+		Make(OpPop),
+	}
+
+	expected := `0000 OpConstant 1
+0003 OpAdd
+0004 OpConstant 65535
+0007 OpJumpNotTruthy 3
+0010 OpTestPair 513 7
+0014 OpPop
+`
+
+	concatted := Instructions{}
+	for _, ins := range instructions {
+		concatted = append(concatted, ins...)
+	}
+
+	if got := Disassemble(concatted); got != expected {
+		t.Errorf("instructions wrongly disassembled.\nwant=%q\ngot =%q", expected, got)
+	}
+	if concatted.String() != expected {
+		t.Errorf("String doesn't match Disassemble. got=%q", concatted.String())
+	}
+}
+
+func TestDisassembleBrokenInstructions(t *testing.T) {
+	tests := []struct {
+		ins      Instructions
+		expected string
+	}{
+		{Instructions{200, byte(OpPop)}, "0000 ERROR: opcode 200 undefined\n0001 OpPop\n"},
+		{Instructions{byte(OpAdd), byte(OpConstant), 1}, "0000 OpAdd\n0001 ERROR: OpConstant is missing operands\n"},
+	}
+
+	for i, tt := range tests {
+		if got := Disassemble(tt.ins); got != tt.expected {
+			t.Errorf("tests[%d] - wrong disassembly.\nwant=%q\ngot =%q", i, tt.expected, got)
+		}
+	}
+}
+
+func TestReadOperands(t *testing.T) {
+	defineTestPair(t)
+
+	tests := []struct {
+		op        Opcode
+		operands  []int
+		bytesRead int
+	}{
+		{OpConstant, []int{65535}, 2},
+		{opTestPair, []int{65535, 255}, 3},
+	}
+
+	for i, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+
+		def, err := Lookup(byte(tt.op))
+		if err != nil {
+			t.Fatalf("tests[%d] - definition not found: %q", i, err)
+		}
+
+		operandsRead, n := ReadOperands(def, instruction[1:])
+		if n != tt.bytesRead {
+			t.Fatalf("tests[%d] - n wrong. want=%d, got=%d", i, tt.bytesRead, n)
+		}
+		for j, want := range tt.operands {
+			if operandsRead[j] != want {
+				t.Errorf("tests[%d] - operand %d wrong. want=%d, got=%d", i, j, want, operandsRead[j])
+			}
+		}
+	}
+}
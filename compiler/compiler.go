@@ -0,0 +1,230 @@
+// Package compiler turns the AST into bytecode for the vm.
+package compiler
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/code"
+	"monkey/object"
+)
+
+// Bytecode is what the compiler hands to the vm: the instructions and the
+// constants they refer to by index.
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+}
+
+// Compile compiles p. Constructs the compiler doesn't support yet make it
+// return an error.
+func Compile(p *ast.Program) (*Bytecode, error) {
+	c := &Compiler{symbolTable: NewSymbolTable()}
+	if err := c.compile(p); err != nil {
+		return nil, err
+	}
+	return &Bytecode{Instructions: c.instructions, Constants: c.constants}, nil
+}
+
+// Compiler holds the instructions and constants emitted so far.
+type Compiler struct {
+	instructions code.Instructions
+	constants    []object.Object
+	symbolTable  *SymbolTable
+
+	// the last two instructions emitted, so the OpPop ending a branch of
+	// an if can be taken back
+	lastInstruction     emittedInstruction
+	previousInstruction emittedInstruction
+}
+
+type emittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+func (c *Compiler) compile(node ast.Node) error {
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			if err := c.compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.ExpressionStatement:
+		if err := c.compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			if err := c.compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.LetStatement:
+		if node.Pattern != nil {
+			return fmt.Errorf("compiling %T is not supported", node.Pattern)
+		}
+		if err := c.compile(node.Value); err != nil {
+			return err
+		}
+		symbol := c.symbolTable.Define(node.Name.Value)
+		c.emit(code.OpSetGlobal, symbol.Index)
+
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(node.Value)
+		if !ok {
+			return fmt.Errorf("undefined variable %s", node.Value)
+		}
+		c.emit(code.OpGetGlobal, symbol.Index)
+
+	case *ast.IfExpression:
+		return c.compileIf(node)
+
+	case *ast.InfixExpression:
+		// a < b is compiled as b > a, so there is a single comparison opcode
+		if node.Operator == "<" {
+			if err := c.compile(node.Right); err != nil {
+				return err
+			}
+			if err := c.compile(node.Left); err != nil {
+				return err
+			}
+			c.emit(code.OpGreaterThan)
+			return nil
+		}
+
+		if err := c.compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.compile(node.Right); err != nil {
+			return err
+		}
+
+		switch node.Operator {
+		case "+":
+			c.emit(code.OpAdd)
+		case "-":
+			c.emit(code.OpSub)
+		case "*":
+			c.emit(code.OpMul)
+		case "/":
+			c.emit(code.OpDiv)
+		case ">":
+			c.emit(code.OpGreaterThan)
+		case "==":
+			c.emit(code.OpEqual)
+		case "!=":
+			c.emit(code.OpNotEqual)
+		default:
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+
+	case *ast.PrefixExpression:
+		if err := c.compile(node.Right); err != nil {
+			return err
+		}
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		default:
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+
+	case *ast.IntegerLiteral:
+		integer := object.NewInteger(node.Value)
+		c.emit(code.OpConstant, c.addConstant(integer))
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	default:
+		return fmt.Errorf("compiling %T is not supported", node)
+	}
+
+	return nil
+}
+
+// compileIf compiles an if so that it leaves the value of the branch taken on
+// the stack, null if there is no else and the condition doesn't hold:
+//
+//	<condition>
+//	OpJumpNotTruthy else
+//	<consequence>
+//	OpJump end
+//	else: <alternative> or OpNull
+//	end:
+func (c *Compiler) compileIf(node *ast.IfExpression) error {
+	if err := c.compile(node.Condition); err != nil {
+		return err
+	}
+	// the real positions are filled in once they are known
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.compileBranch(node.Consequence); err != nil {
+		return err
+	}
+	jumpPos := c.emit(code.OpJump, 9999)
+	c.changeOperand(jumpNotTruthyPos, len(c.instructions))
+
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else if err := c.compileBranch(node.Alternative); err != nil {
+		return err
+	}
+	c.changeOperand(jumpPos, len(c.instructions))
+	return nil
+}
+
+// compileBranch compiles a branch of an if so that it leaves its value on
+// the stack instead of popping it.
+func (c *Compiler) compileBranch(block *ast.BlockStatement) error {
+	if err := c.compile(block); err != nil {
+		return err
+	}
+	if c.lastInstruction.Opcode == code.OpPop && len(block.Statements) > 0 {
+		c.removeLastPop()
+	} else {
+		// a branch ending in a let, or an empty one, has no value
+		c.emit(code.OpNull)
+	}
+	return nil
+}
+
+func (c *Compiler) removeLastPop() {
+	c.instructions = c.instructions[:c.lastInstruction.Position]
+	c.lastInstruction = c.previousInstruction
+}
+
+// changeOperand replaces the operand of the instruction at pos. The new
+// instruction has to have the same width as the old one.
+func (c *Compiler) changeOperand(pos int, operand int) {
+	op := code.Opcode(c.instructions[pos])
+	copy(c.instructions[pos:], code.Make(op, operand))
+}
+
+// addConstant adds obj to the constant pool and returns its index.
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+// emit appends an instruction and returns its position.
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	pos := len(c.instructions)
+	c.instructions = append(c.instructions, ins...)
+
+	c.previousInstruction = c.lastInstruction
+	c.lastInstruction = emittedInstruction{Opcode: op, Position: pos}
+	return pos
+}
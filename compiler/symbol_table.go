@@ -0,0 +1,40 @@
+package compiler
+
+// SymbolScope tells where the value of a symbol is stored.
+type SymbolScope string
+
+const (
+	GlobalScope SymbolScope = "GLOBAL"
+)
+
+// Symbol is a name the compiler has seen bound, with where its value lives.
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+}
+
+// SymbolTable keeps the symbols defined so far, so identifiers can be
+// resolved to the index of their value at compile time.
+type SymbolTable struct {
+	store          map[string]Symbol
+	numDefinitions int
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{store: make(map[string]Symbol)}
+}
+
+// Define binds name to the next free index. Defining a name again gives it a
+// new index, like a new let binding in the evaluator.
+func (s *SymbolTable) Define(name string) Symbol {
+	symbol := Symbol{Name: name, Scope: GlobalScope, Index: s.numDefinitions}
+	s.store[name] = symbol
+	s.numDefinitions++
+	return symbol
+}
+
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	symbol, ok := s.store[name]
+	return symbol, ok
+}
@@ -0,0 +1,19 @@
+// Package diag holds the diagnostics the static checks report.
+package diag
+
+import (
+	"fmt"
+	"monkey/token"
+)
+
+// Diagnostic is a problem found in the source, at Pos.
+type Diagnostic struct {
+	Pos     token.Position
+	Message string
+}
+
+// String writes the diagnostic as "line:column: message", the way the parser
+// writes its warnings.
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s", d.Pos.Line, d.Pos.Column, d.Message)
+}
@@ -0,0 +1,1433 @@
+package evaluator
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"monkey/object"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// input is the stream readLine reads from. It defaults to stdin,
+// hosts like the REPL replace it with SetInput.
+var input = bufio.NewReader(os.Stdin)
+
+// SetInput makes r the stream readLine reads from.
+func SetInput(r io.Reader) {
+	input = bufio.NewReader(r)
+}
+
+// FileSystem is what readFile and writeFile access files through.
+type FileSystem interface {
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte) error
+}
+
+// osFileSystem is the FileSystem of the operating system.
+type osFileSystem struct{}
+
+func (osFileSystem) ReadFile(name string) ([]byte, error) { return os.ReadFile(name) }
+func (osFileSystem) WriteFile(name string, data []byte) error {
+	return os.WriteFile(name, data, 0o644)
+}
+
+// files is the file system of readFile and writeFile. It defaults to the
+// operating system's, hosts can sandbox programs with SetFileSystem.
+var files FileSystem = osFileSystem{}
+
+// SetFileSystem makes fs the file system readFile and writeFile use.
+func SetFileSystem(fs FileSystem) {
+	files = fs
+}
+
+// hostBuiltins are the native functions registered by the host program
+// with RegisterBuiltin.
+var hostBuiltins = map[string]*object.Builtin{}
+
+// RegisterBuiltin makes fn callable from Monkey programs under name, so a host
+// embedding the interpreter can expose its own Go functions. It has to be
+// called before the programs using it are evaluated.
+//
+// Core builtins like len can't be replaced, registering one of their names is
+// rejected with an error. Registering a name a second time replaces the
+// earlier host function.
+func RegisterBuiltin(name string, fn object.BuiltinFunction) error {
+	if _, ok := builtins[name]; ok {
+		return fmt.Errorf("cannot register builtin %q: name is taken by a core builtin", name)
+	}
+	hostBuiltins[name] = &object.Builtin{Fn: fn}
+	return nil
+}
+
+// disabled holds the names of the builtins turned off with DisableBuiltins.
+var disabled = map[string]bool{}
+
+// DisableBuiltins turns off the builtins with the given names, so a host can
+// run untrusted programs without, say, readFile and writeFile. Calling a
+// disabled builtin is an error. Names that aren't builtins are ignored.
+func DisableBuiltins(names ...string) {
+	for _, name := range names {
+		disabled[name] = true
+	}
+}
+
+// identities holds the ids handed out by idOf. Objects get one the first
+// time they are seen, and since they stay in the map they are never
+// collected, so their ids are never reused. That is a known leak: every
+// object passed to idOf is kept alive for the rest of the run, so a program
+// asking for the ids of many short-lived objects keeps growing.
+var identities = map[object.Object]int{}
+
+// lookupBuiltin finds the builtin bound to name, core builtins first.
+func lookupBuiltin(name string) (*object.Builtin, bool) {
+	builtin, ok := builtins[name]
+	if !ok {
+		builtin, ok = hostBuiltins[name]
+	}
+	if ok && disabled[name] {
+		return disabledBuiltin(name), true
+	}
+	return builtin, ok
+}
+
+// disabledBuiltin stands in for the disabled builtin name, calling it is
+// an error.
+func disabledBuiltin(name string) *object.Builtin {
+	return &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		return newError("builtin `%s` is disabled", name)
+	}}
+}
+
+// formatString fills the {} placeholders of f with the values, which have to
+// match them in number.
+func formatString(f string, values []object.Object) object.Object {
+	var out strings.Builder
+	used := 0
+	for i := 0; i < len(f); i++ {
+		switch {
+		case strings.HasPrefix(f[i:], "{{"), strings.HasPrefix(f[i:], "}}"):
+			out.WriteByte(f[i])
+			i++
+		case strings.HasPrefix(f[i:], "{}"):
+			if used < len(values) {
+				out.WriteString(values[used].Inspect())
+			}
+			used++
+			i++
+		default:
+			out.WriteByte(f[i])
+		}
+	}
+	if used != len(values) {
+		return newError("wrong number of values for `format`. got=%d, want=%d", len(values), used)
+	}
+	return &object.String{Value: out.String()}
+}
+
+// sliceBounds resolves the start and optional end index given to slice
+// against a sequence of length elements.
+func sliceBounds(bounds []int64, length int) (start, end int) {
+	clamp := func(i int64) int {
+		if i < 0 {
+			i += int64(length)
+		}
+		return int(max(0, min(i, int64(length))))
+	}
+	start, end = clamp(bounds[0]), length
+	if len(bounds) > 1 {
+		end = clamp(bounds[1])
+	}
+	return start, max(start, end)
+}
+
+// less orders two numbers or two strings for sort.
+func less(a, b object.Object) bool {
+	switch a := a.(type) {
+	case *object.String:
+		return a.Value < b.(*object.String).Value
+	case *object.Integer:
+		if b, ok := b.(*object.Integer); ok {
+			return a.Value < b.Value
+		}
+	}
+	return toFloat(a) < toFloat(b)
+}
+
+// builtinArity is how many arguments each builtin takes, from the first
+// number to the second. A second number of -1 means any number from the
+// first on.
+var builtinArity = map[string][2]int{
+	"len": {1, 1}, "byteLen": {1, 1}, "first": {1, 1}, "last": {1, 1}, "rest": {1, 1},
+	"push": {2, 2}, "concat": {1, -1}, "zip": {1, -1}, "reverse": {1, 1}, "slice": {2, 3},
+	"sort": {1, 1}, "enumerate": {1, 2}, "chunk": {2, 2}, "flatten": {1, 2}, "merge": {1, -1},
+	"has": {2, 2}, "deepEqual": {2, 2}, "approxEqual": {2, 3}, "expectType": {2, 2},
+	"range": {1, 3}, "toArray": {1, 1}, "clone": {1, 1}, "idOf": {1, 1}, "arity": {1, 1}, "params": {1, 1}, "entries": {1, 1}, "sortedPairs": {1, 1}, "fromEntries": {1, 1}, "frequencies": {1, 1},
+	"pqNew": {0, 0}, "pqPush": {3, 3}, "pqPop": {1, 1},
+	"upper": {1, 1}, "lower": {1, 1}, "trim": {1, 1}, "replace": {3, 3}, "parseInt": {1, 2},
+	"format": {1, -1}, "toJSON": {1, 2}, "throw": {1, 1}, "puts": {0, -1}, "readLine": {0, 0},
+	"readFile": {1, 1}, "writeFile": {2, 2},
+	"compose": {2, 2}, "apply": {2, 2}, "partial": {2, -1}, "groupBy": {2, 2},
+	"repeat": {2, 2}, "try": {2, 2}, "count": {2, 2}, "maxBy": {2, 2}, "minBy": {2, 2},
+}
+
+// BuiltinArity returns the least and the most number of arguments the
+// builtin called name takes, with a max of -1 if there is no limit. It
+// returns false for names that aren't builtins. Builtins registered by the
+// host aren't known, since their functions check their own arguments.
+func BuiltinArity(name string) (min, max int, ok bool) {
+	arity, ok := builtinArity[name]
+	return arity[0], arity[1], ok
+}
+
+// valueTypes are the type names a script can see its values take.
+var valueTypes = map[object.ObjectType]bool{
+	object.INTEGER_OBJ:        true,
+	object.FLOAT_OBJ:          true,
+	object.BOOLEAN_OBJ:        true,
+	object.NULL_OBJ:           true,
+	object.STRING_OBJ:         true,
+	object.ARRAY_OBJ:          true,
+	object.HASH_OBJ:           true,
+	object.FUNCTION_OBJ:       true,
+	object.BUILTIN_OBJ:        true,
+	object.RANGE_OBJ:          true,
+	object.PRIORITY_QUEUE_OBJ: true,
+}
+
+// builtins are the functions implemented in Go that every program can call.
+// They are looked up after the environment, so a let binding can shadow them.
+var builtins = map[string]*object.Builtin{
+	// len of a string counts its characters, byteLen its UTF-8 bytes
+	"len": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			switch arg := args[0].(type) {
+			case *object.String:
+				return NativeInt(int64(arg.Len()))
+			case *object.Array:
+				return NativeInt(int64(len(arg.Elements)))
+			case *object.Range:
+				return NativeInt(arg.Len())
+			default:
+				return newError("argument to `len` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	"byteLen": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			str, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `byteLen` must be STRING, got %s", args[0].Type())
+			}
+			return NativeInt(int64(len(str.Value)))
+		},
+	},
+	"first": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
+			}
+			arr := args[0].(*object.Array)
+			if len(arr.Elements) > 0 {
+				return arr.Elements[0]
+			}
+			return NULL
+		},
+	},
+	"last": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("argument to `last` must be ARRAY, got %s", args[0].Type())
+			}
+			arr := args[0].(*object.Array)
+			length := len(arr.Elements)
+			if length > 0 {
+				return arr.Elements[length-1]
+			}
+			return NULL
+		},
+	},
+	// rest returns a new array with every element but the first
+	"rest": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("argument to `rest` must be ARRAY, got %s", args[0].Type())
+			}
+			arr := args[0].(*object.Array)
+			length := len(arr.Elements)
+			if length > 0 {
+				newElements := make([]object.Object, length-1)
+				copy(newElements, arr.Elements[1:length])
+				return &object.Array{Elements: newElements}
+			}
+			return NULL
+		},
+	},
+	// push returns a new array with the element appended,
+	// the original array is left untouched
+	"push": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("argument to `push` must be ARRAY, got %s", args[0].Type())
+			}
+			arr := args[0].(*object.Array)
+			length := len(arr.Elements)
+
+			newElements := make([]object.Object, length+1)
+			copy(newElements, arr.Elements)
+			newElements[length] = args[1]
+			return &object.Array{Elements: newElements}
+		},
+	},
+	// concat returns a new array with the elements of all its array arguments
+	"concat": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) == 0 {
+				return newError("wrong number of arguments. got=0, want at least 1")
+			}
+			elements := []object.Object{}
+			for _, arg := range args {
+				arr, ok := arg.(*object.Array)
+				if !ok {
+					return newError("argument to `concat` must be ARRAY, got %s", arg.Type())
+				}
+				elements = append(elements, arr.Elements...)
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	// zip pairs up the elements of its array arguments by position: the i-th
+	// element of the result is an array of the i-th elements of all the
+	// arguments. It is as long as the shortest argument.
+	"zip": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) == 0 {
+				return newError("wrong number of arguments. got=0, want at least 1")
+			}
+			arrays := make([]*object.Array, len(args))
+			length := -1
+			for i, arg := range args {
+				arr, ok := arg.(*object.Array)
+				if !ok {
+					return newError("argument to `zip` must be ARRAY, got %s", arg.Type())
+				}
+				arrays[i] = arr
+				if length < 0 || len(arr.Elements) < length {
+					length = len(arr.Elements)
+				}
+			}
+
+			tuples := make([]object.Object, length)
+			for i := range tuples {
+				tuple := make([]object.Object, len(arrays))
+				for j, arr := range arrays {
+					tuple[j] = arr.Elements[i]
+				}
+				tuples[i] = &object.Array{Elements: tuple}
+			}
+			return &object.Array{Elements: tuples}
+		},
+	},
+	// reverse returns a new array or string in reverse order. Strings are
+	// reversed by character, so multibyte characters stay intact.
+	"reverse": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			switch arg := args[0].(type) {
+			case *object.Array:
+				length := len(arg.Elements)
+				elements := make([]object.Object, length)
+				for i, el := range arg.Elements {
+					elements[length-1-i] = el
+				}
+				return &object.Array{Elements: elements}
+			case *object.String:
+				runes := []rune(arg.Value)
+				for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+					runes[i], runes[j] = runes[j], runes[i]
+				}
+				return &object.String{Value: string(runes)}
+			default:
+				return newError("argument to `reverse` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	// slice(x, start, end) returns a new array or string with the elements or
+	// characters of x from start up to, but not including, end. Without end
+	// the slice goes on to the end of x. Like in Python, a negative index
+	// counts from the end, indices outside of x are clamped to it, and a
+	// start at or after end gives an empty slice.
+	"slice": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 2 || len(args) > 3 {
+				return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+			}
+			bounds := make([]int64, len(args)-1)
+			for i, arg := range args[1:] {
+				n, ok := arg.(*object.Integer)
+				if !ok {
+					return newError("indices of `slice` must be INTEGER, got %s", arg.Type())
+				}
+				bounds[i] = n.Value
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Array:
+				start, end := sliceBounds(bounds, len(arg.Elements))
+				elements := make([]object.Object, end-start)
+				copy(elements, arg.Elements[start:end])
+				return &object.Array{Elements: elements}
+			case *object.String:
+				runes := []rune(arg.Value)
+				start, end := sliceBounds(bounds, len(runes))
+				return &object.String{Value: string(runes[start:end])}
+			default:
+				return newError("argument to `slice` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	// sort returns a new array with the elements in ascending order. They
+	// have to be all numbers or all strings.
+	"sort": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument to `sort` must be ARRAY, got %s", args[0].Type())
+			}
+			for _, el := range arr.Elements {
+				if !isNumber(el) && el.Type() != object.STRING_OBJ {
+					return newError("`sort` can only sort numbers and strings, got %s", el.Type())
+				}
+				if isNumber(el) != isNumber(arr.Elements[0]) {
+					return newError("`sort` can't compare %s and %s", arr.Elements[0].Type(), el.Type())
+				}
+			}
+
+			elements := make([]object.Object, len(arr.Elements))
+			copy(elements, arr.Elements)
+			sort.SliceStable(elements, func(i, j int) bool {
+				return less(elements[i], elements[j])
+			})
+			return &object.Array{Elements: elements}
+		},
+	},
+	// enumerate returns the elements of an array paired with their index,
+	// as [index, element] arrays. The first index is 0 unless given.
+	"enumerate": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("first argument to `enumerate` must be ARRAY, got %s", args[0].Type())
+			}
+			start := int64(0)
+			if len(args) == 2 {
+				n, ok := args[1].(*object.Integer)
+				if !ok {
+					return newError("second argument to `enumerate` must be INTEGER, got %s", args[1].Type())
+				}
+				start = n.Value
+			}
+
+			pairs := make([]object.Object, len(arr.Elements))
+			for i, el := range arr.Elements {
+				index := NativeInt(start + int64(i))
+				pairs[i] = &object.Array{Elements: []object.Object{index, el}}
+			}
+			return &object.Array{Elements: pairs}
+		},
+	},
+	// chunk(arr, size) splits arr into arrays of size elements, in order.
+	// The last one is shorter when size doesn't divide the length of arr.
+	"chunk": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("first argument to `chunk` must be ARRAY, got %s", args[0].Type())
+			}
+			size, ok := args[1].(*object.Integer)
+			if !ok {
+				return newError("second argument to `chunk` must be INTEGER, got %s", args[1].Type())
+			}
+			if size.Value < 1 {
+				return newError("size of `chunk` must be positive, got %d", size.Value)
+			}
+
+			chunks := []object.Object{}
+			for start := 0; start < len(arr.Elements); start += int(size.Value) {
+				end := len(arr.Elements)
+				if size.Value < int64(end-start) {
+					end = start + int(size.Value)
+				}
+				elements := append([]object.Object{}, arr.Elements[start:end]...)
+				chunks = append(chunks, &object.Array{Elements: elements})
+			}
+			return &object.Array{Elements: chunks}
+		},
+	},
+	// flatten(arr, depth) replaces the arrays in arr by their elements, and
+	// the arrays in those by theirs, depth levels deep. Without a depth it
+	// flattens all the way down.
+	"flatten": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("first argument to `flatten` must be ARRAY, got %s", args[0].Type())
+			}
+			depth := int64(-1)
+			if len(args) == 2 {
+				n, ok := args[1].(*object.Integer)
+				if !ok {
+					return newError("second argument to `flatten` must be INTEGER, got %s", args[1].Type())
+				}
+				if n.Value < 0 {
+					return newError("depth of `flatten` must not be negative, got %d", n.Value)
+				}
+				depth = n.Value
+			}
+			return &object.Array{Elements: flatten([]object.Object{}, arr.Elements, depth)}
+		},
+	},
+	// merge returns a new hash with the pairs of all its hash arguments.
+	// When a key occurs more than once, the rightmost hash wins.
+	"merge": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) == 0 {
+				return newError("wrong number of arguments. got=0, want at least 1")
+			}
+			pairs := make(map[object.HashKey]object.HashPair)
+			for _, arg := range args {
+				hash, ok := arg.(*object.Hash)
+				if !ok {
+					return newError("argument to `merge` must be HASH, got %s", arg.Type())
+				}
+				for key, pair := range hash.Pairs {
+					pairs[key] = pair
+				}
+			}
+			return &object.Hash{Pairs: pairs}
+		},
+	},
+	// deepEqual(a, b) reports whether a and b are the same value, comparing
+	// arrays and hashes element by element, where == only tells whether they
+	// are the very same array or hash. Functions are only equal to
+	// themselves, numbers of different types are never equal, and NaN is
+	// equal to NaN, unlike with ==.
+	"deepEqual": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			return nativeBoolToBooleanObject(object.DeepEqual(args[0], args[1]))
+		},
+	},
+	// has(hash, key) reports whether hash has a pair for key, and
+	// has(arr, value) whether an element of arr equals value.
+	"has": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			switch receiver := args[0].(type) {
+			case *object.Hash:
+				key, ok := args[1].(object.Hashable)
+				if !ok {
+					return newError("unusable as hash key: %s", args[1].Type())
+				}
+				_, ok = receiver.Pairs[key.HashKey()]
+				return nativeBoolToBooleanObject(ok)
+			case *object.Array:
+				for _, el := range receiver.Elements {
+					if object.Equals(el, args[1]) {
+						return TRUE
+					}
+				}
+				return FALSE
+			default:
+				return newError("first argument to `has` must be HASH or ARRAY, got %s", args[0].Type())
+			}
+		},
+	},
+	// approxEqual(a, b, epsilon) reports whether the numbers a and b differ
+	// by at most epsilon, which is 1e-9 when left out.
+	"approxEqual": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 2 || len(args) > 3 {
+				return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+			}
+			for _, arg := range args {
+				if !isNumber(arg) {
+					return newError("argument to `approxEqual` must be INTEGER or FLOAT, got %s", arg.Type())
+				}
+			}
+			epsilon := 1e-9
+			if len(args) == 3 {
+				epsilon = toFloat(args[2])
+			}
+			return nativeBoolToBooleanObject(math.Abs(toFloat(args[0])-toFloat(args[1])) <= epsilon)
+		},
+	},
+	// expectType(x, name) returns x when its type is called name, such as
+	// "INTEGER" or "HASH", and an error otherwise.
+	"expectType": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			name, ok := args[1].(*object.String)
+			if !ok {
+				return newError("second argument to `expectType` must be STRING, got %s", args[1].Type())
+			}
+			if !valueTypes[object.ObjectType(name.Value)] {
+				return newError("unknown type name %q", name.Value)
+			}
+			if args[0].Type() != object.ObjectType(name.Value) {
+				return newError("expected %s, got %s", name.Value, args[0].Type())
+			}
+			return args[0]
+		},
+	},
+	// range(stop), range(start, stop) and range(start, stop, step) return the
+	// numbers from start, 0 by default, up to but not including stop, step
+	// apart, 1 by default. The range is lazy: for-in computes one number at
+	// a time, use toArray to get them all at once. Any float argument makes
+	// it a range of floats.
+	"range": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 3 {
+				return newError("wrong number of arguments. got=%d, want=1 to 3", len(args))
+			}
+			for _, arg := range args {
+				if !isNumber(arg) {
+					return newError("argument to `range` must be INTEGER or FLOAT, got %s", arg.Type())
+				}
+			}
+
+			r := &object.Range{Start: NativeInt(0), Step: NativeInt(1)}
+			switch len(args) {
+			case 1:
+				r.Stop = args[0]
+			case 2:
+				r.Start, r.Stop = args[0], args[1]
+			case 3:
+				r.Start, r.Stop, r.Step = args[0], args[1], args[2]
+			}
+			if toFloat(r.Step) == 0 {
+				return newError("step of `range` must not be zero")
+			}
+			return r
+		},
+	},
+	// toArray returns the elements of a range as an array
+	"toArray": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			r, ok := args[0].(*object.Range)
+			if !ok {
+				return newError("argument to `toArray` must be RANGE, got %s", args[0].Type())
+			}
+			elements := make([]object.Object, r.Len())
+			for i := range elements {
+				elements[i] = r.At(int64(i))
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	// clone returns a deep copy of an array or hash, so assigning to an
+	// element of the copy, however deeply nested, leaves the original alone.
+	// Anything else is returned as it is: integers, strings and the other
+	// immutable values don't need copying, and functions and priority queues
+	// are shared with the original rather than copied.
+	"clone": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			return deepCopy(args[0], map[object.Object]object.Object{})
+		},
+	},
+	// idOf returns a string identifying its argument. Integers, floats,
+	// strings, booleans and null are identified by type and value, so equal
+	// ones share an id. Anything else, like an array or a function, gets an
+	// id of its own the first time it is asked for, which stays the same
+	// for as long as the program runs.
+	"idOf": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			switch arg := args[0].(type) {
+			case *object.Integer, *object.Boolean, *object.Null:
+				return &object.String{Value: fmt.Sprintf("%s:%s", arg.Type(), arg.Inspect())}
+			case *object.Float:
+				// Inspect rounds, the shortest exact form keeps unequal
+				// floats apart. -0 equals 0, so it gets the same id.
+				value := arg.Value
+				if value == 0 {
+					value = 0
+				}
+				return &object.String{Value: fmt.Sprintf("%s:%s", arg.Type(), strconv.FormatFloat(value, 'g', -1, 64))}
+			case *object.String:
+				return &object.String{Value: fmt.Sprintf("%s:%s", arg.Type(), arg.Value)}
+			}
+			id, ok := identities[args[0]]
+			if !ok {
+				id = len(identities) + 1
+				identities[args[0]] = id
+			}
+			return &object.String{Value: fmt.Sprintf("%s#%d", args[0].Type(), id)}
+		},
+	},
+	// params returns the parameter names of a function in order. Builtins
+	// have no names to report.
+	"params": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			switch fn := args[0].(type) {
+			case *object.Function:
+				names := make([]object.Object, len(fn.Parameters))
+				for i, param := range fn.Parameters {
+					names[i] = &object.String{Value: param.Value}
+				}
+				return &object.Array{Elements: names}
+			case *object.Builtin:
+				return newError("`params` of a builtin function are unknown")
+			default:
+				return newError("argument to `params` must be FUNCTION, got %s", args[0].Type())
+			}
+		},
+	},
+	// entries returns the pairs of a hash as [key, value] arrays, sorted by
+	// the printed form of the key so the order is stable.
+	"entries": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			hash, ok := args[0].(*object.Hash)
+			if !ok {
+				return newError("argument to `entries` must be HASH, got %s", args[0].Type())
+			}
+
+			pairs := make([]object.HashPair, 0, len(hash.Pairs))
+			for _, pair := range hash.Pairs {
+				pairs = append(pairs, pair)
+			}
+			sort.Slice(pairs, func(i, j int) bool {
+				ki, kj := pairs[i].Key, pairs[j].Key
+				if ki.Inspect() != kj.Inspect() {
+					return ki.Inspect() < kj.Inspect()
+				}
+				return ki.Type() < kj.Type() // "1" and 1 print the same
+			})
+			return pairArrays(pairs)
+		},
+	},
+	// sortedPairs is like entries, but sorts by the keys themselves: booleans
+	// first, false before true, then integers in numeric order, then strings
+	// in lexicographic order. So 10 comes after 9, where entries has it
+	// before.
+	"sortedPairs": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			hash, ok := args[0].(*object.Hash)
+			if !ok {
+				return newError("argument to `sortedPairs` must be HASH, got %s", args[0].Type())
+			}
+
+			pairs := make([]object.HashPair, 0, len(hash.Pairs))
+			for _, pair := range hash.Pairs {
+				pairs = append(pairs, pair)
+			}
+			sort.Slice(pairs, func(i, j int) bool {
+				return keyLess(pairs[i].Key, pairs[j].Key)
+			})
+			return pairArrays(pairs)
+		},
+	},
+	// fromEntries is the inverse of entries: it builds a hash from an array
+	// of [key, value] arrays. A later entry for the same key wins.
+	"fromEntries": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument to `fromEntries` must be ARRAY, got %s", args[0].Type())
+			}
+
+			pairs := make(map[object.HashKey]object.HashPair)
+			for i, el := range arr.Elements {
+				entry, ok := el.(*object.Array)
+				if !ok || len(entry.Elements) != 2 {
+					return newError("entry %d of `fromEntries` must be a [key, value] array, got %s", i, el.Inspect())
+				}
+				key, ok := entry.Elements[0].(object.Hashable)
+				if !ok {
+					return newError("unusable as hash key: %s", entry.Elements[0].Type())
+				}
+				pairs[key.HashKey()] = object.HashPair{Key: entry.Elements[0], Value: entry.Elements[1]}
+			}
+			return &object.Hash{Pairs: pairs}
+		},
+	},
+	// frequencies counts how often each element of an array occurs, in a
+	// hash from the element to its count. Elements equal as hash keys, like
+	// two strings with the same characters, are counted together.
+	"frequencies": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument to `frequencies` must be ARRAY, got %s", args[0].Type())
+			}
+
+			counts := make(map[object.HashKey]object.HashPair)
+			for _, el := range arr.Elements {
+				key, ok := el.(object.Hashable)
+				if !ok {
+					return newError("unusable as hash key: %s", el.Type())
+				}
+				pair, ok := counts[key.HashKey()]
+				if !ok {
+					pair = object.HashPair{Key: el, Value: NativeInt(0)}
+				}
+				pair.Value = NativeInt(pair.Value.(*object.Integer).Value + 1)
+				counts[key.HashKey()] = pair
+			}
+			return &object.Hash{Pairs: counts}
+		},
+	},
+	// pqNew, pqPush and pqPop work on a priority queue that hands out the
+	// value with the lowest integer priority first
+	"pqNew": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0", len(args))
+			}
+			return &object.PriorityQueue{}
+		},
+	},
+	// pqPush(pq, priority, value) adds value to pq and returns pq
+	"pqPush": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=3", len(args))
+			}
+			pq, ok := args[0].(*object.PriorityQueue)
+			if !ok {
+				return newError("argument to `pqPush` must be PRIORITY_QUEUE, got %s", args[0].Type())
+			}
+			priority, ok := args[1].(*object.Integer)
+			if !ok {
+				return newError("priority passed to `pqPush` must be INTEGER, got %s", args[1].Type())
+			}
+			pq.Push(priority.Value, args[2])
+			return pq
+		},
+	},
+	// pqPop removes and returns the value with the lowest priority,
+	// or null if the queue is empty
+	"pqPop": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			pq, ok := args[0].(*object.PriorityQueue)
+			if !ok {
+				return newError("argument to `pqPop` must be PRIORITY_QUEUE, got %s", args[0].Type())
+			}
+			if value, ok := pq.Pop(); ok {
+				return value
+			}
+			return NULL
+		},
+	},
+	"upper": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			str, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `upper` must be STRING, got %s", args[0].Type())
+			}
+			return &object.String{Value: strings.ToUpper(str.Value)}
+		},
+	},
+	"lower": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			str, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `lower` must be STRING, got %s", args[0].Type())
+			}
+			return &object.String{Value: strings.ToLower(str.Value)}
+		},
+	},
+	// trim removes leading and trailing whitespace
+	"trim": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			str, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `trim` must be STRING, got %s", args[0].Type())
+			}
+			return &object.String{Value: strings.TrimSpace(str.Value)}
+		},
+	},
+	// replace(s, old, new) replaces every occurrence of old in s with new.
+	// An empty old matches nothing, so s is returned unchanged.
+	"replace": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=3", len(args))
+			}
+			strs := make([]string, len(args))
+			for i, arg := range args {
+				str, ok := arg.(*object.String)
+				if !ok {
+					return newError("argument to `replace` must be STRING, got %s", arg.Type())
+				}
+				strs[i] = str.Value
+			}
+			if strs[1] == "" {
+				return args[0]
+			}
+			return &object.String{Value: strings.ReplaceAll(strs[0], strs[1], strs[2])}
+		},
+	},
+	// parseInt(s, base) reads s as an integer written in base, which is 10
+	// when left out and otherwise between 2 and 36.
+	"parseInt": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+			str, ok := args[0].(*object.String)
+			if !ok {
+				return newError("first argument to `parseInt` must be STRING, got %s", args[0].Type())
+			}
+			base := int64(10)
+			if len(args) == 2 {
+				b, ok := args[1].(*object.Integer)
+				if !ok {
+					return newError("second argument to `parseInt` must be INTEGER, got %s", args[1].Type())
+				}
+				if b.Value < 2 || b.Value > 36 {
+					return newError("base of `parseInt` must be between 2 and 36, got %d", b.Value)
+				}
+				base = b.Value
+			}
+
+			value, err := strconv.ParseInt(str.Value, int(base), 64)
+			if errors.Is(err, strconv.ErrRange) {
+				return newError("%q is out of range for an integer", str.Value)
+			}
+			if err != nil {
+				return newError("could not parse %q as an integer in base %d", str.Value, base)
+			}
+			return NativeInt(value)
+		},
+	},
+	// format(f, args...) replaces each {} in f with the next argument,
+	// written the way puts would. {{ and }} stand for literal braces.
+	"format": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) == 0 {
+				return newError("wrong number of arguments. got=0, want at least 1")
+			}
+			f, ok := args[0].(*object.String)
+			if !ok {
+				return newError("first argument to `format` must be STRING, got %s", args[0].Type())
+			}
+			return formatString(f.Value, args[1:])
+		},
+	},
+	// toJSON(x, indent) returns x as JSON text, with nested values on lines
+	// of their own indented by indent spaces per level, or all on one line
+	// without an indent. See writeJSON for how values are converted.
+	"toJSON": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+			indent := int64(0)
+			if len(args) == 2 {
+				n, ok := args[1].(*object.Integer)
+				if !ok {
+					return newError("second argument to `toJSON` must be INTEGER, got %s", args[1].Type())
+				}
+				if n.Value < 0 {
+					return newError("indent of `toJSON` must not be negative, got %d", n.Value)
+				}
+				indent = n.Value
+			}
+
+			var out strings.Builder
+			if err := writeJSON(&out, args[0], strings.Repeat(" ", int(indent)), 0, map[object.Object]bool{}); err != nil {
+				return err
+			}
+			return &object.String{Value: out.String()}
+		},
+	},
+	// throw(message) raises an error, which stops the program like any
+	// runtime error unless a try catches it.
+	"throw": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			if message, ok := args[0].(*object.String); ok {
+				return newError("%s", message.Value)
+			}
+			return newError("%s", args[0].Inspect())
+		},
+	},
+	"puts": {
+		Fn: func(args ...object.Object) object.Object {
+			for _, arg := range args {
+				fmt.Println(arg.Inspect())
+			}
+			return NULL
+		},
+	},
+	// readLine returns the next line of input without the line ending,
+	// or null once the input is exhausted.
+	"readLine": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0", len(args))
+			}
+			line, err := input.ReadString('\n')
+			if err != nil && line == "" {
+				return NULL
+			}
+			line = strings.TrimSuffix(line, "\n")
+			line = strings.TrimSuffix(line, "\r")
+			return &object.String{Value: line}
+		},
+	},
+	// readFile returns the contents of the file at a path, or null if it
+	// can't be read.
+	"readFile": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			path, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `readFile` must be STRING, got %s", args[0].Type())
+			}
+			data, err := files.ReadFile(path.Value)
+			if err != nil {
+				return NULL
+			}
+			return &object.String{Value: string(data)}
+		},
+	},
+	// writeFile(path, contents) replaces the file at path with contents and
+	// returns whether that worked.
+	"writeFile": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			for _, arg := range args {
+				if arg.Type() != object.STRING_OBJ {
+					return newError("arguments to `writeFile` must be STRING, got %s", arg.Type())
+				}
+			}
+			path, contents := args[0].(*object.String), args[1].(*object.String)
+			if err := files.WriteFile(path.Value, []byte(contents.Value)); err != nil {
+				return FALSE
+			}
+			return TRUE
+		},
+	},
+}
+
+// deepCopy copies arrays and hashes recursively. copies maps the arrays and
+// hashes copied so far to their copies, so a value that occurs twice is
+// copied once and an array containing itself doesn't recurse forever.
+func deepCopy(obj object.Object, copies map[object.Object]object.Object) object.Object {
+	if copied, ok := copies[obj]; ok {
+		return copied
+	}
+
+	switch obj := obj.(type) {
+	case *object.Array:
+		arr := &object.Array{Elements: make([]object.Object, len(obj.Elements))}
+		copies[obj] = arr
+		for i, el := range obj.Elements {
+			arr.Elements[i] = deepCopy(el, copies)
+		}
+		return arr
+	case *object.Hash:
+		hash := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair, len(obj.Pairs))}
+		copies[obj] = hash
+		for key, pair := range obj.Pairs {
+			hash.Pairs[key] = object.HashPair{Key: pair.Key, Value: deepCopy(pair.Value, copies)}
+		}
+		return hash
+	default:
+		return obj
+	}
+}
+
+// The builtins taking functions as arguments call back into the evaluator,
+// which looks up builtins itself, and arity looks them up directly, so they
+// are added in init to keep the builtins map from depending on its own
+// initialization.
+func init() {
+	// arity returns the number of parameters of a function, those with a
+	// default value included. For a core builtin it is the most arguments
+	// the builtin takes, see BuiltinArity. Builtins without a limit, those
+	// registered by the host and those made by compose or partial have an
+	// arity of -1.
+	builtins["arity"] = &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			switch fn := args[0].(type) {
+			case *object.Function:
+				return NativeInt(int64(len(fn.Parameters)))
+			case *object.Builtin:
+				for name, builtin := range builtins {
+					if builtin == fn {
+						return NativeInt(int64(builtinArity[name][1]))
+					}
+				}
+				return NativeInt(-1)
+			default:
+				return newError("argument to `arity` must be FUNCTION, got %s", args[0].Type())
+			}
+		},
+	}
+	// compose returns a function passing its arguments to g and the result
+	// of that to f, like fn(x) { f(g(x)) }.
+	builtins["compose"] = &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			for _, arg := range args {
+				if !isCallable(arg) {
+					return newError("argument to `compose` must be FUNCTION, got %s", arg.Type())
+				}
+			}
+			f, g := args[0], args[1]
+			return &object.Builtin{
+				Fn: func(args ...object.Object) object.Object {
+					inner := applyFunction(g, args)
+					if isError(inner) {
+						return inner
+					}
+					return applyFunction(f, []object.Object{inner})
+				},
+			}
+		},
+	}
+	// apply(f, args) calls f with the elements of the array args as its
+	// arguments, so apply(add, [1, 2]) is add(1, 2).
+	builtins["apply"] = &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			if !isCallable(args[0]) {
+				return newError("first argument to `apply` must be FUNCTION, got %s", args[0].Type())
+			}
+			arr, ok := args[1].(*object.Array)
+			if !ok {
+				return newError("second argument to `apply` must be ARRAY, got %s", args[1].Type())
+			}
+			return applyFunction(args[0], append([]object.Object{}, arr.Elements...))
+		},
+	}
+	// partial returns a function calling f with the given arguments followed
+	// by its own, so partial(add, 1)(2) is add(1, 2).
+	builtins["partial"] = &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 2 {
+				return newError("wrong number of arguments. got=%d, want at least 2", len(args))
+			}
+			if !isCallable(args[0]) {
+				return newError("first argument to `partial` must be FUNCTION, got %s", args[0].Type())
+			}
+			f := args[0]
+			bound := append([]object.Object{}, args[1:]...)
+			return &object.Builtin{
+				Fn: func(args ...object.Object) object.Object {
+					all := make([]object.Object, 0, len(bound)+len(args))
+					all = append(all, bound...)
+					all = append(all, args...)
+					return applyFunction(f, all)
+				},
+			}
+		},
+	}
+	// groupBy(arr, f) returns a hash from every key f gives for the elements
+	// of arr to the array of the elements with that key, in their order in arr.
+	builtins["groupBy"] = &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("first argument to `groupBy` must be ARRAY, got %s", args[0].Type())
+			}
+			if !isCallable(args[1]) {
+				return newError("second argument to `groupBy` must be FUNCTION, got %s", args[1].Type())
+			}
+
+			groups := make(map[object.HashKey]object.HashPair)
+			for _, el := range arr.Elements {
+				key := applyFunction(args[1], []object.Object{el})
+				if isError(key) {
+					return key
+				}
+				hashable, ok := key.(object.Hashable)
+				if !ok {
+					return newError("unusable as hash key: %s", key.Type())
+				}
+				group, ok := groups[hashable.HashKey()]
+				if !ok {
+					group = object.HashPair{Key: key, Value: &object.Array{}}
+				}
+				members := group.Value.(*object.Array)
+				members.Elements = append(members.Elements, el)
+				groups[hashable.HashKey()] = group
+			}
+			return &object.Hash{Pairs: groups}
+		},
+	}
+	// repeat(n, f) calls f n times, with the indexes 0 to n-1, and returns
+	// null. It is meant for the side effects of f, so its results are
+	// dropped unless one is an error. A count below 1 doesn't call f at all.
+	builtins["repeat"] = &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			n, ok := args[0].(*object.Integer)
+			if !ok {
+				return newError("first argument to `repeat` must be INTEGER, got %s", args[0].Type())
+			}
+			if !isCallable(args[1]) {
+				return newError("second argument to `repeat` must be FUNCTION, got %s", args[1].Type())
+			}
+			for i := int64(0); i < n.Value; i++ {
+				if result := applyFunction(args[1], []object.Object{NativeInt(i)}); isError(result) {
+					return result
+				}
+			}
+			return NULL
+		},
+	}
+	// try(body, handler) calls body and returns its result. If body raises
+	// an error, thrown or not, handler is called with the error message
+	// instead and try returns what handler returns. An error raised by the
+	// handler isn't caught, so it can pass an error on with throw.
+	builtins["try"] = &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			for _, arg := range args {
+				if !isCallable(arg) {
+					return newError("argument to `try` must be FUNCTION, got %s", arg.Type())
+				}
+			}
+			result := applyFunction(args[0], []object.Object{})
+			if err, ok := result.(*object.Error); ok {
+				return applyFunction(args[1], []object.Object{&object.String{Value: err.Message}})
+			}
+			return result
+		},
+	}
+	// count(arr, x) returns how many elements of arr equal x. If x is a
+	// function, it counts the elements for which x returns a truthy value
+	// instead, so functions themselves can't be counted.
+	builtins["count"] = &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("first argument to `count` must be ARRAY, got %s", args[0].Type())
+			}
+
+			n := int64(0)
+			for _, el := range arr.Elements {
+				if !isCallable(args[1]) {
+					if object.Equals(el, args[1]) {
+						n++
+					}
+					continue
+				}
+				matches := applyFunction(args[1], []object.Object{el})
+				if isError(matches) {
+					return matches
+				}
+				if isTruthy(matches) {
+					n++
+				}
+			}
+			return NativeInt(n)
+		},
+	}
+	// maxBy(arr, f) returns the element of arr for which f gives the
+	// largest key, and minBy the one with the smallest key. The keys have to
+	// be all numbers or all strings. Of equal keys the first one wins, and
+	// an empty array gives null.
+	builtins["maxBy"] = &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			return extremeBy("maxBy", args, func(key, best object.Object) bool { return less(best, key) })
+		},
+	}
+	builtins["minBy"] = &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			return extremeBy("minBy", args, less)
+		},
+	}
+}
+
+// extremeBy does the work of the builtin called name, maxBy or minBy: it
+// returns the element whose key beats the keys of the elements before it,
+// as decided by beats.
+func extremeBy(name string, args []object.Object, beats func(key, best object.Object) bool) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return newError("first argument to `%s` must be ARRAY, got %s", name, args[0].Type())
+	}
+	if !isCallable(args[1]) {
+		return newError("second argument to `%s` must be FUNCTION, got %s", name, args[1].Type())
+	}
+
+	var best, bestKey object.Object = NULL, nil
+	for _, el := range arr.Elements {
+		key := applyFunction(args[1], []object.Object{el})
+		if isError(key) {
+			return key
+		}
+		if !isNumber(key) && key.Type() != object.STRING_OBJ {
+			return newError("keys of `%s` must be numbers or strings, got %s", name, key.Type())
+		}
+		if bestKey == nil {
+			best, bestKey = el, key
+			continue
+		}
+		if isNumber(key) != isNumber(bestKey) {
+			return newError("`%s` can't compare %s and %s", name, bestKey.Type(), key.Type())
+		}
+		if beats(key, bestKey) {
+			best, bestKey = el, key
+		}
+	}
+	return best
+}
+
+// pairArrays returns the pairs as an array of [key, value] arrays.
+func pairArrays(pairs []object.HashPair) *object.Array {
+	elements := make([]object.Object, len(pairs))
+	for i, pair := range pairs {
+		elements[i] = &object.Array{Elements: []object.Object{pair.Key, pair.Value}}
+	}
+	return &object.Array{Elements: elements}
+}
+
+// keyOrder ranks the types of hash keys for keyLess.
+var keyOrder = map[object.ObjectType]int{
+	object.BOOLEAN_OBJ: 0,
+	object.INTEGER_OBJ: 1,
+	object.STRING_OBJ:  2,
+}
+
+// keyLess orders hash keys, first by type as ranked by keyOrder, then by
+// value.
+func keyLess(a, b object.Object) bool {
+	if a.Type() != b.Type() {
+		return keyOrder[a.Type()] < keyOrder[b.Type()]
+	}
+	if a, ok := a.(*object.Boolean); ok {
+		return !a.Value && b.(*object.Boolean).Value
+	}
+	return less(a, b)
+}
+
+// flatten appends elements to flat, with the arrays among them flattened
+// depth levels deep, or all the way down if depth is negative.
+func flatten(flat, elements []object.Object, depth int64) []object.Object {
+	for _, el := range elements {
+		if arr, ok := el.(*object.Array); ok && depth != 0 {
+			flat = flatten(flat, arr.Elements, depth-1)
+		} else {
+			flat = append(flat, el)
+		}
+	}
+	return flat
+}
+
+func isCallable(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Function, *object.Builtin:
+		return true
+	default:
+		return false
+	}
+}
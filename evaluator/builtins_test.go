@@ -0,0 +1,1318 @@
+package evaluator
+
+import (
+	"fmt"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("café")`, 4},
+		{`byteLen("café")`, 5},
+		{`byteLen("four")`, 4},
+		{`byteLen("")`, 0},
+		{`byteLen([1])`, "argument to `byteLen` must be STRING, got ARRAY"},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
+		{`readLine(1)`, "wrong number of arguments. got=1, want=0"},
+		{`len([1, 2, 3])`, 3},
+		{`len([])`, 0},
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, nil},
+		{`first(1)`, "argument to `first` must be ARRAY, got INTEGER"},
+		{`last([1, 2, 3])`, 3},
+		{`last([])`, nil},
+		{`last(1)`, "argument to `last` must be ARRAY, got INTEGER"},
+		{`rest([1, 2, 3])`, []int{2, 3}},
+		{`rest([])`, nil},
+		{`push([], 1)`, []int{1}},
+		{`push(1, 1)`, "argument to `push` must be ARRAY, got INTEGER"},
+		{`let a = [1]; let b = push(a, 2); a`, []int{1}},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestSelfPushKeepsAliasesIntact(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let a = [1]; let b = a; a = push(a, 2); [a, b]`, "[[1, 2], [1]]"},
+		{`let a = []; a = push(a, 1); let b = a; a = push(a, 2); b = push(b, 9); [a, b]`, "[[1, 2], [1, 9]]"},
+		{`let a = []; a = push(a, 1); let b = a; let a = push(a, 2); let c = push(b, 3); [a, b, c]`, "[[1, 2], [1], [1, 3]]"},
+		{`let a = []; let i = 0; while (i < 5) { a = push(a, i); i = i + 1 }; a`, "[0, 1, 2, 3, 4]"},
+		{`let a = []; let f = fn(x) { a = push(a, x) }; f(1); let b = a; f(2); [a, b]`, "[[1, 2], [1]]"},
+		{`let push = fn(arr, x) { [x] }; let a = [1]; a = push(a, 2); a`, "[2]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: wrong result. want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	testExpectedObject(t, `let a = 1; a = push(a, 2)`, testEval(`let a = 1; a = push(a, 2)`),
+		"argument to `push` must be ARRAY, got INTEGER")
+	testExpectedObject(t, `let a = []; a = push(a, -true)`, testEval(`let a = []; a = push(a, -true)`),
+		"unknown operator: -BOOLEAN")
+}
+
+func TestRange(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"toArray(range(4))", "[0, 1, 2, 3]"},
+		{"toArray(range(2, 5))", "[2, 3, 4]"},
+		{"toArray(range(0, 10, 3))", "[0, 3, 6, 9]"},
+		{"toArray(range(5, 0, -2))", "[5, 3, 1]"},
+		{"toArray(range(5, 0))", "[]"},
+		{"toArray(range(0, 1, 0.25))", "[0, 0.25, 0.5, 0.75]"},
+		{"toArray(range(0.5, 2))", "[0.5, 1.5]"},
+		// computed as start + i*step, so there's no drift
+		{"let r = range(0, 1, 0.1); toArray(r)[9]", "0.9"},
+		{"len(range(0, 10, 3))", "4"},
+		{"range(3)", "range(0, 3, 1)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: wrong result. want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"range()", "wrong number of arguments. got=0, want=1 to 3"},
+		{`range("a")`, "argument to `range` must be INTEGER or FLOAT, got STRING"},
+		{"range(0, 5, 0)", "step of `range` must not be zero"},
+		{"toArray([1])", "argument to `toArray` must be RANGE, got ARRAY"},
+	}
+
+	for _, tt := range errors {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+// A range is never turned into an array when it's iterated: the elements of a
+// range of a trillion numbers wouldn't fit in memory.
+func TestRangeIsLazy(t *testing.T) {
+	input := `
+	let find = fn() {
+		for (x in range(1000000000000)) {
+			if (x == 3) { return x }
+		}
+	};
+	[find(), len(range(1000000000000))]`
+	if evaluated := testEval(input); evaluated.Inspect() != "[3, 1000000000000]" {
+		t.Errorf("wrong result. got=%s", evaluated.Inspect())
+	}
+
+	input = "let sum = 0; for (x in range(1000000)) { sum = sum + x }; sum"
+	testExpectedObject(t, input, testEval(input), 499999500000)
+}
+
+func TestClone(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// the copy is independent of the original at every level
+		{"let a = [1, [2, 3]]; let b = clone(a); b[1][0] = 9; b[0] = 8; [a, b]", "[[1, [2, 3]], [8, [9, 3]]]"},
+		{"let a = [1, [2, 3]]; let b = clone(a); a[1][1] = 9; b", "[1, [2, 3]]"},
+		{`let h = {"k": [1]}; let c = clone(h); c["k"][0] = 2; c["n"] = 3; [h, c]`, "[{k: [1]}, {k: [2], n: 3}]"},
+		// an array occurring twice is copied once, and cycles are kept
+		{"let x = [1]; let a = [x, x]; let b = clone(a); b[0][0] = 2; b", "[[2], [2]]"},
+		{"let a = [1]; a[0] = a; let b = clone(a); b[0] == b", "true"},
+		{"let a = [1]; a[0] = a; let b = clone(a); b[0] == a", "false"},
+		{"clone(5)", "5"},
+		{`clone("s")`, "s"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: wrong result. want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	testExpectedObject(t, "clone()", testEval("clone()"), "wrong number of arguments. got=0, want=1")
+}
+
+func TestIdOf(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = [1]; idOf(a) == idOf(a)", "true"},
+		{"let a = [1]; let b = a; idOf(a) == idOf(b)", "true"},
+		{"let a = [1]; idOf(a) == idOf(clone(a))", "false"},
+		{"idOf([1]) == idOf([1])", "false"},
+		{`let h = {"a": 1}; let before = idOf(h); h["b"] = 2; before == idOf(h)`, "true"},
+		{"let f = fn() { 1 }; idOf(f) == idOf(f)", "true"},
+		{"idOf(fn() { 1 }) == idOf(fn() { 1 })", "false"},
+		{"let a = [1]; push(a, 2); a[0] = 5; let before = idOf(a); a[0] = 6; before == idOf(a)", "true"},
+		{"idOf(5) == idOf(2 + 3)", "true"},
+		{`idOf("ab") == idOf("a" + "b")`, "true"},
+		{`idOf(1) == idOf("1")`, "false"},
+		{"idOf(1) == idOf(1.0)", "false"},
+		{"[idOf(0.1 + 0.2) == idOf(0.3), 0.1 + 0.2 == 0.3]", "[false, false]"},
+		{"idOf(0.1 + 0.2)", "FLOAT:0.30000000000000004"},
+		{"idOf(1.5) == idOf(3.0 / 2.0)", "true"},
+		{"idOf(-0.0) == idOf(0.0)", "true"},
+		{"idOf(5)", "INTEGER:5"},
+		{`idOf("x")`, "STRING:x"},
+		{"idOf(true)", "BOOLEAN:true"},
+		{"idOf(if (false) { 1 })", "NULL:null"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: wrong result. want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	evaluated := testEval("let a = [1]; idOf(a)")
+	if id, ok := evaluated.(*object.String); !ok || !strings.HasPrefix(id.Value, "ARRAY#") {
+		t.Errorf("wrong id of an array. got=%s", evaluated.Inspect())
+	}
+	testExpectedObject(t, "idOf()", testEval("idOf()"), "wrong number of arguments. got=0, want=1")
+}
+
+// BenchmarkPushLoop builds an array of n elements with push. `a = push(a, i)`
+// grows the array in place, while pushing to a copy of the binding can't and
+// has to copy the whole array every time, so its time grows with n².
+func BenchmarkPushLoop(b *testing.B) {
+	loops := map[string]string{
+		"in place": "let a = []; let i = 0; while (i < %d) { a = push(a, i); i = i + 1 }",
+		"copying":  "let a = []; let i = 0; while (i < %d) { let b = push(a, i); a = b; i = i + 1 }",
+	}
+	for _, name := range []string{"in place", "copying"} {
+		for _, n := range []int{1000, 4000} {
+			program := parser.New(lexer.New(fmt.Sprintf(loops[name], n))).ParseProgram()
+			b.Run(fmt.Sprintf("%s/%d", name, n), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					Eval(program, object.NewEnvironment())
+				}
+			})
+		}
+	}
+}
+
+func TestLenOfDerivedValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let a = [1, 2]; len(a); let b = push(a, 3); [len(a), len(b)]`, []int{2, 3}},
+		{`let a = [1, 2]; len(a); a = push(a, 3); len(a)`, 3},
+		{`let a = [1, 2, 3]; len(a); [len(rest(a)), len(slice(a, 1)), len(concat(a, a))]`, []int{2, 2, 6}},
+		{`let a = [1, 2, 3]; len(a); a[0] = 5; len(a)`, 3},
+		{`let s = "héllo"; len(s); let t = s + "!"; [len(s), len(t), len(s)]`, []int{5, 6, 5}},
+		{`let s = "ab"; len(s); [len(s * 3), len(upper(s)), len(slice(s, 1))]`, []int{6, 2, 1}},
+		{`let s = ""; [len(s), len(s)]`, []int{0, 0}},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+// BenchmarkStringLen calls len on the same long string over and over, which
+// only counts its characters the first time, and on fresh copies of it,
+// which have to count them every time.
+func BenchmarkStringLen(b *testing.B) {
+	value := strings.Repeat("héllo wörld ", 10000)
+	length := builtins["len"].Fn
+
+	b.Run("same string", func(b *testing.B) {
+		str := &object.String{Value: value}
+		for i := 0; i < b.N; i++ {
+			length(str)
+		}
+	})
+	b.Run("fresh strings", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			length(&object.String{Value: value})
+		}
+	})
+}
+
+func TestConcat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`concat([1, 2], [3, 4])`, []int{1, 2, 3, 4}},
+		{`concat([1], [], [2, 3], [4])`, []int{1, 2, 3, 4}},
+		{`concat([])`, []int{}},
+		{`let a = [1]; concat(a, [2]); a`, []int{1}},
+		{`concat()`, "wrong number of arguments. got=0, want at least 1"},
+		{`concat([1], 2)`, "argument to `concat` must be ARRAY, got INTEGER"},
+		{`concat({}, [1])`, "argument to `concat` must be ARRAY, got HASH"},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestZip(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`zip([1, 2, 3], ["a", "b", "c"])`, `[[1, a], [2, b], [3, c]]`},
+		{`zip([1, 2, 3], ["a"])`, `[[1, a]]`},
+		{`zip([1, 2], [3, 4], [5, 6, 7])`, `[[1, 3, 5], [2, 4, 6]]`},
+		{`zip([1, 2])`, `[[1], [2]]`},
+		{`zip([1, 2], [])`, `[]`},
+		{`zip()`, "ERROR: wrong number of arguments. got=0, want at least 1"},
+		{`zip([1], "a")`, "ERROR: argument to `zip` must be ARRAY, got STRING"},
+	}
+
+	for _, tt := range tests {
+		if evaluated := testEval(tt.input); evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestReverse(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`reverse([1, 2, 3])`, `[3, 2, 1]`},
+		{`reverse([])`, `[]`},
+		{`let a = [1, 2]; reverse(a); a`, `[1, 2]`},
+		{`reverse("abc")`, `cba`},
+		{`reverse("")`, ``},
+		{`reverse("héllo, 世界")`, `界世 ,olléh`},
+		{`reverse(1)`, "ERROR: argument to `reverse` not supported, got INTEGER"},
+		{`reverse([1], [2])`, "ERROR: wrong number of arguments. got=2, want=1"},
+	}
+
+	for _, tt := range tests {
+		if evaluated := testEval(tt.input); evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestApproxEqual(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`approxEqual(0.1 + 0.2, 0.3)`, true},
+		{`0.1 + 0.2 == 0.3`, false},
+		{`approxEqual(1, 1.0)`, true},
+		{`approxEqual(1, 2)`, false},
+		{`approxEqual(1.0, 1.1)`, false},
+		{`approxEqual(1.0, 1.1, 0.2)`, true},
+		{`approxEqual(100, 103, 5)`, true},
+		{`approxEqual(100, 106, 5)`, false},
+		{`approxEqual(1, "1")`, "argument to `approxEqual` must be INTEGER or FLOAT, got STRING"},
+		{`approxEqual(1, 1, true)`, "argument to `approxEqual` must be INTEGER or FLOAT, got BOOLEAN"},
+		{`approxEqual(1)`, "wrong number of arguments. got=1, want=2 or 3"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if expected, ok := tt.expected.(bool); ok {
+			testBooleanObject(t, evaluated, expected)
+			continue
+		}
+		testExpectedObject(t, tt.input, evaluated, tt.expected)
+	}
+}
+
+func TestSlice(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`slice([1, 2, 3, 4], 1, 3)`, `[2, 3]`},
+		{`slice([1, 2, 3, 4], 0, 4)`, `[1, 2, 3, 4]`},
+		{`slice("hello", 1, 3)`, `el`},
+		{`slice("héllo", 1, 2)`, `é`},
+		// omitted end
+		{`slice([1, 2, 3, 4], 2)`, `[3, 4]`},
+		{`slice("hello", 1)`, `ello`},
+		// negative and out of range indices
+		{`slice([1, 2, 3, 4], -2)`, `[3, 4]`},
+		{`slice("hello", 1, -1)`, `ell`},
+		{`slice([1, 2, 3], 1, 100)`, `[2, 3]`},
+		{`slice([1, 2, 3], -100, 2)`, `[1, 2]`},
+		{`slice("hello", 10)`, ``},
+		// start after end
+		{`slice([1, 2, 3], 2, 1)`, `[]`},
+		{`slice("hello", 3, 3)`, ``},
+		{`let a = [1, 2, 3]; slice(a, 1); a`, `[1, 2, 3]`},
+		{`slice([1])`, "ERROR: wrong number of arguments. got=1, want=2 or 3"},
+		{`slice([1], "a")`, "ERROR: indices of `slice` must be INTEGER, got STRING"},
+		{`slice([1], 0, 1.5)`, "ERROR: indices of `slice` must be INTEGER, got FLOAT"},
+		{`slice(1, 0)`, "ERROR: argument to `slice` not supported, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		if evaluated := testEval(tt.input); evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestSort(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`sort([3, 1, 2])`, `[1, 2, 3]`},
+		{`sort([])`, `[]`},
+		{`sort([2.5, 1, 2])`, `[1, 2, 2.5]`},
+		{`sort(["b", "c", "a"])`, `[a, b, c]`},
+		{`let a = [2, 1]; sort(a); a`, `[2, 1]`},
+		{`sort([1, "a"])`, "ERROR: `sort` can't compare INTEGER and STRING"},
+		{`sort([[1], [2]])`, "ERROR: `sort` can only sort numbers and strings, got ARRAY"},
+		{`sort("abc")`, "ERROR: argument to `sort` must be ARRAY, got STRING"},
+	}
+
+	for _, tt := range tests {
+		if evaluated := testEval(tt.input); evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestEnumerate(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`enumerate(["a", "b"])`, `[[0, a], [1, b]]`},
+		{`enumerate(["a", "b"], 1)`, `[[1, a], [2, b]]`},
+		{`enumerate([[1], true], -1)`, `[[-1, [1]], [0, true]]`},
+		{`enumerate([])`, `[]`},
+		{`enumerate("ab")`, "ERROR: first argument to `enumerate` must be ARRAY, got STRING"},
+		{`enumerate([1], "1")`, "ERROR: second argument to `enumerate` must be INTEGER, got STRING"},
+		{`enumerate()`, "ERROR: wrong number of arguments. got=0, want=1 or 2"},
+	}
+
+	for _, tt := range tests {
+		if evaluated := testEval(tt.input); evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestExpectType(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`expectType(5, "INTEGER")`, `5`},
+		{`expectType([1, 2], "ARRAY")`, `[1, 2]`},
+		{`expectType(len, "BUILTIN")`, `builtin function`},
+		{`expectType(puts(), "NULL")`, `null`},
+		{`expectType("5", "INTEGER")`, "ERROR: expected INTEGER, got STRING"},
+		{`expectType(5, "NUMBER")`, `ERROR: unknown type name "NUMBER"`},
+		{`expectType(5, "ERROR")`, `ERROR: unknown type name "ERROR"`},
+		{`expectType(5, 5)`, "ERROR: second argument to `expectType` must be STRING, got INTEGER"},
+		{`expectType(5)`, "ERROR: wrong number of arguments. got=1, want=2"},
+	}
+
+	for _, tt := range tests {
+		if evaluated := testEval(tt.input); evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestChunk(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`chunk([1, 2, 3, 4], 2)`, `[[1, 2], [3, 4]]`},
+		{`chunk([1, 2, 3, 4, 5], 2)`, `[[1, 2], [3, 4], [5]]`},
+		{`chunk([1, 2, 3], 1)`, `[[1], [2], [3]]`},
+		{`chunk([1, 2], 5)`, `[[1, 2]]`},
+		{`chunk([], 3)`, `[]`},
+		{`chunk([1, 2], 0)`, "ERROR: size of `chunk` must be positive, got 0"},
+		{`chunk([1, 2], -1)`, "ERROR: size of `chunk` must be positive, got -1"},
+		{`chunk("ab", 1)`, "ERROR: first argument to `chunk` must be ARRAY, got STRING"},
+		{`chunk([1], "1")`, "ERROR: second argument to `chunk` must be INTEGER, got STRING"},
+		{`chunk([1])`, "ERROR: wrong number of arguments. got=1, want=2"},
+	}
+
+	for _, tt := range tests {
+		if evaluated := testEval(tt.input); evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`flatten([1, [2, [3, 4]], 5])`, `[1, 2, 3, 4, 5]`},
+		{`flatten([[[[1]]], [[2]]])`, `[1, 2]`},
+		{`flatten([1, [2, [3, 4]], 5], 1)`, `[1, 2, [3, 4], 5]`},
+		{`flatten([1, [2, [3, 4]], 5], 0)`, `[1, [2, [3, 4]], 5]`},
+		{`flatten([1, "two", {"a": [3]}])`, `[1, two, {a: [3]}]`},
+		{`flatten([[], [[]]])`, `[]`},
+		{`let a = [[1]]; flatten(a); a`, `[[1]]`},
+		{`flatten([1], -1)`, "ERROR: depth of `flatten` must not be negative, got -1"},
+		{`flatten(1)`, "ERROR: first argument to `flatten` must be ARRAY, got INTEGER"},
+		{`flatten([1], [1])`, "ERROR: second argument to `flatten` must be INTEGER, got ARRAY"},
+		{`flatten()`, "ERROR: wrong number of arguments. got=0, want=1 or 2"},
+	}
+
+	for _, tt := range tests {
+		if evaluated := testEval(tt.input); evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestHas(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`has({"a": 1}, "a")`, true},
+		{`has({"a": 1}, "b")`, false},
+		{`has({1: "one", true: "yes"}, true)`, true},
+		{`has({1: "one"}, "1")`, false},
+		{`has([1, "two", [3]], "two")`, true},
+		{`has([1, "two", [3]], [3])`, true},
+		{`has([1, 2], 3)`, false},
+		{`has([], 1)`, false},
+		{`has({"a": 1}, [1])`, "unusable as hash key: ARRAY"},
+		{`has("abc", "a")`, "first argument to `has` must be HASH or ARRAY, got STRING"},
+		{`has([1])`, "wrong number of arguments. got=1, want=2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if expected, ok := tt.expected.(bool); ok {
+			testBooleanObject(t, evaluated, expected)
+			continue
+		}
+		testExpectedObject(t, tt.input, evaluated, tt.expected)
+	}
+}
+
+func TestDeepEqual(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`deepEqual([1, [2, {"a": [3]}]], [1, [2, {"a": [3]}]])`, true},
+		{`deepEqual({"a": 1, "b": 2}, {"b": 2, "a": 1})`, true},
+		{`deepEqual([1, [2, 3]], [1, [2, 4]])`, false},
+		{`deepEqual([1, 2], [1, 2, 3])`, false},
+		{`deepEqual({"a": 1}, {"a": 1, "b": 2})`, false},
+		{`deepEqual(1, 1.0)`, false},
+		{`deepEqual("a", "a")`, true},
+		{`let f = fn(x) { x }; deepEqual([f], [f])`, true},
+		{`deepEqual([fn(x) { x }], [fn(x) { x }])`, false},
+		{`let nan = 1e308 * 10 - 1e308 * 10; deepEqual(nan, nan)`, true},
+		{`let nan = 1e308 * 10 - 1e308 * 10; deepEqual([nan], [1e308 * 10 - 1e308 * 10])`, true},
+		{`let nan = 1e308 * 10 - 1e308 * 10; nan == nan`, false},
+		{`deepEqual(0.0, -0.0)`, true},
+		{`deepEqual(1)`, "wrong number of arguments. got=1, want=2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if expected, ok := tt.expected.(bool); ok {
+			testBooleanObject(t, evaluated, expected)
+			continue
+		}
+		testExpectedObject(t, tt.input, evaluated, tt.expected)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`merge({"a": 1}, {"b": 2})`, `{a: 1, b: 2}`},
+		{`merge({"a": 1, "b": 2}, {"b": 3})`, `{a: 1, b: 3}`},
+		{`merge({"a": 1}, {"a": 2}, {"a": 3, "c": 4})`, `{a: 3, c: 4}`},
+		{`merge({})`, `{}`},
+		{`let base = {"a": 1}; merge(base, {"a": 2}); base`, `{a: 1}`},
+		{`merge()`, "wrong number of arguments. got=0, want at least 1"},
+		{`merge({"a": 1}, [1])`, "argument to `merge` must be HASH, got ARRAY"},
+		{`merge(1)`, "argument to `merge` must be HASH, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if hash, ok := evaluated.(*object.Hash); ok {
+			if hash.Inspect() != tt.expected {
+				t.Errorf("%s: wrong hash. expected=%s, got=%s", tt.input, tt.expected, hash.Inspect())
+			}
+			continue
+		}
+		testExpectedObject(t, tt.input, evaluated, tt.expected)
+	}
+}
+
+func TestEntries(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`entries({"a": 1})`, `[[a, 1]]`},
+		{`entries({"b": 2, "a": 1, "c": 3})`, `[[a, 1], [b, 2], [c, 3]]`},
+		{`entries({2: "x", 1: "y", true: "z"})`, `[[1, y], [2, x], [true, z]]`},
+		{`entries({})`, `[]`},
+		{`fromEntries([["a", 1]])`, `{a: 1}`},
+		{`fromEntries([["a", 1], [2, [3]], [true, {}]])`, `{2: [3], a: 1, true: {}}`},
+		{`fromEntries([["a", 1], ["a", 2]])`, `{a: 2}`},
+		{`fromEntries([])`, `{}`},
+		{`fromEntries(entries({"x": 1, "y": [2], 3: "z"}))`, `{3: z, x: 1, y: [2]}`},
+		{`entries(fromEntries([["b", 2], ["a", 1]]))`, `[[a, 1], [b, 2]]`},
+		{`entries([1])`, "argument to `entries` must be HASH, got ARRAY"},
+		{`entries({}, {})`, "wrong number of arguments. got=2, want=1"},
+		{`fromEntries({})`, "argument to `fromEntries` must be ARRAY, got HASH"},
+		{`fromEntries([1])`, "entry 0 of `fromEntries` must be a [key, value] array, got 1"},
+		{`fromEntries([["a", 1], ["b"]])`, "entry 1 of `fromEntries` must be a [key, value] array, got [b]"},
+		{`fromEntries([["a", 1, 2]])`, "entry 0 of `fromEntries` must be a [key, value] array, got [a, 1, 2]"},
+		{`fromEntries([[[1], 1]])`, "unusable as hash key: ARRAY"},
+		{`fromEntries()`, "wrong number of arguments. got=0, want=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if _, ok := evaluated.(*object.Error); !ok {
+			if evaluated.Inspect() != tt.expected {
+				t.Errorf("%s: wrong result. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+			}
+			continue
+		}
+		testExpectedObject(t, tt.input, evaluated, tt.expected)
+	}
+}
+
+func TestSortedPairs(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`sortedPairs({"b": 2, "a": 1})`, `[[a, 1], [b, 2]]`},
+		{`sortedPairs({"ab": 1, "B": 2, "a": 3})`, `[[B, 2], [a, 3], [ab, 1]]`},
+		{`sortedPairs({10: "ten", 9: "nine", -1: "minus one"})`, `[[-1, minus one], [9, nine], [10, ten]]`},
+		{`sortedPairs({"x": 1, 2: 2, true: 3, false: 4, "1": 5, 1: 6})`, `[[false, 4], [true, 3], [1, 6], [2, 2], [1, 5], [x, 1]]`},
+		{`sortedPairs({})`, `[]`},
+		{`sortedPairs([1])`, "argument to `sortedPairs` must be HASH, got ARRAY"},
+		{`sortedPairs("a")`, "argument to `sortedPairs` must be HASH, got STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if _, ok := evaluated.(*object.Error); !ok {
+			if evaluated.Inspect() != tt.expected {
+				t.Errorf("%s: wrong result. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+			}
+			continue
+		}
+		testExpectedObject(t, tt.input, evaluated, tt.expected)
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`toJSON({"name": "monkey", "tags": ["a", "b"], "size": {"w": 1.5, "h": 2}, "ok": true, "none": if (false) { 1 }}, 2)`, `{
+  "name": "monkey",
+  "none": null,
+  "ok": true,
+  "size": {
+    "h": 2,
+    "w": 1.5
+  },
+  "tags": [
+    "a",
+    "b"
+  ]
+}`},
+		{`toJSON([1, [2, []], {}], 4)`, `[
+    1,
+    [
+        2,
+        []
+    ],
+    {}
+]`},
+		{`toJSON([1, "two", {"x": [3]}])`, `[1,"two",{"x":[3]}]`},
+		{`toJSON({2: "b", 10: "c", true: "a"}, 0)`, `{"true":"a","2":"b","10":"c"}`},
+		{`toJSON("say \"hi\"\n\\")`, `"say \"hi\"\n\\"`},
+		{`toJSON(-7)`, `-7`},
+		{`toJSON(0.25)`, `0.25`},
+		{`toJSON(fn(x) { x })`, "ERROR: cannot convert FUNCTION to JSON"},
+		{`toJSON([1, len])`, "ERROR: cannot convert BUILTIN to JSON"},
+		{`toJSON({"f": fn() { 1 }}, 2)`, "ERROR: cannot convert FUNCTION to JSON"},
+		{`toJSON(1e308 * 10)`, "ERROR: cannot convert +Inf to JSON"},
+		{`let a = [1]; a[0] = a; toJSON(a)`, "ERROR: cannot convert an ARRAY containing itself to JSON"},
+		{`let x = [1]; toJSON([x, x])`, `[[1],[1]]`},
+		{`toJSON({1: "a", "1": "b"})`, "ERROR: cannot convert a HASH with two keys 1 to JSON"},
+		{`toJSON(1, -2)`, "ERROR: indent of `toJSON` must not be negative, got -2"},
+		{`toJSON(1, "2")`, "ERROR: second argument to `toJSON` must be INTEGER, got STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: wrong result. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestPriorityQueue(t *testing.T) {
+	input := `
+let pq = pqNew();
+pqPush(pq, 5, "five");
+pqPush(pq, 1, "one");
+pqPush(pq, 3, "three");
+pqPush(pq, -2, "minus two");
+pqPush(pq, 3, "three again");
+[pqPop(pq), pqPop(pq), pqPop(pq), pqPop(pq), pqPop(pq)]
+`
+	evaluated := testEval(input)
+	expected := "[minus two, one, three, three again, five]"
+	if evaluated.Inspect() != expected {
+		t.Errorf("wrong pop order. want=%s, got=%s", expected, evaluated.Inspect())
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`pqPop(pqNew())`, nil},
+		{`let pq = pqNew(); pqPush(pq, 1, 10); pqPop(pq); pqPop(pq)`, nil},
+		{`pqPop(pqPush(pqNew(), 7, 70))`, 70},
+		{`pqNew(1)`, "wrong number of arguments. got=1, want=0"},
+		{`pqPush(pqNew(), 1)`, "wrong number of arguments. got=2, want=3"},
+		{`pqPush([], 1, 1)`, "argument to `pqPush` must be PRIORITY_QUEUE, got ARRAY"},
+		{`pqPush(pqNew(), "high", 1)`, "priority passed to `pqPush` must be INTEGER, got STRING"},
+		{`pqPop(1)`, "argument to `pqPop` must be PRIORITY_QUEUE, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`upper("hi")`, "HI"},
+		{`upper("")`, ""},
+		{`upper("MiXed 1!")`, "MIXED 1!"},
+		{`lower("HI")`, "hi"},
+		{`lower("")`, ""},
+		{`trim("  x  ")`, "x"},
+		{`trim("  x y ")`, "x y"},
+		{`trim("   ")`, ""},
+		{`trim("")`, ""},
+		{`replace("aaa", "a", "b")`, "bbb"},
+		{`replace("hello world", "o", "0")`, "hell0 w0rld"},
+		{`replace("aaa", "aa", "b")`, "ba"},
+		{`replace("abc", "x", "y")`, "abc"},
+		{`replace("abc", "b", "")`, "ac"},
+		{`replace("abc", "", "x")`, "abc"},
+		{`replace("", "a", "b")`, ""},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("%s: object is not String. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if str.Value != tt.expected {
+			t.Errorf("%s: wrong value. expected=%q, got=%q", tt.input, tt.expected, str.Value)
+		}
+	}
+}
+
+func TestParseInt(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`parseInt("42")`, 42},
+		{`parseInt("-42", 10)`, -42},
+		{`parseInt("ff", 16)`, 255},
+		{`parseInt("FF", 16)`, 255},
+		{`parseInt("101", 2)`, 5},
+		{`parseInt("777", 8)`, 511},
+		{`parseInt("z", 36)`, 35},
+		{`parseInt("abc", 10)`, `could not parse "abc" as an integer in base 10`},
+		{`parseInt("102", 2)`, `could not parse "102" as an integer in base 2`},
+		{`parseInt("")`, `could not parse "" as an integer in base 10`},
+		{`parseInt("0x1f", 16)`, `could not parse "0x1f" as an integer in base 16`},
+		{`parseInt("9223372036854775808")`, `"9223372036854775808" is out of range for an integer`},
+		{`parseInt("1", 1)`, "base of `parseInt` must be between 2 and 36, got 1"},
+		{`parseInt("1", 37)`, "base of `parseInt` must be between 2 and 36, got 37"},
+		{`parseInt("1", 0)`, "base of `parseInt` must be between 2 and 36, got 0"},
+		{`parseInt(1)`, "first argument to `parseInt` must be STRING, got INTEGER"},
+		{`parseInt("1", "2")`, "second argument to `parseInt` must be INTEGER, got STRING"},
+		{`parseInt()`, "wrong number of arguments. got=0, want=1 or 2"},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`format("{}-{}", 1, 2)`, "1-2"},
+		{`format("no placeholders")`, "no placeholders"},
+		{`format("{} is {}", "x", [1, true])`, "x is [1, true]"},
+		{`format("{}{}", 1.5, false)`, "1.5false"},
+		{`format("{{}} is {}", "empty")`, "{} is empty"},
+		{`format("{{{}}}", 1)`, "{1}"},
+		{`format("{", 1)`, "ERROR: wrong number of values for `format`. got=1, want=0"},
+		{`format("{} {}", 1)`, "ERROR: wrong number of values for `format`. got=1, want=2"},
+		{`format("{}", 1, 2)`, "ERROR: wrong number of values for `format`. got=2, want=1"},
+		{`format()`, "ERROR: wrong number of arguments. got=0, want at least 1"},
+		{`format(1)`, "ERROR: first argument to `format` must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		if evaluated := testEval(tt.input); evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestStringBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`upper(1)`, "argument to `upper` must be STRING, got INTEGER"},
+		{`upper("a", "b")`, "wrong number of arguments. got=2, want=1"},
+		{`lower([])`, "argument to `lower` must be STRING, got ARRAY"},
+		{`lower()`, "wrong number of arguments. got=0, want=1"},
+		{`trim(true)`, "argument to `trim` must be STRING, got BOOLEAN"},
+		{`trim()`, "wrong number of arguments. got=0, want=1"},
+		{`replace("a", "b")`, "wrong number of arguments. got=2, want=3"},
+		{`replace("a", 1, "b")`, "argument to `replace` must be STRING, got INTEGER"},
+		{`replace(1, "a", "b")`, "argument to `replace` must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestReadLine(t *testing.T) {
+	SetInput(strings.NewReader("first line\nsecond\r\n\nlast without newline"))
+	defer SetInput(os.Stdin)
+
+	expected := []interface{}{"first line", "second", "", "last without newline", nil, nil}
+	for i, want := range expected {
+		got := testEval("readLine()")
+		if want == nil {
+			testNullObject(t, got)
+			continue
+		}
+		str, ok := got.(*object.String)
+		if !ok {
+			t.Fatalf("readLine()[%d] is not String. got=%T (%+v)", i, got, got)
+		}
+		if str.Value != want {
+			t.Errorf("readLine()[%d] wrong. expected=%q, got=%q", i, want, str.Value)
+		}
+	}
+}
+
+func TestReadLineInProgram(t *testing.T) {
+	SetInput(strings.NewReader("Sam\n"))
+	defer SetInput(os.Stdin)
+
+	evaluated := testEval(`let name = readLine(); "hi " + name`)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+	if str.Value != "hi Sam" {
+		t.Errorf("wrong greeting. got=%q", str.Value)
+	}
+}
+
+// memoryFileSystem is a FileSystem keeping its files in a map.
+type memoryFileSystem map[string]string
+
+func (fs memoryFileSystem) ReadFile(name string) ([]byte, error) {
+	data, ok := fs[name]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return []byte(data), nil
+}
+
+func (fs memoryFileSystem) WriteFile(name string, data []byte) error {
+	if strings.HasPrefix(name, "/readonly/") {
+		return os.ErrPermission
+	}
+	fs[name] = string(data)
+	return nil
+}
+
+func TestFileBuiltins(t *testing.T) {
+	fs := memoryFileSystem{"notes.txt": "hello"}
+	SetFileSystem(fs)
+	defer SetFileSystem(osFileSystem{})
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len(readFile("notes.txt"))`, 5},
+		{`readFile("missing.txt")`, nil},
+		{`writeFile("out.txt", "abc"); len(readFile("out.txt"))`, 3},
+		{`readFile(1)`, "argument to `readFile` must be STRING, got INTEGER"},
+		{`writeFile("out.txt")`, "wrong number of arguments. got=1, want=2"},
+		{`writeFile("out.txt", 1)`, "arguments to `writeFile` must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+
+	testBooleanObject(t, testEval(`writeFile("a.txt", "round trip")`), true)
+	if fs["a.txt"] != "round trip" {
+		t.Errorf("file not written. got=%q", fs["a.txt"])
+	}
+	if got := testEval(`readFile("a.txt")`).Inspect(); got != "round trip" {
+		t.Errorf("wrong contents read back. got=%q", got)
+	}
+	testBooleanObject(t, testEval(`writeFile("/readonly/a.txt", "x")`), false)
+}
+
+func TestDisableBuiltins(t *testing.T) {
+	DisableBuiltins("readFile", "writeFile", "push", "notABuiltin")
+	defer func() { disabled = map[string]bool{} }()
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`readFile("secret.txt")`, "builtin `readFile` is disabled"},
+		{`writeFile("out.txt", "data")`, "builtin `writeFile` is disabled"},
+		{`"x".readFile()`, "builtin `readFile` is disabled"},
+		{`apply(readFile, ["secret.txt"])`, "builtin `readFile` is disabled"},
+		{`let b = [1]; let c = push(b, 2)`, "builtin `push` is disabled"},
+		// binding the result to the array itself still calls push
+		{`let a = [1]; a = push(a, 2); a`, "builtin `push` is disabled"},
+		{`let a = [1]; let a = push(a, 2); a`, "builtin `push` is disabled"},
+		{`let lines = ["a", "bb"]; len(lines) + len(first(lines) + last(lines))`, 5},
+		{`let f = fn(x) { x * 2 }; f(21)`, 42},
+		// a binding can still use a disabled name
+		{`let readFile = fn(path) { 1 }; readFile("secret.txt")`, 1},
+		{`notABuiltin`, "identifier not found: notABuiltin"},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestRegisterBuiltin(t *testing.T) {
+	err := RegisterBuiltin("double", func(args ...object.Object) object.Object {
+		if len(args) != 1 || args[0].Type() != object.INTEGER_OBJ {
+			return newError("double expects one INTEGER")
+		}
+		return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+	})
+	if err != nil {
+		t.Fatalf("RegisterBuiltin returned error: %s", err)
+	}
+	defer delete(hostBuiltins, "double")
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`double(21)`, 42},
+		{`let f = fn(x) { double(x) + 1 }; f(5)`, 11},
+		{`double("x")`, "double expects one INTEGER"},
+		// a let binding still shadows the builtin
+		{`let double = fn(x) { x }; double(3)`, 3},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestRegisterBuiltinCollisions(t *testing.T) {
+	err := RegisterBuiltin("len", func(args ...object.Object) object.Object { return NULL })
+	if err == nil {
+		t.Fatalf("registering a core builtin name didn't fail")
+	}
+	if err.Error() != `cannot register builtin "len": name is taken by a core builtin` {
+		t.Errorf("wrong error. got=%q", err.Error())
+	}
+	testIntegerObject(t, testEval(`len("abc")`), 3)
+
+	// registering a host builtin again replaces it
+	defer delete(hostBuiltins, "answer")
+	RegisterBuiltin("answer", func(args ...object.Object) object.Object { return &object.Integer{Value: 1} })
+	if err := RegisterBuiltin("answer", func(args ...object.Object) object.Object { return &object.Integer{Value: 42} }); err != nil {
+		t.Fatalf("re-registering a host builtin failed: %s", err)
+	}
+	testIntegerObject(t, testEval(`answer()`), 42)
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let add = fn(a, b) { a + b }; apply(add, [1, 2])`, 3},
+		{`apply(len, ["four"])`, 4},
+		{`apply(fn() { 7 }, [])`, 7},
+		{`let add = fn(a, b = 10) { a + b }; apply(add, [1])`, 11},
+		{`let add = fn(a, b) { a + b }; apply(add, [1, 2, 3])`, "wrong number of arguments: want=2, got=3"},
+		{`let add = fn(a, b) { a + b }; apply(add, [1])`, "wrong number of arguments: want=2, got=1"},
+		{`apply(len, [])`, "wrong number of arguments. got=0, want=1"},
+		{`apply(len, "four")`, "second argument to `apply` must be ARRAY, got STRING"},
+		{`apply([1], [])`, "first argument to `apply` must be FUNCTION, got ARRAY"},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestArityAndParams(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`arity(fn(a, b) {})`, 2},
+		{`arity(fn() { 1 })`, 0},
+		{`arity(fn(a, b = 2) { a + b })`, 2},
+		{`arity(len)`, 1},
+		{`arity(push)`, 2},
+		{`arity(pqNew)`, 0},
+		{`arity(slice)`, 3},
+		{`arity(concat)`, -1},
+		{`arity(puts)`, -1},
+		{`arity(compose(len, rest))`, -1},
+		{`arity(1)`, "argument to `arity` must be FUNCTION, got INTEGER"},
+		{`params(len)`, "`params` of a builtin function are unknown"},
+		{`params("f")`, "argument to `params` must be FUNCTION, got STRING"},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+
+	inspects := map[string]string{
+		`params(fn(a, b) {})`: `[a, b]`,
+		`params(fn() {})`:     `[]`,
+	}
+	for input, expected := range inspects {
+		if got := testEval(input).Inspect(); got != expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%s", input, expected, got)
+		}
+	}
+}
+
+// TestArityOfEveryBuiltin makes sure arity knows every builtin, so one added
+// without an entry in builtinArity doesn't report -1 unnoticed.
+func TestArityOfEveryBuiltin(t *testing.T) {
+	for name := range builtins {
+		arity, ok := builtinArity[name]
+		if !ok {
+			t.Errorf("builtin %s is missing from builtinArity", name)
+			continue
+		}
+		input := "arity(" + name + ")"
+		testExpectedObject(t, input, testEval(input), arity[1])
+	}
+	for name := range builtinArity {
+		if _, ok := builtins[name]; !ok {
+			t.Errorf("builtinArity has %s, which is not a builtin", name)
+		}
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`groupBy([1, 2, 3, 4], fn(x) { x % 2 })`, `{0: [2, 4], 1: [1, 3]}`},
+		{`groupBy(["apple", "banana", "avocado", "cherry", "blueberry"], fn(s) { slice(s, 0, 1) })`, `{a: [apple, avocado], b: [banana, blueberry], c: [cherry]}`},
+		{`groupBy(["bb", "a", "cc", "d"], len)`, `{1: [a, d], 2: [bb, cc]}`},
+		{`groupBy(["x", "y", "x"], fn(s) { s })`, `{x: [x, x], y: [y]}`},
+		{`groupBy([], fn(x) { x })`, `{}`},
+		{`groupBy([1, 2], fn(x) { x > 1 })`, `{false: [1], true: [2]}`},
+		{`groupBy([1], fn(x) { [x] })`, "ERROR: unusable as hash key: ARRAY"},
+		{`groupBy([1], fn(x) { x + true })`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{`groupBy(1, len)`, "ERROR: first argument to `groupBy` must be ARRAY, got INTEGER"},
+		{`groupBy([1], 1)`, "ERROR: second argument to `groupBy` must be FUNCTION, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		if evaluated := testEval(tt.input); evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestFrequencies(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`frequencies([1, 1, 2, 3, 3, 3])`, `{1: 2, 2: 1, 3: 3}`},
+		{`frequencies(["b", "a", "b", "a" + "b", "b"])`, `{a: 1, ab: 1, b: 3}`},
+		{`frequencies([1, "1", true, 1, false, true, "1"])`, `{1: 2, 1: 2, false: 1, true: 2}`},
+		{`let f = frequencies([1, "1", 1]); [f[1], f["1"]]`, `[2, 1]`},
+		{`frequencies([])`, `{}`},
+		{`frequencies([1, fn(x) { x }])`, "ERROR: unusable as hash key: FUNCTION"},
+		{`frequencies([[1]])`, "ERROR: unusable as hash key: ARRAY"},
+		{`frequencies("abc")`, "ERROR: argument to `frequencies` must be ARRAY, got STRING"},
+	}
+
+	for _, tt := range tests {
+		if evaluated := testEval(tt.input); evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestRepeat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let n = 0; repeat(3, fn(i) { n = n + 1 }); n`, `3`},
+		{`let seen = []; repeat(3, fn(i) { seen = push(seen, i) }); seen`, `[0, 1, 2]`},
+		{`repeat(2, fn(i) { i })`, `null`},
+		{`let n = 0; repeat(0, fn(i) { n = n + 1 }); n`, `0`},
+		{`let n = 0; repeat(-2, fn(i) { n = n + 1 }); n`, `0`},
+		{`repeat(3, fn(i) { i + true })`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{`repeat(3, 1)`, "ERROR: second argument to `repeat` must be FUNCTION, got INTEGER"},
+		{`repeat("3", len)`, "ERROR: first argument to `repeat` must be INTEGER, got STRING"},
+		{`repeat(3)`, "ERROR: wrong number of arguments. got=1, want=2"},
+	}
+
+	for _, tt := range tests {
+		if evaluated := testEval(tt.input); evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestBuiltinArity(t *testing.T) {
+	for name, builtin := range builtins {
+		min, max, ok := BuiltinArity(name)
+		if !ok {
+			t.Errorf("no arity for builtin %s", name)
+			continue
+		}
+		// the argument count is checked first, so the arguments themselves
+		// don't matter
+		counts := []int{min - 1}
+		if max >= 0 {
+			counts = append(counts, max+1)
+		}
+		for _, n := range counts {
+			if n < 0 {
+				continue
+			}
+			args := make([]object.Object, n)
+			for i := range args {
+				args[i] = NULL
+			}
+			result, ok := builtin.Fn(args...).(*object.Error)
+			if !ok || !strings.HasPrefix(result.Message, "wrong number of arguments") {
+				t.Errorf("%s with %d arguments doesn't report the wrong number. got=%v", name, n, result)
+			}
+		}
+	}
+	if _, _, ok := BuiltinArity("nope"); ok {
+		t.Errorf("arity for an unknown builtin")
+	}
+}
+
+func TestThrowAndTry(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`try(fn() { throw("boom"); 1 }, fn(err) { "caught " + err })`, "caught boom"},
+		{`try(fn() { 1 + 2 }, fn(err) { 0 })`, "3"},
+		{`try(fn() { 1 + true }, fn(err) { err })`, "type mismatch: INTEGER + BOOLEAN"},
+		{`try(fn() { throw([1, 2]) }, fn(err) { err })`, "[1, 2]"},
+		{`let f = fn(x) { if (x > 2) { throw("too big") } x }; try(fn() { f(1) + f(3) }, fn(err) { err })`, "too big"},
+		// nested: the inner handler passes the error on, the outer catches it
+		{`try(fn() { try(fn() { throw("inner") }, fn(err) { throw(err + "!") }) }, fn(err) { "outer " + err })`, "outer inner!"},
+		{`try(fn() { try(fn() { throw("inner") }, fn(err) { 1 }) + 1 }, fn(err) { 0 })`, "2"},
+		{`let x = 0; try(fn() { x = 1; throw("stop"); x = 2 }, fn(err) { x })`, "1"},
+		{`throw("boom"); 1`, "ERROR: boom"},
+		{`try(fn() { throw("a") }, fn(err) { throw("b") })`, "ERROR: b"},
+		{`try(1, fn(err) { err })`, "ERROR: argument to `try` must be FUNCTION, got INTEGER"},
+		{`throw()`, "ERROR: wrong number of arguments. got=0, want=1"},
+	}
+
+	for _, tt := range tests {
+		if evaluated := testEval(tt.input); evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestCount(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`count([1, 2, 2, 3], 2)`, 2},
+		{`count(["a", [1], "a", [1]], [1])`, 2},
+		{`count([1, 2, 3, 4], fn(x) { x > 2 })`, 2},
+		{`count([1, 2, 3], fn(x) { if (x == 2) { 1 } })`, 1},
+		{`count([1, 2, 3], 5)`, 0},
+		{`count([1, 2, 3], fn(x) { false })`, 0},
+		{`count([], 1)`, 0},
+		{`count([1], fn(x) { x + true })`, "type mismatch: INTEGER + BOOLEAN"},
+		{`count("aab", "a")`, "first argument to `count` must be ARRAY, got STRING"},
+		{`count([1])`, "wrong number of arguments. got=1, want=2"},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestMaxByAndMinBy(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`maxBy([{"a": 1}, {"a": 3}, {"a": 2}], fn(x) { x["a"] })`, "{a: 3}"},
+		{`minBy([{"a": 1}, {"a": 3}, {"a": 2}], fn(x) { x["a"] })`, "{a: 1}"},
+		{`maxBy([3, -5, 4], fn(x) { x * x })`, "-5"},
+		{`minBy([1, 2.5, -0.5], fn(x) { x })`, "-0.5"},
+		{`maxBy(["pear", "fig", "apple"], fn(s) { s })`, "pear"},
+		{`minBy([["b", 1], ["a", 2]], fn(p) { p[0] })`, "[a, 2]"},
+		{`maxBy(["ab", "cd", "e"], len)`, "ab"},
+		{`minBy([[2, "x"], [1, "y"], [1, "z"]], first)`, "[1, y]"},
+		{`maxBy([], fn(x) { x })`, "null"},
+		{`minBy([], fn(x) { x })`, "null"},
+		{`maxBy("abc", fn(x) { x })`, "ERROR: first argument to `maxBy` must be ARRAY, got STRING"},
+		{`minBy({"a": 1}, fn(x) { x })`, "ERROR: first argument to `minBy` must be ARRAY, got HASH"},
+		{`maxBy([1], 2)`, "ERROR: second argument to `maxBy` must be FUNCTION, got INTEGER"},
+		{`maxBy([1, 2], fn(x) { [x] })`, "ERROR: keys of `maxBy` must be numbers or strings, got ARRAY"},
+		{`minBy([1, "a"], fn(x) { x })`, "ERROR: `minBy` can't compare INTEGER and STRING"},
+		{`maxBy([1], fn(x) { x + true })`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestComposeAndPartial(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let inc = fn(x) { x + 1 }; let double = fn(x) { x * 2 }; compose(inc, double)(5)`, 11},
+		{`let inc = fn(x) { x + 1 }; let double = fn(x) { x * 2 }; compose(double, inc)(5)`, 12},
+		{`let add = fn(a, b) { a + b }; let addTen = partial(add, 10); addTen(5)`, 15},
+		{`let sub = fn(a, b, c) { a - b - c }; partial(sub, 10, 3)(2)`, 5},
+		// builtins work on both sides, and the results compose again
+		{`compose(len, rest)([1, 2, 3])`, 2},
+		{`let add = fn(a, b) { a + b }; compose(partial(add, 1), partial(add, 2))(3)`, 6},
+		{`compose(fn(x) { x }, fn(x) { x + true })(1)`, "type mismatch: INTEGER + BOOLEAN"},
+		{`compose(1, len)`, "argument to `compose` must be FUNCTION, got INTEGER"},
+		{`compose(len)`, "wrong number of arguments. got=1, want=2"},
+		{`partial(len)`, "wrong number of arguments. got=1, want at least 2"},
+		{`partial("f", 1)`, "first argument to `partial` must be FUNCTION, got STRING"},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+// testExpectedObject checks obj against expected, where an int means an
+// Integer, a []int an Array of Integers, a string an Error with that message
+// and nil means Null.
+func testExpectedObject(t *testing.T, input string, obj object.Object, expected interface{}) {
+	t.Helper()
+	switch expected := expected.(type) {
+	case int:
+		testIntegerObject(t, obj, int64(expected))
+	case string:
+		errObj, ok := obj.(*object.Error)
+		if !ok {
+			t.Errorf("%s: object is not Error. got=%T (%+v)", input, obj, obj)
+			return
+		}
+		if errObj.Message != expected {
+			t.Errorf("%s: wrong error message. expected=%q, got=%q", input, expected, errObj.Message)
+		}
+	case []int:
+		array, ok := obj.(*object.Array)
+		if !ok {
+			t.Errorf("%s: object is not Array. got=%T (%+v)", input, obj, obj)
+			return
+		}
+		if len(array.Elements) != len(expected) {
+			t.Errorf("%s: wrong num of elements. want=%d, got=%d", input, len(expected), len(array.Elements))
+			return
+		}
+		for i, expectedElem := range expected {
+			testIntegerObject(t, array.Elements[i], int64(expectedElem))
+		}
+	case nil:
+		testNullObject(t, obj)
+	}
+}
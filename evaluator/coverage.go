@@ -0,0 +1,46 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/token"
+	"sort"
+)
+
+// covered holds the positions of the statements that have been evaluated,
+// coverage is off while it is nil.
+var covered map[token.Position]bool
+
+// RecordCoverage turns recording of executed statements on or off. Turning it
+// on starts with an empty record.
+func RecordCoverage(on bool) {
+	if on {
+		covered = map[token.Position]bool{}
+	} else {
+		covered = nil
+	}
+}
+
+// Coverage returns the positions of the statements evaluated since coverage
+// was turned on, in source order. Every statement is listed once, however
+// often it ran, and statements in branches that weren't taken are missing.
+func Coverage() []token.Position {
+	positions := make([]token.Position, 0, len(covered))
+	for pos := range covered {
+		positions = append(positions, pos)
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i].Offset < positions[j].Offset
+	})
+	return positions
+}
+
+// recordCoverage marks node as executed if it's a statement. Blocks aren't
+// recorded themselves, only the statements in them.
+func recordCoverage(node ast.Node) {
+	if _, ok := node.(*ast.BlockStatement); ok {
+		return
+	}
+	if stmt, ok := node.(ast.Statement); ok {
+		covered[stmt.Pos()] = true
+	}
+}
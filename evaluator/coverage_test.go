@@ -0,0 +1,66 @@
+package evaluator
+
+import (
+	"monkey/token"
+	"testing"
+)
+
+func TestCoverage(t *testing.T) {
+	input := `let x = 1;
+if (x > 0) {
+  let y = 2;
+  y
+} else {
+  let z = 3;
+}
+let f = fn() { 4 };
+`
+
+	RecordCoverage(true)
+	defer RecordCoverage(false)
+	testEval(input)
+
+	expected := []token.Position{
+		{Offset: 0, Line: 1, Column: 1},
+		{Offset: 11, Line: 2, Column: 1},
+		{Offset: 26, Line: 3, Column: 3},
+		{Offset: 39, Line: 4, Column: 3},
+		{Offset: 65, Line: 8, Column: 1},
+	}
+
+	covered := Coverage()
+	if len(covered) != len(expected) {
+		t.Fatalf("wrong number of covered statements. want=%d, got=%+v", len(expected), covered)
+	}
+	for i, pos := range expected {
+		if covered[i] != pos {
+			t.Errorf("covered[%d] wrong. want=%+v, got=%+v", i, pos, covered[i])
+		}
+	}
+
+	for _, pos := range covered {
+		if pos.Line == 6 {
+			t.Errorf("statement in the else branch is covered: %+v", pos)
+		}
+	}
+}
+
+func TestCoverageCountsStatementsOnce(t *testing.T) {
+	RecordCoverage(true)
+	defer RecordCoverage(false)
+	testEval("let i = 0; while (i < 3) { i = i + 1 }")
+
+	if got := len(Coverage()); got != 3 {
+		t.Errorf("wrong number of covered statements. want=3, got=%d (%+v)", got, Coverage())
+	}
+}
+
+func TestCoverageOff(t *testing.T) {
+	RecordCoverage(true)
+	RecordCoverage(false)
+	testEval("let x = 1; x")
+
+	if got := Coverage(); len(got) != 0 {
+		t.Errorf("coverage recorded while off: %+v", got)
+	}
+}
@@ -0,0 +1,1323 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"monkey/ast"
+	"monkey/object"
+	"monkey/token"
+	"strings"
+)
+
+// there is only ever one true, one false and one null, so they can be
+// compared by pointer instead of by value
+var (
+	NULL  = &object.Null{}
+	TRUE  = &object.Boolean{Value: true}
+	FALSE = &object.Boolean{Value: false}
+
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
+)
+
+// MaxCallDepth limits how deeply user functions can be nested in calls, so a
+// runaway recursion gives an error instead of exhausting the Go stack. Zero
+// means no limit.
+var MaxCallDepth = 0
+
+// NullSafeLookup makes looking up something that isn't there give null
+// instead of an error: an unbound identifier is null, and so is indexing
+// null, so that `missing[0]` is null as well. Indexing an array out of range
+// and a hash with a missing key is null either way.
+var NullSafeLookup = false
+
+// WrapOverflow makes integer arithmetic wrap around on overflow, the way Go
+// int64 arithmetic does, so the largest integer + 1 is the smallest one.
+// That is the default. Without it an overflow is an error.
+var WrapOverflow = true
+
+// MaxRepeat limits how many characters repeating a string, as in "a" * 3,
+// and how many elements repeating an array, as in [0] * 3, can produce, so
+// a huge count gives an error instead of exhausting the memory of the host.
+// Zero means no limit other than what Go can allocate at all, beyond which
+// repeating is an error either way.
+var MaxRepeat = 0
+
+// OnAssign, if set, is called every time a let statement binds a name or an
+// assignment rebinds one, with the new value and the position of the name,
+// in the order the bindings happen. A destructuring let reports its names in
+// the order they appear in the pattern. Parameters being bound by a call and
+// assignments to an index aren't reported.
+var OnAssign func(name string, val object.Object, pos token.Position)
+
+// NativeInt returns the integer object for value, the interned one if value
+// is in the range of object.SmallInts.
+func NativeInt(value int64) *object.Integer {
+	return object.NewInteger(value)
+}
+
+// callDepth is the number of user function calls currently being evaluated.
+var callDepth = 0
+
+// Eval is a tree-walking evaluator: it evaluates the given node in env
+// and returns the resulting object.
+func Eval(node ast.Node, env *object.Environment) object.Object {
+	if covered != nil {
+		recordCoverage(node)
+	}
+	if traceOut != nil {
+		return traceEval(node, env)
+	}
+	return eval(node, env)
+}
+
+// eval does the actual work of Eval. Child nodes are evaluated through Eval,
+// so hooks like the trace see every node.
+func eval(node ast.Node, env *object.Environment) object.Object {
+	switch node := node.(type) {
+
+	// statements
+	case *ast.Program:
+		return evalProgram(node, env)
+
+	case *ast.ExpressionStatement:
+		return Eval(node.Expression, env)
+
+	case *ast.CommentStatement:
+		return nil
+
+	case *ast.BreakStatement:
+		return BREAK
+
+	case *ast.ContinueStatement:
+		return CONTINUE
+
+	case *ast.BlockStatement:
+		return evalBlockStatement(node, env)
+
+	case *ast.ReturnStatement:
+		if call, ok := node.ReturnValue.(*ast.CallExpression); ok && callDepth > 0 && traceOut == nil {
+			return evalReturnedCall(call, env)
+		}
+		return evalReturn(node.ReturnValue, env)
+
+	case *ast.LetStatement:
+		if node.Pattern != nil {
+			return evalDestructuring(node.Pattern, node.Value, env)
+		}
+		val, ok := evalSelfPush(node.Name, node.Value, env)
+		if !ok {
+			val = Eval(node.Value, env)
+		}
+		if isError(val) {
+			return val
+		}
+		env.Set(node.Name.Value, val)
+		if OnAssign != nil {
+			OnAssign(node.Name.Value, val, node.Name.Pos())
+		}
+
+	// expressions
+	case *ast.IntegerLiteral:
+		return NativeInt(node.Value)
+
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+
+	case *ast.StringLiteral:
+		return &object.String{Value: node.Value}
+
+	case *ast.Boolean:
+		return nativeBoolToBooleanObject(node.Value)
+
+	case *ast.PrefixExpression:
+		right := Eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
+		return evalPrefixExpression(node.Operator, right)
+
+	case *ast.GroupedExpression:
+		return Eval(node.Expression, env)
+
+	case *ast.PipeExpression:
+		return evalPipeExpression(node, env)
+
+	case *ast.Placeholder:
+		if val, ok := env.Get(node.Token.Literal); ok {
+			return val
+		}
+		return newError("`_` can only be used in the arguments of a call after |>")
+
+	case *ast.InfixExpression:
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		right := Eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
+		return evalInfixExpression(node.Operator, left, right)
+
+	case *ast.IfExpression:
+		return evalIfExpression(node, env)
+
+	case *ast.TemplateLiteral:
+		return evalTemplateLiteral(node, env)
+
+	case *ast.WhileExpression:
+		return evalWhileExpression(node, env)
+
+	case *ast.DoWhileExpression:
+		return evalDoWhileExpression(node, env)
+
+	case *ast.ForExpression:
+		return evalForExpression(node, env)
+
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
+
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
+
+	case *ast.BlockExpression:
+		return evalBlockExpression(node, env)
+
+	case *ast.Identifier:
+		return evalIdentifier(node, env)
+
+	case *ast.FunctionLiteral:
+		return &object.Function{Parameters: node.Parameters, Defaults: node.Defaults, Body: node.Body, Env: env}
+
+	case *ast.CallExpression:
+		if method, ok := ast.Unparen(node.Function).(*ast.DotExpression); ok {
+			return evalMethodCall(method, node.Arguments, env)
+		}
+		function := Eval(node.Function, env)
+		if isError(function) {
+			return function
+		}
+		args := evalCallArguments(function, node.Arguments, env)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0]
+		}
+		return applyFunction(function, args)
+
+	case *ast.ArrayLiteral:
+		if hasSpread(node.Elements) {
+			return evalSpreadArray(node.Elements, env)
+		}
+		elements := evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
+		}
+		return &object.Array{Elements: elements}
+
+	case *ast.SpreadElement:
+		return newError("`...` can only be used in array and hash literals and call arguments")
+
+	case *ast.IndexExpression:
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := Eval(node.Index, env)
+		if isError(index) {
+			return index
+		}
+		return evalIndexExpression(left, index)
+
+	case *ast.HashLiteral:
+		return evalHashLiteral(node, env)
+
+	case *ast.OptionalIndexExpression:
+		left := Eval(node.Left, env)
+		if isError(left) || left == NULL {
+			return left
+		}
+		return evalIndexExpression(left, Eval(node.Index, env))
+
+	case *ast.DotExpression:
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		if left.Type() != object.HASH_OBJ {
+			return newError("`.%s` can only be used on a hash or to call a method, got %s", node.Name.Value, left.Type())
+		}
+		return evalIndexExpression(left, &object.String{Value: node.Name.Value})
+	}
+
+	return nil
+}
+
+// evalProgram evaluates the statements of a program one after another
+// and unwraps a return value, since it is the outermost boundary.
+func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+	var result object.Object
+
+	for _, statement := range program.Statements {
+		if _, ok := statement.(*ast.CommentStatement); ok {
+			continue
+		}
+		if profiled != nil {
+			result = evalProfiled(statement, env)
+		} else {
+			result = Eval(statement, env)
+		}
+
+		switch result := result.(type) {
+		case *object.ReturnValue:
+			return result.Value
+		case *object.Error:
+			return result
+		}
+	}
+	return result
+}
+
+// evalBlockStatement is like evalProgram but keeps return values wrapped,
+// so a return inside a nested block also stops the outer blocks. So do
+// break and continue, up to the loop they belong to.
+func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+	var result object.Object
+
+	for _, statement := range block.Statements {
+		if _, ok := statement.(*ast.CommentStatement); ok {
+			continue
+		}
+		result = Eval(statement, env)
+
+		if result != nil {
+			rt := result.Type()
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ || isLoopControl(result) {
+				return result
+			}
+		}
+	}
+	return result
+}
+
+// isLoopControl tells if obj is the signal of a break or continue statement.
+func isLoopControl(obj object.Object) bool {
+	return obj == BREAK || obj == CONTINUE
+}
+
+// evalBlockExpression evaluates a block in its own scope, so its let
+// bindings don't leak out. The block yields the value of its last
+// statement if that's an expression statement and Null otherwise.
+func evalBlockExpression(be *ast.BlockExpression, env *object.Environment) object.Object {
+	result := evalBlockStatement(be.Block, object.NewEnclosedEnvironment(env))
+	if result != nil {
+		rt := result.Type()
+		if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ || isLoopControl(result) {
+			return result
+		}
+	}
+
+	stmts := be.Block.Statements
+	for len(stmts) > 0 {
+		if _, ok := stmts[len(stmts)-1].(*ast.CommentStatement); !ok {
+			break
+		}
+		stmts = stmts[:len(stmts)-1]
+	}
+	if len(stmts) == 0 {
+		return NULL
+	}
+	if _, ok := stmts[len(stmts)-1].(*ast.ExpressionStatement); !ok || result == nil {
+		return NULL
+	}
+	return result
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return TRUE
+	}
+	return FALSE
+}
+
+func evalPrefixExpression(operator string, right object.Object) object.Object {
+	switch operator {
+	case "!":
+		return evalBangOperatorExpression(right)
+	case "-":
+		return evalMinusPrefixOperatorExpression(right)
+	case "+":
+		// unary plus leaves numbers as they are
+		if isNumber(right) {
+			return right
+		}
+		return newError("unknown operator: +%s", right.Type())
+	default:
+		return newError("unknown operator: %s%s", operator, right.Type())
+	}
+}
+
+// evalBangOperatorExpression negates the truthiness of right.
+func evalBangOperatorExpression(right object.Object) object.Object {
+	switch right {
+	case TRUE:
+		return FALSE
+	case FALSE:
+		return TRUE
+	case NULL:
+		return TRUE
+	default:
+		return FALSE
+	}
+}
+
+func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		if !WrapOverflow && right.Value == math.MinInt64 {
+			return newError("integer overflow: -(%d)", right.Value)
+		}
+		return NativeInt(-right.Value)
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
+		return newError("unknown operator: -%s", right.Type())
+	}
+}
+
+func evalInfixExpression(operator string, left, right object.Object) object.Object {
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		// at least one is a float, the other one is converted
+		return evalFloatInfixExpression(operator, toFloat(left), toFloat(right))
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringInfixExpression(operator, left, right)
+	case operator == "*" && left.Type() == object.STRING_OBJ && isNumber(right):
+		return evalStringRepetition(left.(*object.String), right)
+	case operator == "*" && isNumber(left) && right.Type() == object.STRING_OBJ:
+		return evalStringRepetition(right.(*object.String), left)
+	case operator == "*" && left.Type() == object.ARRAY_OBJ && isNumber(right):
+		return evalArrayRepetition(left.(*object.Array), right)
+	case operator == "*" && isNumber(left) && right.Type() == object.ARRAY_OBJ:
+		return evalArrayRepetition(right.(*object.Array), left)
+	// booleans and null are singletons, so pointer comparison is enough.
+	// Other objects, like functions, are only equal to themselves.
+	case operator == "==":
+		return nativeBoolToBooleanObject(left == right)
+	case operator == "!=":
+		return nativeBoolToBooleanObject(left != right)
+	case left.Type() != right.Type():
+		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := left.(*object.Integer).Value
+	rightVal := right.(*object.Integer).Value
+
+	if !WrapOverflow && overflows(operator, leftVal, rightVal) {
+		return newError("integer overflow: %d %s %d", leftVal, operator, rightVal)
+	}
+
+	switch operator {
+	case "+":
+		return NativeInt(leftVal + rightVal)
+	case "-":
+		return NativeInt(leftVal - rightVal)
+	case "*":
+		return NativeInt(leftVal * rightVal)
+	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return NativeInt(leftVal / rightVal)
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return NativeInt(leftVal % rightVal)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// overflows reports whether left operator right is out of the range of int64.
+func overflows(operator string, left, right int64) bool {
+	switch operator {
+	case "+":
+		sum := left + right
+		return (left > 0 && right > 0 && sum < 0) || (left < 0 && right < 0 && sum >= 0)
+	case "-":
+		diff := left - right
+		return (left >= 0 && right < 0 && diff < 0) || (left < 0 && right > 0 && diff >= 0)
+	case "*":
+		if left == 0 || right == 0 {
+			return false
+		}
+		product := left * right
+		return product/right != left || (left == -1 && right == math.MinInt64) || (right == -1 && left == math.MinInt64)
+	case "/":
+		return left == math.MinInt64 && right == -1
+	default:
+		return false
+	}
+}
+
+func evalFloatInfixExpression(operator string, leftVal, rightVal float64) object.Object {
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", object.FLOAT_OBJ, operator, object.FLOAT_OBJ)
+	}
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+// toFloat returns the value of an Integer or Float as a float64.
+func toFloat(obj object.Object) float64 {
+	if i, ok := obj.(*object.Integer); ok {
+		return float64(i.Value)
+	}
+	return obj.(*object.Float).Value
+}
+
+func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// evalStringRepetition evaluates str * count and count * str, str repeated
+// count times. A count of zero or less gives the empty string.
+func evalStringRepetition(str *object.String, count object.Object) object.Object {
+	n, ok := count.(*object.Integer)
+	if !ok {
+		return newError("string repetition count must be INTEGER, got %s", count.Type())
+	}
+	if n.Value <= 0 {
+		return &object.String{Value: ""}
+	}
+	if MaxRepeat > 0 {
+		length := int64(str.Len())
+		if length > 0 && n.Value > int64(MaxRepeat)/length {
+			return newError("string repetition too long: %d * %d characters, the limit is %d", n.Value, length, MaxRepeat)
+		}
+	}
+	if size := int64(len(str.Value)); size > 0 && n.Value > maxAlloc/size {
+		return newError("string repetition too long: %d * %d bytes don't fit in memory", n.Value, size)
+	}
+	return &object.String{Value: strings.Repeat(str.Value, int(n.Value))}
+}
+
+// maxAlloc is the most bytes a repetition can produce even without
+// MaxRepeat. Go refuses a single allocation of 1<<48 bytes or more on 64-bit
+// platforms, and of more than an int holds on 32-bit ones, and does so with a
+// panic, so a result that large is an error here instead.
+const maxAlloc = min(math.MaxInt, 1<<47)
+
+// maxElements is the most elements repeating an array can produce, each
+// taking the two words of an interface value.
+const maxElements = maxAlloc / 16
+
+// evalArrayRepetition evaluates arr * count and count * arr, the elements of
+// arr repeated count times. The elements themselves aren't copied, so
+// `[[0]] * 2` holds the same inner array twice. A count of zero or less gives
+// the empty array.
+func evalArrayRepetition(arr *object.Array, count object.Object) object.Object {
+	n, ok := count.(*object.Integer)
+	if !ok {
+		return newError("array repetition count must be INTEGER, got %s", count.Type())
+	}
+	length := int64(len(arr.Elements))
+	if n.Value <= 0 || length == 0 {
+		return &object.Array{Elements: []object.Object{}}
+	}
+	if MaxRepeat > 0 && n.Value > int64(MaxRepeat)/length {
+		return newError("array repetition too long: %d * %d elements, the limit is %d", n.Value, length, MaxRepeat)
+	}
+	if n.Value > maxElements/length {
+		return newError("array repetition too long: %d * %d elements don't fit in memory", n.Value, length)
+	}
+	elements := make([]object.Object, 0, length*n.Value)
+	for i := int64(0); i < n.Value; i++ {
+		elements = append(elements, arr.Elements...)
+	}
+	return &object.Array{Elements: elements}
+}
+
+// evalPipeExpression evaluates `<left> |> <right>` as the call Piped
+// returns. The placeholders in it are bound to the value of left, in a scope
+// of their own around the call's arguments, so `_` can't be bound by a
+// program otherwise.
+func evalPipeExpression(pe *ast.PipeExpression, env *object.Environment) object.Object {
+	val := Eval(pe.Left, env)
+	if isError(val) {
+		return val
+	}
+	scope := object.NewEnclosedEnvironment(env)
+	scope.Set("_", val)
+	return Eval(pe.Piped(), scope)
+}
+
+// evalIfExpression evaluates to the value of the branch taken. That is Null
+// when the branch has no value, like one ending in a let statement, and when
+// the condition is falsy and there is no else branch.
+func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := Eval(ie.Condition, env)
+	if isError(condition) {
+		return condition
+	}
+
+	var result object.Object
+	if isTruthy(condition) {
+		result = Eval(ie.Consequence, env)
+	} else if ie.Alternative != nil {
+		result = Eval(ie.Alternative, env)
+	}
+	if result == nil {
+		return NULL
+	}
+	return result
+}
+
+// evalTemplateLiteral builds the string of a template, with every value in
+// it printed the way puts would print it.
+func evalTemplateLiteral(tl *ast.TemplateLiteral, env *object.Environment) object.Object {
+	values := evalExpressions(tl.Values, env)
+	if len(values) == 1 && isError(values[0]) {
+		return values[0]
+	}
+
+	var out strings.Builder
+	for i, part := range tl.Parts {
+		out.WriteString(part)
+		if i < len(values) {
+			out.WriteString(values[i].Inspect())
+		}
+	}
+	return &object.String{Value: out.String()}
+}
+
+// evalWhileExpression runs the body for as long as the condition is truthy.
+// A return or an error inside the body ends the loop and is passed on,
+// otherwise the loop evaluates to Null. A break ends the loop as well, a
+// continue only the current iteration.
+func evalWhileExpression(we *ast.WhileExpression, env *object.Environment) object.Object {
+	for {
+		condition := Eval(we.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return NULL
+		}
+
+		result := Eval(we.Body, env)
+		if result == BREAK {
+			return NULL
+		}
+		if result != nil {
+			rt := result.Type()
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+				return result
+			}
+		}
+	}
+}
+
+// evalDoWhileExpression is evalWhileExpression with the condition checked
+// after the body, so the body always runs at least once.
+func evalDoWhileExpression(dw *ast.DoWhileExpression, env *object.Environment) object.Object {
+	for {
+		result := Eval(dw.Body, env)
+		if result == BREAK {
+			return NULL
+		}
+		if result != nil {
+			rt := result.Type()
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+				return result
+			}
+		}
+
+		condition := Eval(dw.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return NULL
+		}
+	}
+}
+
+// evalDestructuring binds the names in pattern to the matching parts of the
+// value. Nothing is bound unless the whole value fits the pattern.
+func evalDestructuring(pattern ast.Expression, value ast.Expression, env *object.Environment) object.Object {
+	val := Eval(value, env)
+	if isError(val) {
+		return val
+	}
+
+	bindings := map[string]object.Object{}
+	if err := destructure(pattern, val, bindings); err != nil {
+		return err
+	}
+	for name, val := range bindings {
+		env.Set(name, val)
+	}
+	if OnAssign != nil {
+		reportPattern(pattern, bindings)
+	}
+	return nil
+}
+
+// reportPattern calls OnAssign for the names bound by a destructuring
+// pattern, in the order they appear in it.
+func reportPattern(pattern ast.Expression, bindings map[string]object.Object) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		OnAssign(pattern.Value, bindings[pattern.Value], pattern.Pos())
+	case *ast.ArrayPattern:
+		for _, el := range pattern.Elements {
+			reportPattern(el, bindings)
+		}
+	case *ast.HashPattern:
+		for _, value := range pattern.Values {
+			reportPattern(value, bindings)
+		}
+	}
+}
+
+// destructure matches val against pattern and adds the names it binds to
+// bindings. Arrays must have exactly as many elements as the pattern, hashes
+// must have every key of the pattern, as a string, but may have more.
+func destructure(pattern ast.Expression, val object.Object, bindings map[string]object.Object) *object.Error {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		bindings[pattern.Value] = val
+
+	case *ast.ArrayPattern:
+		arr, ok := val.(*object.Array)
+		if !ok {
+			return newError("cannot destructure %s with an array pattern", val.Type())
+		}
+		if len(arr.Elements) != len(pattern.Elements) {
+			return newError("wrong number of elements to destructure: want=%d, got=%d", len(pattern.Elements), len(arr.Elements))
+		}
+		for i, el := range pattern.Elements {
+			if err := destructure(el, arr.Elements[i], bindings); err != nil {
+				return err
+			}
+		}
+
+	case *ast.HashPattern:
+		hash, ok := val.(*object.Hash)
+		if !ok {
+			return newError("cannot destructure %s with a hash pattern", val.Type())
+		}
+		for i, key := range pattern.Keys {
+			pair, ok := hash.Pairs[(&object.String{Value: key.Value}).HashKey()]
+			if !ok {
+				return newError("missing key to destructure: %q", key.Value)
+			}
+			if err := destructure(pattern.Values[i], pair.Value, bindings); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// evalForExpression runs the body once for every element of an array or a
+// range. The variable is bound in a scope of its own for every iteration, so
+// closures made in the body see the element of their iteration. Ranges are
+// iterated without ever creating all their elements. Like a while loop it
+// evaluates to Null, and a return, an error or a break ends it.
+func evalForExpression(fe *ast.ForExpression, env *object.Environment) object.Object {
+	iterable := Eval(fe.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	var length int64
+	var at func(i int64) object.Object
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		elements := iterable.Elements
+		length = int64(len(elements))
+		at = func(i int64) object.Object { return elements[i] }
+	case *object.Range:
+		length = iterable.Len()
+		at = iterable.At
+	default:
+		return newError("cannot iterate over %s", iterable.Type())
+	}
+
+	for i := int64(0); i < length; i++ {
+		scope := object.NewEnclosedEnvironment(env)
+		scope.Set(fe.Variable.Value, at(i))
+
+		result := Eval(fe.Body, scope)
+		if result == BREAK {
+			return NULL
+		}
+		if result != nil {
+			rt := result.Type()
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+				return result
+			}
+		}
+	}
+	return NULL
+}
+
+// evalMatchExpression evaluates the subject once and then the patterns in
+// order, until one equals the subject. Only the value of that arm is
+// evaluated. It is an error if no arm matches.
+func evalMatchExpression(me *ast.MatchExpression, env *object.Environment) object.Object {
+	subject := Eval(me.Subject, env)
+	if isError(subject) {
+		return subject
+	}
+
+	for _, arm := range me.Arms {
+		if arm.Pattern != nil {
+			pattern := Eval(arm.Pattern, env)
+			if isError(pattern) {
+				return pattern
+			}
+			if !object.Equals(subject, pattern) {
+				continue
+			}
+		}
+		return Eval(arm.Value, env)
+	}
+	return newError("no match arm for %s", subject.Inspect())
+}
+
+// evalAssignExpression rebinds a name in the scope that declared it and
+// yields the assigned value. Only names bound by let or as parameters can
+// be assigned, `x = 1` never creates a new binding.
+func evalAssignExpression(ae *ast.AssignExpression, env *object.Environment) object.Object {
+	if ae.Index != nil {
+		return evalIndexAssignment(ae.Index, ae.Value, env)
+	}
+	val, ok := evalSelfPush(ae.Name, ae.Value, env)
+	if !ok {
+		val = Eval(ae.Value, env)
+	}
+	if isError(val) {
+		return val
+	}
+	if !env.Assign(ae.Name.Value, val) {
+		return newError("cannot assign to undeclared identifier: %s", ae.Name.Value)
+	}
+	if OnAssign != nil {
+		OnAssign(ae.Name.Value, val, ae.Name.Pos())
+	}
+	return val
+}
+
+// evalIndexAssignment replaces an element of an array or stores a value in a
+// hash, in place: every reference to the array or hash sees the change. An
+// array index has to be in range, arrays don't grow by assignment.
+func evalIndexAssignment(ie *ast.IndexExpression, value ast.Expression, env *object.Environment) object.Object {
+	left := Eval(ie.Left, env)
+	if isError(left) {
+		return left
+	}
+	index := Eval(ie.Index, env)
+	if isError(index) {
+		return index
+	}
+	val := Eval(value, env)
+	if isError(val) {
+		return val
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		idx, ok := index.(*object.Integer)
+		if !ok {
+			return newError("array index must be INTEGER, got %s", index.Type())
+		}
+		if idx.Value < 0 || idx.Value >= int64(len(left.Elements)) {
+			return newError("index %d out of range for array of length %d", idx.Value, len(left.Elements))
+		}
+		left.Set(int(idx.Value), val)
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+		left.Pairs[key.HashKey()] = object.HashPair{Key: index, Value: val}
+	default:
+		return newError("index assignment not supported: %s", left.Type())
+	}
+	return val
+}
+
+// evalSelfPush evaluates `push(name, x)` when its result is bound to name
+// again, as in `a = push(a, x)` and `let a = push(a, x)`. That's the usual
+// way to build an array in a loop, and copying the array every time would
+// make it quadratic. Instead the array is grown with Array.Append, which
+// keeps every other reference to the old array unchanged.
+//
+// It reports false when value isn't such a call, or when push isn't the
+// builtin, because a binding shadows it or it is disabled, and the caller
+// has to evaluate value as usual.
+func evalSelfPush(name *ast.Identifier, value ast.Expression, env *object.Environment) (object.Object, bool) {
+	call, ok := value.(*ast.CallExpression)
+	if !ok || len(call.Arguments) != 2 {
+		return nil, false
+	}
+	fn, ok := call.Function.(*ast.Identifier)
+	if !ok || fn.Value != "push" {
+		return nil, false
+	}
+	if _, shadowed := env.Get("push"); shadowed {
+		return nil, false
+	}
+	if builtin, _ := lookupBuiltin("push"); builtin != builtins["push"] {
+		return nil, false
+	}
+	target, ok := call.Arguments[0].(*ast.Identifier)
+	if !ok || target.Value != name.Value {
+		return nil, false
+	}
+	current, _ := env.Get(name.Value)
+	arr, ok := current.(*object.Array)
+	if !ok {
+		return nil, false // let push report the error
+	}
+
+	el := Eval(call.Arguments[1], env)
+	if isError(el) {
+		return el, true
+	}
+	return arr.Append(el), true
+}
+
+// isTruthy reports whether obj counts as true in a condition:
+// everything except false and null does.
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case NULL:
+		return false
+	case TRUE:
+		return true
+	case FALSE:
+		return false
+	default:
+		return true
+	}
+}
+
+func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	if val, ok := env.Get(node.Value); ok {
+		return val
+	}
+	if builtin, ok := lookupBuiltin(node.Value); ok {
+		return builtin
+	}
+	if NullSafeLookup {
+		return NULL
+	}
+	return newError("identifier not found: " + node.Value)
+}
+
+// evalMethodCall calls `<left>.<name>(<args>)` as `name(<left>, <args>)`.
+// Any function can be called that way, those bound in env and the builtins.
+func evalMethodCall(method *ast.DotExpression, arguments []ast.Expression, env *object.Environment) object.Object {
+	name := method.Name.Value
+	function, ok := env.Get(name)
+	if !ok {
+		builtin, found := lookupBuiltin(name)
+		if !found {
+			return newError("method not found: %s", name)
+		}
+		function = builtin
+	}
+
+	exps := append([]ast.Expression{method.Left}, arguments...)
+	args := evalCallArguments(function, exps, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+	return applyFunction(function, args)
+}
+
+func evalIndexExpression(left, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.HASH_OBJ:
+		return evalHashIndexExpression(left, index)
+	case left == NULL && NullSafeLookup:
+		return NULL
+	default:
+		return newError("index operator not supported: %s", left.Type())
+	}
+}
+
+// evalArrayIndexExpression returns the element at index, or null if the
+// index is out of range.
+func evalArrayIndexExpression(array, index object.Object) object.Object {
+	arrayObject := array.(*object.Array)
+	idx := index.(*object.Integer).Value
+	max := int64(len(arrayObject.Elements) - 1)
+
+	if idx < 0 || idx > max {
+		return NULL
+	}
+	return arrayObject.Elements[idx]
+}
+
+// evalHashIndexExpression returns the value stored under index, or null if
+// there is none.
+func evalHashIndexExpression(hash, index object.Object) object.Object {
+	hashObject := hash.(*object.Hash)
+
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newError("unusable as hash key: %s", index.Type())
+	}
+
+	pair, ok := hashObject.Pairs[key.HashKey()]
+	if !ok {
+		return NULL
+	}
+	return pair.Value
+}
+
+// evalHashLiteral evaluates the pairs in source order, so a repeated key
+// keeps the last value. That goes for the pairs of a spread hash as well:
+// in {...base, "x": 1}, the "x" of base is overridden.
+func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	pairs := make(map[object.HashKey]object.HashPair)
+
+	for _, pair := range node.Pairs {
+		if spread, ok := pair.Key.(*ast.SpreadElement); ok {
+			value := Eval(spread.Value, env)
+			if isError(value) {
+				return value
+			}
+			hash, ok := value.(*object.Hash)
+			if !ok {
+				return newError("cannot spread %s into a hash", value.Type())
+			}
+			for key, pair := range hash.Pairs {
+				pairs[key] = pair
+			}
+			continue
+		}
+
+		key := Eval(pair.Key, env)
+		if isError(key) {
+			return key
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+
+		value := Eval(pair.Value, env)
+		if isError(value) {
+			return value
+		}
+
+		pairs[hashKey.HashKey()] = object.HashPair{Key: key, Value: value}
+	}
+	return &object.Hash{Pairs: pairs}
+}
+
+func hasSpread(exps []ast.Expression) bool {
+	for _, exp := range exps {
+		if _, ok := exp.(*ast.SpreadElement); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// evalSpreadArray evaluates the elements of an array literal some of which
+// are spread, replacing every spread array by its elements.
+func evalSpreadArray(exps []ast.Expression, env *object.Environment) object.Object {
+	elements := evalSpreadElements(exps, env, "an array")
+	if len(elements) == 1 && isError(elements[0]) {
+		return elements[0]
+	}
+	return &object.Array{Elements: elements}
+}
+
+// evalSpreadElements evaluates exps like evalExpressions does, except that
+// every spread array is replaced by its elements. Into names what they are
+// spread into, for the error about spreading something else.
+func evalSpreadElements(exps []ast.Expression, env *object.Environment, into string) []object.Object {
+	elements := []object.Object{}
+	for _, exp := range exps {
+		spread, ok := exp.(*ast.SpreadElement)
+		if !ok {
+			el := Eval(exp, env)
+			if isError(el) {
+				return []object.Object{el}
+			}
+			elements = append(elements, el)
+			continue
+		}
+
+		value := Eval(spread.Value, env)
+		if isError(value) {
+			return []object.Object{value}
+		}
+		arr, ok := value.(*object.Array)
+		if !ok {
+			return []object.Object{newError("cannot spread %s into %s", value.Type(), into)}
+		}
+		elements = append(elements, arr.Elements...)
+	}
+	return elements
+}
+
+// evalExpressions evaluates exps from left to right. If one of them
+// produces an error, that error is returned as the only element.
+func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+	var result []object.Object
+
+	for _, e := range exps {
+		evaluated := Eval(e, env)
+		if isError(evaluated) {
+			return []object.Object{evaluated}
+		}
+		result = append(result, evaluated)
+	}
+	return result
+}
+
+// evalCallArguments evaluates the arguments of a call to fn. Without named
+// arguments that's just evalExpressions, or evalSpreadElements for spread
+// arguments, `f(...args)`, which pass the elements of an array as positional
+// arguments. Otherwise the result is ordered by the parameters of fn:
+// positional arguments fill the first parameters, named ones the parameter
+// with their name. Errors are returned like evalExpressions does.
+func evalCallArguments(fn object.Object, exps []ast.Expression, env *object.Environment) []object.Object {
+	if !hasNamedArgument(exps) {
+		if hasSpread(exps) {
+			return evalSpreadElements(exps, env, "the arguments of a call")
+		}
+		return evalExpressions(exps, env)
+	}
+	if hasSpread(exps) {
+		return []object.Object{newError("spread arguments can't be combined with named arguments")}
+	}
+
+	function, ok := fn.(*object.Function)
+	if !ok {
+		return []object.Object{newError("named arguments are not supported for %s", fn.Type())}
+	}
+
+	args := make([]object.Object, len(function.Parameters))
+	for i, exp := range exps {
+		idx := i
+		if named, ok := exp.(*ast.NamedArgument); ok {
+			idx = parameterIndex(function, named.Name.Value)
+			if idx < 0 {
+				return []object.Object{newError("unknown parameter name: %s", named.Name.Value)}
+			}
+			exp = named.Value
+		} else if idx >= len(args) {
+			return []object.Object{newError("wrong number of arguments: want=%d, got=%d", len(args), len(exps))}
+		}
+		if args[idx] != nil {
+			return []object.Object{newError("duplicate argument for parameter %s", function.Parameters[idx].Value)}
+		}
+
+		evaluated := Eval(exp, env)
+		if isError(evaluated) {
+			return []object.Object{evaluated}
+		}
+		args[idx] = evaluated
+	}
+
+	// parameters that weren't given are left nil for applyUserFunction
+	// to fill in with their defaults
+	return args
+}
+
+func hasNamedArgument(exps []ast.Expression) bool {
+	for _, exp := range exps {
+		if _, ok := exp.(*ast.NamedArgument); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// parameterIndex returns the index of the parameter called name, or -1.
+func parameterIndex(fn *object.Function, name string) int {
+	for i, param := range fn.Parameters {
+		if param.Value == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// applyFunction calls fn with args. The body of a user defined function is
+// evaluated in a new environment enclosed by the one it was defined in.
+func applyFunction(fn object.Object, args []object.Object) object.Object {
+	switch fn := fn.(type) {
+	case *object.Function:
+		return applyUserFunction(fn, args)
+	case *object.Builtin:
+		return fn.Fn(args...)
+	default:
+		return newError("not a function: %s", fn.Type())
+	}
+}
+
+// applyUserFunction evaluates the body of function with its parameters bound to args.
+// Parameters without an argument, because args is short or has a nil left by
+// named arguments, get their default value.
+//
+// When the body returns a tailCall, that call is made in its place, in the
+// same loop, so tail calls don't count towards MaxCallDepth.
+func applyUserFunction(function *object.Function, args []object.Object) object.Object {
+	if MaxCallDepth > 0 && callDepth >= MaxCallDepth {
+		return newError("stack overflow: max call depth exceeded")
+	}
+	callDepth++
+	defer func() { callDepth-- }()
+
+	for {
+		if len(args) > len(function.Parameters) {
+			return newError("wrong number of arguments: want=%d, got=%d", len(function.Parameters), len(args))
+		}
+		if len(args) < len(function.Parameters) || hasMissingArgument(args) {
+			var err *object.Error
+			args, err = fillDefaults(function, args)
+			if err != nil {
+				return err
+			}
+		}
+
+		extendedEnv := extendFunctionEnv(function, args)
+		evaluated := unwrapReturnValue(Eval(function.Body, extendedEnv))
+		call, ok := evaluated.(*tailCall)
+		if !ok {
+			return evaluated
+		}
+		function, args = call.function, call.args
+	}
+}
+
+func hasMissingArgument(args []object.Object) bool {
+	for _, arg := range args {
+		if arg == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// fillDefaults returns args extended to one argument per parameter, using the
+// defaults for the missing ones. Defaults are evaluated on every call, in the
+// environment the function was defined in.
+func fillDefaults(function *object.Function, args []object.Object) ([]object.Object, *object.Error) {
+	filled := make([]object.Object, len(function.Parameters))
+	copy(filled, args)
+
+	for i, arg := range filled {
+		if arg != nil {
+			continue
+		}
+		if i >= len(function.Defaults) || function.Defaults[i] == nil {
+			if i < len(args) {
+				return nil, newError("missing argument for parameter %s", function.Parameters[i].Value)
+			}
+			return nil, newError("wrong number of arguments: want=%d, got=%d", len(function.Parameters), len(args))
+		}
+
+		val := Eval(function.Defaults[i], function.Env)
+		if err, ok := val.(*object.Error); ok {
+			return nil, err
+		}
+		filled[i] = val
+	}
+	return filled, nil
+}
+
+func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+	env := object.NewEnclosedEnvironment(fn.Env)
+	for paramIdx, param := range fn.Parameters {
+		env.Set(param.Value, args[paramIdx])
+	}
+	return env
+}
+
+// unwrapReturnValue stops a return value from bubbling up past the function
+// it was returned from.
+func unwrapReturnValue(obj object.Object) object.Object {
+	if returnValue, ok := obj.(*object.ReturnValue); ok {
+		return returnValue.Value
+	}
+	return obj
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+func isError(obj object.Object) bool {
+	if obj != nil {
+		return obj.Type() == object.ERROR_OBJ
+	}
+	return false
+}
@@ -0,0 +1,1449 @@
+package evaluator
+
+import (
+	"math"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/token"
+	"strings"
+	"testing"
+)
+
+func TestEvalIntegerExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"5", 5},
+		{"-10", -10},
+		{"5 + 5 + 5 + 5 - 10", 10},
+		{"2 * 2 * 2 * 2 * 2", 32},
+		{"-50 + 100 + -50", 0},
+		{"20 + 2 * -10", 0},
+		{"50 / 2 * 2 + 10", 60},
+		{"3 * (3 * 3) + 10", 37},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"+5", 5},
+		{"+(-3)", -3},
+		{"1 - +2", -1},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"1 + 10 % 4 * 2", 5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestIntegerOverflow(t *testing.T) {
+	const (
+		maxInt = "9223372036854775807"
+		minInt = "(-9223372036854775807 - 1)"
+	)
+	tests := []struct {
+		input   string
+		wrapped int64
+		err     string
+	}{
+		{maxInt + " + 1", math.MinInt64, "integer overflow: 9223372036854775807 + 1"},
+		{minInt + " - 1", math.MaxInt64, "integer overflow: -9223372036854775808 - 1"},
+		{"-1 - " + maxInt + " - 1", math.MaxInt64, "integer overflow: -9223372036854775808 - 1"},
+		{maxInt + " * 2", -2, "integer overflow: 9223372036854775807 * 2"},
+		{minInt + " * -1", math.MinInt64, "integer overflow: -9223372036854775808 * -1"},
+		{"-1 * " + minInt, math.MinInt64, "integer overflow: -1 * -9223372036854775808"},
+		{minInt + " / -1", math.MinInt64, "integer overflow: -9223372036854775808 / -1"},
+		{"-" + minInt, math.MinInt64, "integer overflow: -(-9223372036854775808)"},
+		// no overflow
+		{maxInt + " + 0", math.MaxInt64, ""},
+		{maxInt + " - 1 + 1", math.MaxInt64, ""},
+		{minInt + " + " + maxInt, -1, ""},
+		{"3037000499 * 3037000499", 9223372030926249001, ""},
+		{minInt + " / 1", math.MinInt64, ""},
+		{"-" + maxInt, -math.MaxInt64, ""},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.wrapped)
+	}
+
+	WrapOverflow = false
+	defer func() { WrapOverflow = true }()
+	for _, tt := range tests {
+		if tt.err == "" {
+			testIntegerObject(t, testEval(tt.input), tt.wrapped)
+		} else {
+			testExpectedObject(t, tt.input, testEval(tt.input), tt.err)
+		}
+	}
+}
+
+func TestSmallInts(t *testing.T) {
+	if NativeInt(5) != NativeInt(5) {
+		t.Errorf("NativeInt(5) isn't interned")
+	}
+	if NativeInt(-128) != NativeInt(-128) || NativeInt(255) != NativeInt(255) {
+		t.Errorf("the ends of the range aren't interned")
+	}
+	if NativeInt(256) == NativeInt(256) || NativeInt(-129) == NativeInt(-129) {
+		t.Errorf("integers outside of the range are interned")
+	}
+	if got := NativeInt(200).Value; got != 200 {
+		t.Errorf("wrong value. expected=200, got=%d", got)
+	}
+	if testEval("2 + 3") != NativeInt(5) {
+		t.Errorf("arithmetic doesn't give the interned integer")
+	}
+
+	elements := testEval("let a = []; for (i in range(3)) { a = push(a, i) }; a").(*object.Array).Elements
+	for i, el := range elements {
+		if el != NativeInt(int64(i)) {
+			t.Errorf("element %d of a for over a range isn't interned", i)
+		}
+	}
+
+	object.SmallInts = [2]int64{0, 1000}
+	defer func() { object.SmallInts = [2]int64{-128, 255} }()
+	if NativeInt(1000) != NativeInt(1000) || NativeInt(-1) == NativeInt(-1) {
+		t.Errorf("the range isn't configurable")
+	}
+	if got := NativeInt(700).Value; got != 700 {
+		t.Errorf("wrong value. expected=700, got=%d", got)
+	}
+	object.SmallInts = [2]int64{1, 0}
+	if NativeInt(0) == NativeInt(0) {
+		t.Errorf("an empty range interns integers")
+	}
+}
+
+func BenchmarkCountingLoop(b *testing.B) {
+	loops := map[string]string{
+		"while": "let i = 0; while (i < 200) { i = i + 1 }",
+		"for":   "let n = 0; for (i in range(200)) { n = i }",
+	}
+	defer func() { object.SmallInts = [2]int64{-128, 255} }()
+	for _, loop := range []string{"while", "for"} {
+		program := parser.New(lexer.New(loops[loop])).ParseProgram()
+		for _, tt := range []struct {
+			name  string
+			small [2]int64
+		}{{"interned", [2]int64{-128, 255}}, {"allocated", [2]int64{1, 0}}} {
+			object.SmallInts = tt.small
+			b.Run(loop+"/"+tt.name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					Eval(program, object.NewEnvironment())
+				}
+			})
+		}
+	}
+}
+
+func TestEvalBooleanExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true", true},
+		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"true == true", true},
+		{"true != false", true},
+		{"(1 < 2) == true", true},
+		{"(1 > 2) == true", false},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestBangOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"!true", false},
+		{"!false", true},
+		{"!5", false},
+		{"!!true", true},
+		{"!!5", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestIfElseExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", nil},
+		{"if (1) { 10 }", 10},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestIfExpressionValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = if (true) { 1 } else { 2 }; x", 1},
+		{"let x = if (false) { 1 } else { 2 }; x", 2},
+		{"let x = if (false) { 1 }; x", nil},
+		{"let c = 5; let x = if (c > 3) { let d = c * 2; d + 1 } else { 0 }; x", 11},
+		{"let f = fn(n) { if (n > 0) { n } else { -n } }; f(-3) + f(4)", 7},
+		{"[if (true) { 1 }, if (false) { 2 }][1]", nil},
+		{"let x = if (false) { 1 } else { if (false) { 2 } }; x", nil},
+		{"(if (true) { 2 } else { 3 }) * 10", 20},
+		// a block ending in a let statement has no value
+		{"let x = if (true) { let y = 1; }; x", nil},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestReturnStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"9; return 2 * 5; 9;", 10},
+		{`
+if (10 > 1) {
+  if (10 > 1) {
+    return 10;
+  }
+  return 1;
+}
+`, 10},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestErrorHandling(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"5 + true;", "type mismatch: INTEGER + BOOLEAN"},
+		{"5 + true; 5;", "type mismatch: INTEGER + BOOLEAN"},
+		{"-true", "unknown operator: -BOOLEAN"},
+		{"+true", "unknown operator: +BOOLEAN"},
+		{`+"a"`, "unknown operator: +STRING"},
+		{"true + false;", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"if (10 > 1) { true + false; }", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"foobar", "identifier not found: foobar"},
+		{"1 / 0", "division by zero"},
+		{"1 % 0", "division by zero"},
+		{"let f = fn(x) { x }; f(1, 2)", "wrong number of arguments: want=1, got=2"},
+		{"{ let x = 1; }; x", "identifier not found: x"},
+		{`{"name": "Monkey"}[fn(x) { x }];`, "unusable as hash key: FUNCTION"},
+		{`{fn(x) { x }: 1}`, "unusable as hash key: FUNCTION"},
+		{`1[0]`, "index operator not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestFunctionApplication(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let identity = fn(x) { x; }; identity(5);", 5},
+		{"let identity = fn(x) { return x; }; identity(5);", 5},
+		{"let double = fn(x) { x * 2; }; double(5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) { x; }(5)", 5},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestNamedArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let greet = fn(name, greeting) { greeting + " " + name }; greet(name = "Sam", greeting = "hi")`, "hi Sam"},
+		{`let greet = fn(name, greeting) { greeting + " " + name }; greet(greeting = "hi", name = "Sam")`, "hi Sam"},
+		{`let greet = fn(name, greeting) { greeting + " " + name }; greet("Sam", greeting = "hello")`, "hello Sam"},
+		{`let sub = fn(a, b) { a - b }; sub(b = 1, a = 10)`, 9},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("wrong value. expected=%q, got=%q", expected, str.Value)
+			}
+		}
+	}
+}
+
+func TestNamedArgumentErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`let f = fn(a, b) { a }; f(1, c = 2)`, "unknown parameter name: c"},
+		{`let f = fn(a, b) { a }; f(1, a = 2)`, "duplicate argument for parameter a"},
+		{`let f = fn(a, b) { a }; f(a = 1, a = 2)`, "duplicate argument for parameter a"},
+		{`let f = fn(a, b) { a }; f(a = 1)`, "missing argument for parameter b"},
+		{`let f = fn(a) { a }; f(1, 2, a = 3)`, "wrong number of arguments: want=1, got=3"},
+		{`len(x = "abc")`, "named arguments are not supported for BUILTIN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestDefaultParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let add = fn(x, y = 10) { x + y }; add(5)", 15},
+		{"let add = fn(x, y = 10) { x + y }; add(5, 1)", 6},
+		{"let f = fn(a, b = 2, c = 3) { a * 100 + b * 10 + c }; f(1)", 123},
+		{"let f = fn(a, b = 2, c = 3) { a * 100 + b * 10 + c }; f(1, 5)", 153},
+		{"let f = fn(a, b = 2, c = 3) { a * 100 + b * 10 + c }; f(1, c = 9)", 129},
+		{"let f = fn(a = 1) { a }; f()", 1},
+		{"let n = 1; let f = fn(x = n) { x }; let n = 2; f()", 2},
+		{"let x = 7; let f = fn(x, y = x) { y }; f(1)", 7},
+		{"let add = fn(x, y = 10) { x + y }; add()", "wrong number of arguments: want=2, got=0"},
+		{"let add = fn(x, y = 10) { x + y }; add(1, 2, 3)", "wrong number of arguments: want=2, got=3"},
+		{"let add = fn(x, y = 10) { x + y }; add(y = 1)", "missing argument for parameter x"},
+		{"let f = fn(x = -true) { x }; f()", "unknown operator: -BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestClosures(t *testing.T) {
+	input := `
+let newAdder = fn(x) {
+  fn(y) { x + y };
+};
+
+let addTwo = newAdder(2);
+addTwo(2);`
+
+	testIntegerObject(t, testEval(input), 4)
+}
+
+// A function bound with let can call itself: the closure captures the
+// environment, which has the binding by the time the function is called.
+func TestFunctionIdentity(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let f = fn(x) { x }; f == f", true},
+		{"let f = fn(x) { x }; let g = f; f == g", true},
+		{"let f = fn(x) { x }; let g = f; f != g", false},
+		{"fn(x) { x } == fn(x) { x }", false},
+		{"let f = fn() {}; let g = fn() {}; f == g", false},
+		{"let f = fn() {}; let g = fn() {}; f != g", true},
+		// every call of make creates a new function
+		{"let make = fn() { fn() { 1 } }; make() == make()", false},
+		{"len == len", true},
+		{"len == first", false},
+		{"let f = fn() {}; f == 1", false},
+	}
+
+	for i, tt := range tests {
+		evaluated := testEval(tt.input)
+		if !testBooleanObject(t, evaluated, tt.expected) {
+			t.Errorf("tests[%d] - wrong result for %q", i, tt.input)
+		}
+	}
+}
+
+func TestRecursiveFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(10)`, 55},
+		{`
+let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } };
+let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } };
+if (isEven(10)) { if (isOdd(7)) { 1 } else { 2 } } else { 3 }`, 1},
+		{`
+let outer = fn() {
+  let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } };
+  fact(5)
+};
+outer()`, 120},
+		// the binding is looked up when the call happens, not when the function is made
+		{`let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; let g = f; let f = fn(n) { 42 }; g(3)`, 42},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+	testBooleanObject(t, testEval(`let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } };
+let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } };
+isOdd(9)`), true)
+}
+
+func TestMaxCallDepth(t *testing.T) {
+	MaxCallDepth = 100
+	defer func() { MaxCallDepth = 0 }()
+
+	testExpectedObject(t, "runaway", testEval(`let f = fn(n) { f(n + 1) }; f(0)`),
+		"stack overflow: max call depth exceeded")
+	testExpectedObject(t, "bounded", testEval(`let sum = fn(n) { if (n == 0) { 0 } else { n + sum(n - 1) } }; sum(99)`), 4950)
+	testExpectedObject(t, "over", testEval(`let sum = fn(n) { if (n == 0) { 0 } else { n + sum(n - 1) } }; sum(100)`),
+		"stack overflow: max call depth exceeded")
+
+	// the depth goes back down after an error, so later calls aren't affected
+	testExpectedObject(t, "after", testEval(`let f = fn() { 1 }; f()`), 1)
+}
+
+func TestTailCalls(t *testing.T) {
+	sum := `let sum = fn(n, acc) { if (n == 0) { return acc; } return sum(n - 1, acc + n); };`
+
+	// deep enough to exhaust the Go stack if every call nested
+	testExpectedObject(t, "deep", testEval(sum+"sum(1000000, 0)"), 500000500000)
+
+	MaxCallDepth = 100
+	defer func() { MaxCallDepth = 0 }()
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{sum + "sum(10000, 0)", 50005000},
+		// mutual recursion and calls inside blocks are tail calls as well
+		{`let isEven = fn(n) { if (n == 0) { return 1; } return isOdd(n - 1); };
+let isOdd = fn(n) { if (n == 0) { return 0; } return isEven(n - 1); };
+isEven(5000)`, 1},
+		{"let down = fn(n) { while (true) { if (n == 0) { return 7; } return down(n - 1); } }; down(5000)", 7},
+		// defaults are filled in for every call
+		{"let count = fn(n, acc = 0) { if (n == 0) { return acc; } return count(n - 1); }; count(5000)", 0},
+		{"let f = fn(n) { if (n == 0) { return len([1, 2]); } return f(n - 1); }; f(5000)", 2},
+		{"let f = fn(n) { if (n == 0) { return missing(); } return f(n - 1); }; f(5000)", "identifier not found: missing"},
+		{"let f = fn(n) { if (n == 0) { return n + true; } return f(n - 1); }; f(5000)", "type mismatch: INTEGER + BOOLEAN"},
+		{"let f = fn(n) { return f(n, n); }; f(1)", "wrong number of arguments: want=1, got=2"},
+		// a call that isn't returned still nests
+		{"let f = fn(n) { if (n == 0) { return 0; } f(n - 1) }; f(200)", "stack overflow: max call depth exceeded"},
+		{"let f = fn(n) { if (n == 0) { return 0; } return 1 + f(n - 1); }; f(200)", "stack overflow: max call depth exceeded"},
+		// an error in the callback of a builtin still reaches the caller of the builtin
+		{"let f = fn(n) { if (n == 0) { return throw(\"done\"); } return f(n - 1); }; try(fn() { f(5000) }, fn(e) { len(e) })", 4},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestBlockExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"{ let x = 1; x + 1 }", 2},
+		{"let y = { let x = 1; x + 1 }; y", 2},
+		{"{ 1; 2; 3 }", 3},
+		{"{ let x = 1; }", nil},
+		{"{ let x = 1; x; let y = 2; }", nil},
+		{"{ let a = 1; { let b = 2; a + b } }", 3},
+		{"{ let a = 1; { let a = 10; a }; a }", 1},
+		{"let f = fn() { { return 5; }; 10 }; f()", 5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 1; x = 2", 2},
+		{"let a = 1; let b = 2; a = b = 3; a + b", 6},
+		{"let x = 1; let f = fn() { x = 5 }; f(); x", 5},
+		{"let x = 1; let f = fn(x) { x = 5 }; f(2); x", 1},
+		{"let x = 1; { x = 2 }; x", 2},
+		{"y = 1", "cannot assign to undeclared identifier: y"},
+		{"let x = 1; x = -true", "unknown operator: -BOOLEAN"},
+		{"let x = 5; x += 2; x", 7},
+		{"let x = 5; x -= 2; x", 3},
+		{"let x = 5; x *= 2; x", 10},
+		{"let x = 5; x /= 2; x", 2},
+		{"let x = 5; x %= 2; x", 1},
+		{"let x = 5; x += 2", 7},
+		{"let x = 1; let f = fn() { x += 1 }; f(); f(); x", 3},
+		{"let a = [1, 2]; a[1] += 5; a[1]", 7},
+		{`let s = "ab"; s += "c"; if (s == "abc") { 1 } else { 0 }`, 1},
+		{`let x = 1; x += "a"`, "type mismatch: INTEGER + STRING"},
+		{"let x = 1; x /= 0", "division by zero"},
+		{"y += 1", "identifier not found: y"},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestGroupedExpressions(t *testing.T) {
+	parser.PreserveParens = true
+	defer func() { parser.PreserveParens = false }()
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"(1 + 2) * 3", 9},
+		{"((5))", 5},
+		{"let x = 1; (x) = 4; x", 4},
+		{"let a = [1, 2]; (a[1]) += 5; a[1]", 7},
+		{"let f = fn(x) { x * 2 }; (f)(4)", 8},
+		{"([1, 2, 3].len)()", 3},
+		{"([1, 2, 3]).len()", 3},
+		{"-(true)", "unknown operator: -BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestPipeExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let sub = fn(a, b) { a - b }; 3 |> sub(10, _)", 7},
+		{"let sub = fn(a, b) { a - b }; 10 |> sub(3)", 7},
+		{"[1, 2] |> len", 2},
+		{"[1, 2] |> len()", 2},
+		{"let double = fn(x) { x * 2 }; 1 + 2 |> double |> double", 12},
+		{"let add = fn(a, b) { a + b }; 4 |> add(_, _)", 8},
+		{"let f = fn(a, b) { a * 10 + b }; 1 |> f(2 |> f(_, 3), _)", 231},
+		{"let n = 0; let next = fn() { n += 1 }; next() |> fn(a, b) { [a, b] }(_, _)", []int{1, 1}},
+		{"[3, 1, 2] |> sort |> push(4)", []int{1, 2, 3, 4}},
+		{"_", "`_` can only be used in the arguments of a call after |>"},
+		{"let f = fn() { _ }; 1 |> f()", "wrong number of arguments: want=0, got=1"},
+		{"5 |> 3", "not a function: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestOptionalIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let n = puts(); n?.x", nil},
+		{`{"x": 1}?.x`, 1},
+		{`{"x": 1}?.y`, nil},
+		{`let a = {"b": {"c": 3}}; a?.b?.c`, 3},
+		{`let a = {"b": 2}; a?.c?.d?.e`, nil},
+		{`let a = puts(); a?.b?.c`, nil},
+		{"[5, 6]?.1", 6},
+		{"let grid = [[1, 2], [3, 4]]; grid?.1?.0", 3},
+		{"let n = puts(); n?.x.len()", "argument to `len` not supported, got NULL"},
+		{"5?.x", "index operator not supported: INTEGER"},
+		{"true?.x", "index operator not supported: BOOLEAN"},
+		// plain . looks up the same keys but doesn't stop at null
+		{`{"x": 1}.x`, 1},
+		{`{"x": 1}.y`, nil},
+		{`let a = {"b": {"c": 3}}; a.b.c`, 3},
+		{`let a = {"b": {"c": 3}}; a?.b.c`, 3},
+		{`let f = fn(h) { 5 }; {"f": 1}.f()`, 5},
+		{"let n = puts(); n.x", "`.x` can only be used on a hash or to call a method, got NULL"},
+		{`let a = {"b": 2}; a.c.d`, "`.d` can only be used on a hash or to call a method, got NULL"},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestCommentStatements(t *testing.T) {
+	inputs := []string{
+		"let x = 1; // one\nx + 1 // two\n// the end",
+		"let f = fn(x) {\n  // double it\n  x * 2\n  // done\n};\nf(21)",
+		"let y = { 5 // five\n}; if (y > 1) { y // big\n} else { 0 }",
+		"let f = fn() { return 3; // early\n 4 }; f() // call",
+	}
+
+	for _, input := range inputs {
+		expected := testEval(input)
+
+		parser.CommentStatements = true
+		l := lexer.New(input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		parser.CommentStatements = false
+
+		comments := 0
+		ast.Walk(program, func(node ast.Node) bool {
+			if _, ok := node.(*ast.CommentStatement); ok {
+				comments++
+			}
+			return true
+		})
+		if comments == 0 {
+			t.Errorf("no comment statements in %q", input)
+		}
+		evaluated := Eval(program, object.NewEnvironment())
+		if evaluated.Inspect() != expected.Inspect() {
+			t.Errorf("comments changed the result of %q. want=%s, got=%s", input, expected.Inspect(), evaluated.Inspect())
+		}
+	}
+}
+
+func TestSpreadArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let max = fn(a, b, c) { let m = a; if (b > m) { m = b }; if (c > m) { m = c }; m }; max(...[3, 1, 2])", "3"},
+		{"let add = fn(a, b) { a + b }; let args = [1, 2]; add(...args)", "3"},
+		{"let f = fn(a, b, c, d) { [a, b, c, d] }; f(1, ...[2, 3], 4)", "[1, 2, 3, 4]"},
+		{"let f = fn(a, b = 10) { a + b }; [f(...[1]), f(...[1, 2]), f(...[], 5)]", "[11, 3, 15]"},
+		{"concat(...[[1], [2, 3]], [4])", "[1, 2, 3, 4]"},
+		{"len(...[[1, 2]])", "2"},
+		// several arguments of a builtin from one spread, in order
+		{"push(...[[1], 2])", "[1, 2]"},
+		{`replace(...["banana", "an", "o"])`, "booa"},
+		{`replace("banana", ...["an", "o"])`, "booa"},
+		{`replace(...["banana"], "a", ...["_"])`, "b_n_n_"},
+		{"slice(...[[1, 2, 3, 4], 1, 3])", "[2, 3]"},
+		{"push(...[[1]])", "ERROR: wrong number of arguments. got=1, want=2"},
+		{"[1, 2].push(...[3])", "[1, 2, 3]"},
+		{"let f = fn(a, b) { a - b }; 10 |> f(...[3])", "7"},
+		{"let f = fn() { 0 }; f(...[])", "0"},
+		{"let f = fn(a) { a }; f(...[1, 2])", "ERROR: wrong number of arguments: want=1, got=2"},
+		{"len(...5)", "ERROR: cannot spread INTEGER into the arguments of a call"},
+		{`let f = fn(a) { a }; f(...{"a": 1})`, "ERROR: cannot spread HASH into the arguments of a call"},
+		{"let f = fn(a, b) { a }; f(...[1], b = 2)", "ERROR: spread arguments can't be combined with named arguments"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: wrong result. want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestOnAssign(t *testing.T) {
+	input := "let x = 1;\nlet [a, b] = [2, 3];\nx = a + b;\nlet f = fn(y) { x += y };\nf(4); x"
+
+	type assignment struct {
+		name  string
+		value string
+		line  int
+		col   int
+	}
+	got := []assignment{}
+	OnAssign = func(name string, val object.Object, pos token.Position) {
+		got = append(got, assignment{name, val.Inspect(), pos.Line, pos.Column})
+	}
+	defer func() { OnAssign = nil }()
+	testIntegerObject(t, testEval(input), 9)
+
+	expected := []assignment{
+		{"x", "1", 1, 5},
+		{"a", "2", 2, 6},
+		{"b", "3", 2, 9},
+		{"x", "5", 3, 1},
+		{"f", "fn(y) {\n(x = (x + y))\n}", 4, 5},
+		{"x", "9", 4, 17},
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("wrong number of assignments. want=%d, got=%d: %v", len(expected), len(got), got)
+	}
+	for i, want := range expected {
+		if got[i] != want {
+			t.Errorf("assignment %d wrong. want=%+v, got=%+v", i, want, got[i])
+		}
+	}
+}
+
+func TestSpreadLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = [1, 2, 3]; [...a, 4]", "[1, 2, 3, 4]"},
+		{"let a = [1, 2]; [0, ...a, ...a, ...[]]", "[0, 1, 2, 1, 2]"},
+		{"let a = [1]; let b = [...a]; b[0] = 2; a", "[1]"},
+		{`let base = {"x": 0, "y": 2}; {...base, "x": 1}`, "{x: 1, y: 2}"},
+		{`let base = {"x": 0}; {"x": 1, ...base}`, "{x: 0}"},
+		{`{...{"a": 1}, ...{"b": 2}}`, "{a: 1, b: 2}"},
+		{`[..."ab"]`, "ERROR: cannot spread STRING into an array"},
+		{`[...{"a": 1}]`, "ERROR: cannot spread HASH into an array"},
+		{`{...[1]}`, "ERROR: cannot spread ARRAY into a hash"},
+		{`[...x]`, "ERROR: identifier not found: x"},
+		{`let a = [1]; ...a`, "ERROR: `...` can only be used in array and hash literals and call arguments"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: wrong result. want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestIndexAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = [1, 2, 3]; a[1] = 5; a", "[1, 5, 3]"},
+		{"let a = [1, 2]; a[0] = a[1] = 7; a", "[7, 7]"},
+		{"let a = [1, 2]; let b = a; a[0] = 9; b", "[9, 2]"},
+		{"let a = [[1], [2]]; a[1][0] = 3; a", "[[1], [3]]"},
+		{`let h = {"a": 1}; h["b"] = 2; h["a"] = 3; h`, "{a: 3, b: 2}"},
+		{"let a = [1]; a[0] = 2", "2"},
+		// arrays grown by push may share storage, assigning must not leak
+		{"let a = []; a = push(a, 1); let b = a; a = push(a, 2); b[0] = 9; [a, b]", "[[1, 2], [9]]"},
+		{"let a = []; a = push(a, 1); let b = a; a = push(a, 2); a[0] = 9; b = push(b, 3); [a, b]", "[[9, 2], [1, 3]]"},
+		{"let a = [1]; a = push(a, 2); a[0] = 5; a = push(a, 3); a", "[5, 2, 3]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: wrong result. want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = [1]; a[1] = 2", "index 1 out of range for array of length 1"},
+		{"let a = [1]; a[-1] = 2", "index -1 out of range for array of length 1"},
+		{`let a = [1]; a["x"] = 2`, "array index must be INTEGER, got STRING"},
+		{"let h = {}; h[[1]] = 2", "unusable as hash key: ARRAY"},
+		{"let s = 1; s[0] = 2", "index assignment not supported: INTEGER"},
+		{"b[0] = 1", "identifier not found: b"},
+		{"let a = [1]; a[0] = -true", "unknown operator: -BOOLEAN"},
+	}
+
+	for _, tt := range errors {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestWhileExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; while (i < 5) { i = i + 1 }; i", 5},
+		{"let i = 0; let sum = 0; while (i < 4) { i = i + 1; sum = sum + i }; sum", 10},
+		{"let i = 10; while (i < 5) { i = i + 1 }; i", 10},
+		{"while (false) { 1 }", nil},
+		{"let f = fn() { let i = 0; while (true) { i = i + 1; if (i > 2) { return i } } }; f()", 3},
+		{"while (true) { -true }", "unknown operator: -BOOLEAN"},
+		{"while (x) { 1 }", "identifier not found: x"},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestNullSafeLookup(t *testing.T) {
+	tests := []struct {
+		input    string
+		strict   interface{}
+		nullSafe interface{}
+	}{
+		{"foobar", "identifier not found: foobar", nil},
+		{"foobar[0]", "identifier not found: foobar", nil},
+		{`foobar["a"][1]`, "identifier not found: foobar", nil},
+		{"[1, 2][5]", nil, nil},
+		{`{"a": 1}["b"]`, nil, nil},
+		{"let x = 5; x", 5, 5},
+		{"len()", "wrong number of arguments. got=0, want=1", "wrong number of arguments. got=0, want=1"},
+		{"5[0]", "index operator not supported: INTEGER", "index operator not supported: INTEGER"},
+		{"foobar + 1", "identifier not found: foobar", "type mismatch: NULL + INTEGER"},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.strict)
+	}
+
+	NullSafeLookup = true
+	defer func() { NullSafeLookup = false }()
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.nullSafe)
+	}
+}
+
+func TestDoWhileExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 10; do { i = i + 1 } while (i < 5); i", 11},
+		{"let i = 0; do { i = i + 1 } while (i < 5); i", 5},
+		{"let i = 0; let sum = 0; do { i = i + 1; sum = sum + i } while (i < 4); sum", 10},
+		{"do { 1 } while (false)", nil},
+		{"let f = fn() { let i = 0; do { i = i + 1; if (i > 2) { return i } } while (true) }; f()", 3},
+		{"do { -true } while (true)", "unknown operator: -BOOLEAN"},
+		{"do { 1 } while (x)", "identifier not found: x"},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestBreakAndContinue(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; while (true) { i = i + 1; if (i == 3) { break } }; i", 3},
+		{"let i = 0; let sum = 0; while (i < 5) { i = i + 1; if (i % 2 == 0) { continue }; sum = sum + i }; sum", 9},
+		{"let i = 0; do { i = i + 1; if (i == 3) { break } } while (true); i", 3},
+		{"let i = 0; let sum = 0; do { i = i + 1; if (i % 2 == 0) { continue }; sum = sum + i } while (i < 5); sum", 9},
+		{"let sum = 0; for (x in range(10)) { if (x == 4) { break }; sum = sum + x }; sum", 6},
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { if (x % 2 == 1) { continue }; sum = sum + x }; sum", 6},
+		{"while (true) { break }", nil},
+		{"let i = 0; while (true) { { i = i + 1; break; } }; i", 1},
+		// only the innermost loop ends
+		{"let n = 0; for (x in range(3)) { for (y in range(3)) { if (y == 1) { break }; n = n + 1 } }; n", 3},
+		{"let n = 0; for (x in range(3)) { while (true) { break }; n = n + 1 }; n", 3},
+		{"let f = fn() { for (x in range(5)) { if (x == 2) { break } }; 7 }; f()", 7},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let [a, b] = [1, 2]; a * 10 + b", 12},
+		{`let {x, y} = {"x": 1, "y": 2, "z": 3}; x * 10 + y`, 12},
+		{"let [a, [b, c]] = [1, [2, 3]]; a + b + c", 6},
+		{`let {p: [a, b], q: {r}} = {"p": [1, 2], "q": {"r": 3}}; a + b + r`, 6},
+		{"let [a, b] = [1, 2]; let [a, b] = [b, a]; a * 10 + b", 21},
+		{"let [a, b] = [1, 2, 3];", "wrong number of elements to destructure: want=2, got=3"},
+		{"let [a, [b, c]] = [1, [2]];", "wrong number of elements to destructure: want=2, got=1"},
+		{`let {x, y} = {"x": 1};`, `missing key to destructure: "y"`},
+		{"let [a] = 1;", "cannot destructure INTEGER with an array pattern"},
+		{"let {a} = [1];", "cannot destructure ARRAY with a hash pattern"},
+		{"let [a] = [-true];", "unknown operator: -BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+
+	// nothing is bound when the value doesn't fit
+	env := object.NewEnvironment()
+	Eval(parser.New(lexer.New("let [a, [b]] = [1, 2];")).ParseProgram(), env)
+	if _, ok := env.Get("a"); ok {
+		t.Errorf("a was bound although destructuring failed")
+	}
+}
+
+func TestFloatExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1.5", "1.5"},
+		{"-2.5", "-2.5"},
+		{"1.5 + 1.5", "3"},
+		{"1 + 0.5", "1.5"},
+		{"0.5 * 4", "2"},
+		{"1 / 4.0", "0.25"},
+		{"3.5 - 1", "2.5"},
+		{"1.5 < 2", "true"},
+		{"2 > 2.5", "false"},
+		{"1 == 1.0", "true"},
+		{"1.5 != 1.5", "false"},
+		{"5.5 % 2", "1.5"},
+		{"+2.5", "2.5"},
+		// whole numbers, rounding error, negative zero and magnitudes
+		{"1.0", "1"},
+		{"-3.0", "-3"},
+		{"0.1 + 0.2", "0.3"},
+		{"1 / 3.0", "0.333333333333333"},
+		{"2 / 3.0", "0.666666666666667"},
+		{"-0.0", "0"},
+		{"0.0 * -1", "0"},
+		{"123456789012345.0", "123456789012345"},
+		{"1000000000000000.0", "1e+15"},
+		{"1.5 * 10000000000000000000000.0", "1.5e+22"},
+		{"0.0001", "0.0001"},
+		{"0.000025", "2.5e-05"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: wrong result. want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	testExpectedObject(t, "1.5 / 0", testEval("1.5 / 0"), "division by zero")
+	testExpectedObject(t, "1.5 % 0", testEval("1.5 % 0"), "division by zero")
+	testExpectedObject(t, "1.5 + true", testEval("1.5 + true"), "type mismatch: FLOAT + BOOLEAN")
+}
+
+func TestForExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let sum = 0; for (x in [1, 2, 3]) { sum = sum + x }; sum", 6},
+		{"let sum = 0; for (x in range(5)) { sum = sum + x }; sum", 10},
+		{"let sum = 0; for (x in range(2, 5)) { sum = sum + x }; sum", 9},
+		{"let sum = 0; for (x in range(10, 0, -3)) { sum = sum + x }; sum", 22},
+		{"let n = 0; for (x in []) { n = n + 1 }; n", 0},
+		{"for (x in [1]) { x }", nil},
+		{"let f = fn() { for (x in range(100)) { if (x > 2) { return x } } }; f()", 3},
+		// every iteration has its own x
+		{"let fs = []; for (x in [1, 2]) { fs = push(fs, fn() { x }) }; fs[0]() * 10 + fs[1]()", 12},
+		{"let x = 7; for (x in [1, 2]) { }; x", 7},
+		{"for (x in 5) { x }", "cannot iterate over INTEGER"},
+		{"for (x in [1]) { -true }", "unknown operator: -BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`match 2 { 1 => 10, 2 => 20, _ => 30 }`, 20},
+		{`match 5 { 1 => 10, 2 => 20, _ => 30 }`, 30},
+		{`match "b" { "a" => 1, "b" => 2 }`, 2},
+		{`match [1, 2] { [1] => 1, [1, 1 + 1] => 2 }`, 2},
+		{`match {"a": 1} { {"a": 2} => 1, {"a": 1} => 2 }`, 2},
+		{`match true { 1 => 1, true => 2 }`, 2},
+		{`let x = 1; match x + 1 { x => 1, x * 2 => 2 }`, 2},
+		{`match 3 { 1 => 10, 2 => 20 }`, "no match arm for 3"},
+		{`match 1 { 1 => -true, _ => 2 }`, "unknown operator: -BOOLEAN"},
+		{`match 1 { y => 1 }`, "identifier not found: y"},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestMatchEvaluatesSubjectOnce(t *testing.T) {
+	input := `
+	let calls = 0;
+	let next = fn() { calls = calls + 1; calls };
+	match next() { 2 => 2, 3 => 3, _ => 0 };
+	calls`
+	testIntegerObject(t, testEval(input), 1)
+}
+
+func TestArrayLiterals(t *testing.T) {
+	evaluated := testEval("[1, 2 * 2, 3 + 3]")
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements. got=%d", len(result.Elements))
+	}
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"[1, 2, 3][1 + 1];", 3},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"[1, 2, 3][3]", nil},
+		{"[1, 2, 3][-1]", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6
+	}`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		TRUE.HashKey():                             5,
+		FALSE.HashKey():                            6,
+	}
+
+	if len(result.Pairs) != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	}
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Pairs[expectedKey]
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+		}
+		testIntegerObject(t, pair.Value, expectedValue)
+	}
+}
+
+func TestEmptyHashLiteral(t *testing.T) {
+	evaluated := testEval("{}")
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+	if len(result.Pairs) != 0 {
+		t.Errorf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{"a": 1, "a": 2}["a"]`, 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func testEval(input string) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+	return Eval(program, env)
+}
+
+func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
+	t.Helper()
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("object is not Integer. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%d, want=%d", result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
+	t.Helper()
+	result, ok := obj.(*object.Boolean)
+	if !ok {
+		t.Errorf("object is not Boolean. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%t, want=%t", result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func testNullObject(t *testing.T, obj object.Object) bool {
+	t.Helper()
+	if obj != NULL {
+		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
+		return false
+	}
+	return true
+}
+
+func TestStringLiteral(t *testing.T) {
+	evaluated := testEval(`"Hello World!"`)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+	if str.Value != "Hello World!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func TestStringConcatenation(t *testing.T) {
+	evaluated := testEval(`"Hello" + " " + "World!"`)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+	if str.Value != "Hello World!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func TestMaxRepeat(t *testing.T) {
+	MaxRepeat = 10
+	defer func() { MaxRepeat = 0 }()
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("ab" * 3)`, 6},
+		{`len("ab" * 5)`, 10},
+		{`len("é" * 10)`, 10},
+		{`len("" * 1000000000)`, 0},
+		{`"ab" * 6`, "string repetition too long: 6 * 2 characters, the limit is 10"},
+		{`1000000000 * "a"`, "string repetition too long: 1000000000 * 1 characters, the limit is 10"},
+		{`"abc" * 9223372036854775807`, "string repetition too long: 9223372036854775807 * 3 characters, the limit is 10"},
+		{`len([1, 2] * 5)`, 10},
+		{`[1, 2] * 6`, "array repetition too long: 6 * 2 elements, the limit is 10"},
+		{`9223372036854775807 * [1]`, "array repetition too long: 9223372036854775807 * 1 elements, the limit is 10"},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestRepeatWithoutLimit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"a" * 9223372036854775807`, "string repetition too long: 9223372036854775807 * 1 bytes don't fit in memory"},
+		{`"é" * 4611686018427387904`, "string repetition too long: 4611686018427387904 * 2 bytes don't fit in memory"},
+		{`[1] * 9223372036854775807`, "array repetition too long: 9223372036854775807 * 1 elements don't fit in memory"},
+		{`len("ab" * 1000)`, 2000},
+		{`len([0] * 1000)`, 1000},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestArrayRepetition(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2] * 3", "[1, 2, 1, 2, 1, 2]"},
+		{"2 * [0]", "[0, 0]"},
+		{"[1] * 0", "[]"},
+		{"[1] * -2", "[]"},
+		{"[] * 5", "[]"},
+		{"let a = [[0]] * 2; a[0][0] = 1; a", "[[1], [1]]"},
+		{"let a = [1]; let b = a * 2; a[0] = 5; [a, b]", "[[5], [1, 1]]"},
+		{"[1] * 1.5", "ERROR: array repetition count must be INTEGER, got FLOAT"},
+		{"[1] * [2]", "ERROR: unknown operator: ARRAY * ARRAY"},
+	}
+
+	for _, tt := range tests {
+		if evaluated := testEval(tt.input); evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: wrong result. want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestStringMultiplication(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		err      string
+	}{
+		{`"ab" * 3`, "ababab", ""},
+		{`3 * "ab"`, "ababab", ""},
+		{`"ab" * 1`, "ab", ""},
+		{`"ab" * 0`, "", ""},
+		{`"ab" * -2`, "", ""},
+		{`"" * 5`, "", ""},
+		{`"ab" * 1.5`, "", "string repetition count must be INTEGER, got FLOAT"},
+		{`1.5 * "ab"`, "", "string repetition count must be INTEGER, got FLOAT"},
+		{`"ab" * true`, "", "type mismatch: STRING * BOOLEAN"},
+	}
+
+	for i, tt := range tests {
+		evaluated := testEval(tt.input)
+		if tt.err != "" {
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("tests[%d] - object is not Error. got=%T (%+v)", i, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != tt.err {
+				t.Errorf("tests[%d] - wrong error message. expected=%q, got=%q", i, tt.err, errObj.Message)
+			}
+			continue
+		}
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("tests[%d] - object is not String. got=%T (%+v)", i, evaluated, evaluated)
+			continue
+		}
+		if str.Value != tt.expected {
+			t.Errorf("tests[%d] - String has wrong value. expected=%q, got=%q", i, tt.expected, str.Value)
+		}
+	}
+}
+
+func TestTemplateLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let name = "Sam"; "Hello ${name}!"`, "Hello Sam!"},
+		{`"${1 + 2} and ${[1, 2]}"`, "3 and [1, 2]"},
+		{`let f = fn(x) { x * 2 }; "${f(2)}${f(3)}"`, "46"},
+		{`"${"inner ${1}"}"`, "inner 1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("%s: object is not String. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if str.Value != tt.expected {
+			t.Errorf("%s: wrong value. want=%q, got=%q", tt.input, tt.expected, str.Value)
+		}
+	}
+
+	testExpectedObject(t, `"a ${x} b"`, testEval(`"a ${x} b"`), "identifier not found: x")
+}
+
+func TestTaggedTemplates(t *testing.T) {
+	// tag concatenates the parts with every value put in brackets
+	err := RegisterBuiltin("tag", func(args ...object.Object) object.Object {
+		parts := args[0].(*object.Array).Elements
+		values := args[1].(*object.Array).Elements
+		var out strings.Builder
+		for i, part := range parts {
+			out.WriteString(part.Inspect())
+			if i < len(values) {
+				out.WriteString("[" + values[i].Inspect() + "]")
+			}
+		}
+		return &object.String{Value: out.String()}
+	})
+	if err != nil {
+		t.Fatalf("RegisterBuiltin failed: %s", err)
+	}
+	defer delete(hostBuiltins, "tag")
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let name = "Sam"; tag"Hello ${name}"`, "Hello [Sam]"},
+		{`tag"${1}, ${1 + 1} and ${"three"}."`, "[1], [2] and [three]."},
+		{`let tag = fn(parts, values) { len(parts) * 10 + len(values) }; "${tag"a${1}b${2}c"}"`, "32"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("%s: object is not String. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if str.Value != tt.expected {
+			t.Errorf("%s: wrong value. want=%q, got=%q", tt.input, tt.expected, str.Value)
+		}
+	}
+}
+
+func TestMethodCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`[1, 2, 3].len()`, 3},
+		{`"hi".upper().len()`, 2},
+		{`let add = fn(a, b) { a + b }; 1.add(2)`, 3},
+		{`let add = fn(a, b) { a + b }; let x = 4; x.add(x).add(1)`, 9},
+		{`let a = [[1], [2, 3]]; a[1].len()`, 2},
+		{`let len = fn(x) { 42 }; [1].len()`, 42},
+		{`-[1, 2].len()`, -2},
+		{`let f = fn(s, n = 2) { n }; "x".f(n = 5)`, 5},
+		{`[1].nope()`, "method not found: nope"},
+		{`[1].len`, "`.len` can only be used on a hash or to call a method, got ARRAY"},
+		{`[1].len(2)`, "wrong number of arguments. got=2, want=1"},
+	}
+
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+
+	if got := testEval(`"hi".upper()`).Inspect(); got != "HI" {
+		t.Errorf(`wrong result for "hi".upper(). got=%s`, got)
+	}
+}
+
+func TestMethodChains(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[3, 1, 2].sort().reverse()`, `[3, 2, 1]`},
+		{`[3, 1, 2].push(0).sort().rest()`, `[1, 2, 3]`},
+		{`"hello".upper().reverse()`, `OLLEH`},
+		{`"  hello ".trim().upper().reverse()`, `OLLEH`},
+		// each call gets the result of the one before it
+		{`"ab".reverse().push("x")`, "ERROR: argument to `push` must be ARRAY, got STRING"},
+		{`[1].nope().reverse()`, "ERROR: method not found: nope"},
+	}
+
+	for _, tt := range tests {
+		if evaluated := testEval(tt.input); evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
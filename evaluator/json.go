@@ -0,0 +1,138 @@
+package evaluator
+
+import (
+	"math"
+	"monkey/object"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// writeJSON writes obj to out as JSON, for toJSON. Numbers, strings,
+// booleans, null and arrays map to their JSON counterparts, and hashes to
+// objects with their keys in the order of sortedPairs, integer and boolean
+// keys written as strings. Any other value, like a function, can't be
+// represented and gives an error, and so do NaN, the infinities, arrays and
+// hashes containing themselves, and hashes with two keys written the same,
+// like 1 and "1".
+//
+// With a non-empty indent, the elements of arrays and hashes go on lines of
+// their own, indented by indent once per level of depth.
+func writeJSON(out *strings.Builder, obj object.Object, indent string, depth int, open map[object.Object]bool) *object.Error {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		out.WriteString(strconv.FormatInt(obj.Value, 10))
+	case *object.Float:
+		if math.IsNaN(obj.Value) || math.IsInf(obj.Value, 0) {
+			return newError("cannot convert %s to JSON", obj.Inspect())
+		}
+		out.WriteString(strconv.FormatFloat(obj.Value, 'g', -1, 64))
+	case *object.Boolean:
+		out.WriteString(strconv.FormatBool(obj.Value))
+	case *object.Null:
+		out.WriteString("null")
+	case *object.String:
+		writeJSONString(out, obj.Value)
+
+	case *object.Array:
+		if open[obj] {
+			return newError("cannot convert an ARRAY containing itself to JSON")
+		}
+		open[obj] = true
+		defer delete(open, obj)
+
+		out.WriteString("[")
+		for i, el := range obj.Elements {
+			writeJSONSeparator(out, i, indent, depth+1)
+			if err := writeJSON(out, el, indent, depth+1, open); err != nil {
+				return err
+			}
+		}
+		writeJSONClosing(out, len(obj.Elements), indent, depth, "]")
+
+	case *object.Hash:
+		if open[obj] {
+			return newError("cannot convert a HASH containing itself to JSON")
+		}
+		open[obj] = true
+		defer delete(open, obj)
+
+		pairs := make([]object.HashPair, 0, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			pairs = append(pairs, pair)
+		}
+		sort.Slice(pairs, func(i, j int) bool {
+			return keyLess(pairs[i].Key, pairs[j].Key)
+		})
+
+		names := map[string]bool{}
+		out.WriteString("{")
+		for i, pair := range pairs {
+			name := pair.Key.Inspect()
+			if names[name] {
+				return newError("cannot convert a HASH with two keys %s to JSON", name)
+			}
+			names[name] = true
+
+			writeJSONSeparator(out, i, indent, depth+1)
+			writeJSONString(out, name)
+			out.WriteString(":")
+			if indent != "" {
+				out.WriteString(" ")
+			}
+			if err := writeJSON(out, pair.Value, indent, depth+1, open); err != nil {
+				return err
+			}
+		}
+		writeJSONClosing(out, len(pairs), indent, depth, "}")
+
+	default:
+		return newError("cannot convert %s to JSON", obj.Type())
+	}
+	return nil
+}
+
+// writeJSONSeparator writes what comes before the element with index i of
+// an array or hash at nesting level depth.
+func writeJSONSeparator(out *strings.Builder, i int, indent string, depth int) {
+	if i > 0 {
+		out.WriteString(",")
+	}
+	if indent != "" {
+		out.WriteString("\n" + strings.Repeat(indent, depth))
+	}
+}
+
+// writeJSONClosing writes the bracket closing an array or hash with n
+// elements, on a line of its own unless the array or hash is empty.
+func writeJSONClosing(out *strings.Builder, n int, indent string, depth int, bracket string) {
+	if n > 0 && indent != "" {
+		out.WriteString("\n" + strings.Repeat(indent, depth))
+	}
+	out.WriteString(bracket)
+}
+
+// writeJSONString writes s as a JSON string, in double quotes, with quotes,
+// backslashes and control characters escaped.
+func writeJSONString(out *strings.Builder, s string) {
+	out.WriteString(`"`)
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			out.WriteString(`\` + string(r))
+		case r == '\n':
+			out.WriteString(`\n`)
+		case r == '\r':
+			out.WriteString(`\r`)
+		case r == '\t':
+			out.WriteString(`\t`)
+		case r < 0x20:
+			out.WriteString(`\u00`)
+			out.WriteString(strconv.FormatInt(int64(r)>>4, 16))
+			out.WriteString(strconv.FormatInt(int64(r)&0xF, 16))
+		default:
+			out.WriteRune(r)
+		}
+	}
+	out.WriteString(`"`)
+}
@@ -0,0 +1,66 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+	"monkey/token"
+	"sort"
+	"time"
+)
+
+// StatementTiming is the time spent evaluating a top-level statement.
+type StatementTiming struct {
+	Pos      token.Position
+	Duration time.Duration
+}
+
+// profiled holds the time spent in each top-level statement, profiling is off
+// while it is nil.
+var profiled map[token.Position]time.Duration
+
+// now is the clock of the profiler.
+var now = time.Now
+
+// RecordProfile turns profiling of top-level statements on or off. Turning it
+// on starts with an empty profile.
+func RecordProfile(on bool) {
+	if on {
+		profiled = map[token.Position]time.Duration{}
+	} else {
+		profiled = nil
+	}
+}
+
+// SetClock makes the profiler read the time from clock instead of time.Now,
+// so tests can control the time that passes. SetClock(nil) goes back to
+// time.Now.
+func SetClock(clock func() time.Time) {
+	if clock == nil {
+		clock = time.Now
+	}
+	now = clock
+}
+
+// Profile returns the wall time spent in each top-level statement since
+// profiling was turned on, in source order. The time of a statement includes
+// the functions it calls and adds up over every time it was evaluated, as in
+// a REPL evaluating the same program again.
+func Profile() []StatementTiming {
+	timings := make([]StatementTiming, 0, len(profiled))
+	for pos, d := range profiled {
+		timings = append(timings, StatementTiming{Pos: pos, Duration: d})
+	}
+	sort.Slice(timings, func(i, j int) bool {
+		return timings[i].Pos.Offset < timings[j].Pos.Offset
+	})
+	return timings
+}
+
+// evalProfiled evaluates a top-level statement, adding the time it took to
+// its timing.
+func evalProfiled(stmt ast.Statement, env *object.Environment) object.Object {
+	start := now()
+	result := Eval(stmt, env)
+	profiled[stmt.Pos()] += now().Sub(start)
+	return result
+}
@@ -0,0 +1,60 @@
+package evaluator
+
+import (
+	"monkey/object"
+	"monkey/token"
+	"testing"
+	"time"
+)
+
+func TestProfile(t *testing.T) {
+	// the clock only moves when the program calls wait
+	var clock time.Time
+	SetClock(func() time.Time { return clock })
+	defer SetClock(nil)
+	if err := RegisterBuiltin("wait", func(args ...object.Object) object.Object {
+		clock = clock.Add(time.Duration(args[0].(*object.Integer).Value) * time.Millisecond)
+		return NULL
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer delete(hostBuiltins, "wait")
+
+	input := `let slow = fn(n) { wait(n); wait(n) };
+slow(5);
+wait(1);
+slow(10);
+`
+
+	RecordProfile(true)
+	defer RecordProfile(false)
+	testEval(input)
+	testEval(input)
+
+	expected := []StatementTiming{
+		{Pos: token.Position{Offset: 0, Line: 1, Column: 1}, Duration: 0},
+		{Pos: token.Position{Offset: 39, Line: 2, Column: 1}, Duration: 20 * time.Millisecond},
+		{Pos: token.Position{Offset: 48, Line: 3, Column: 1}, Duration: 2 * time.Millisecond},
+		{Pos: token.Position{Offset: 57, Line: 4, Column: 1}, Duration: 40 * time.Millisecond},
+	}
+
+	timings := Profile()
+	if len(timings) != len(expected) {
+		t.Fatalf("wrong number of timings. want=%d, got=%+v", len(expected), timings)
+	}
+	for i, timing := range expected {
+		if timings[i] != timing {
+			t.Errorf("timings[%d] wrong. want=%+v, got=%+v", i, timing, timings[i])
+		}
+	}
+}
+
+func TestProfileOff(t *testing.T) {
+	RecordProfile(true)
+	RecordProfile(false)
+	testEval("let x = 1; x")
+
+	if timings := Profile(); len(timings) != 0 {
+		t.Errorf("timings recorded while profiling was off. got=%+v", timings)
+	}
+}
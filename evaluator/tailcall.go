@@ -0,0 +1,58 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+)
+
+// tailCall is what `return f(args)` in a function body evaluates to when f is
+// a user function: the call still to be made. It travels up to the
+// applyUserFunction evaluating the body like any return value, which then
+// makes the call in a loop instead of recursing. That way a tail-recursive
+// function runs in constant stack space, however deep it recurses.
+//
+// Only explicit returns are tail calls, a call that is the last expression
+// of a body is evaluated as usual. Neither are calls while Trace is on, so
+// the trace shows every call.
+type tailCall struct {
+	function *object.Function
+	args     []object.Object
+}
+
+func (tc *tailCall) Type() object.ObjectType { return "TAIL_CALL" }
+func (tc *tailCall) Inspect() string         { return "tail call" }
+
+// evalReturnedCall evaluates `return <call>` inside a function. Calls of user
+// functions become a tailCall, anything else is called right away.
+func evalReturnedCall(call *ast.CallExpression, env *object.Environment) object.Object {
+	if _, ok := ast.Unparen(call.Function).(*ast.DotExpression); ok {
+		return evalReturn(call, env)
+	}
+	function := Eval(call.Function, env)
+	if isError(function) {
+		return function
+	}
+	args := evalCallArguments(function, call.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+
+	fn, ok := function.(*object.Function)
+	if !ok {
+		return wrapReturn(applyFunction(function, args))
+	}
+	return &object.ReturnValue{Value: &tailCall{function: fn, args: args}}
+}
+
+// evalReturn evaluates `return <value>`.
+func evalReturn(value ast.Expression, env *object.Environment) object.Object {
+	return wrapReturn(Eval(value, env))
+}
+
+// wrapReturn wraps the value of a return statement, errors pass unwrapped.
+func wrapReturn(val object.Object) object.Object {
+	if isError(val) {
+		return val
+	}
+	return &object.ReturnValue{Value: val}
+}
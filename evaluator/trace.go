@@ -0,0 +1,47 @@
+package evaluator
+
+import (
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/object"
+	"strings"
+)
+
+// traceOut receives the evaluation trace, tracing is off while it is nil.
+var (
+	traceOut   io.Writer
+	traceDepth int
+)
+
+// Trace makes Eval write every node it evaluates to w, followed by the
+// resulting object once the node is done, both indented by nesting depth:
+//
+//	InfixExpression (1 + 2)
+//	  IntegerLiteral 1
+//	  => 1
+//	  IntegerLiteral 2
+//	  => 2
+//	=> 3
+//
+// Trace(nil) turns the trace off again.
+func Trace(w io.Writer) {
+	traceOut = w
+	traceDepth = 0
+}
+
+func traceEval(node ast.Node, env *object.Environment) object.Object {
+	indent := strings.Repeat("  ", traceDepth)
+	fmt.Fprintf(traceOut, "%s%s %s\n", indent, strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast."), node.String())
+
+	traceDepth++
+	result := eval(node, env)
+	traceDepth--
+
+	if result == nil {
+		fmt.Fprintf(traceOut, "%s=> (no value)\n", indent)
+	} else {
+		fmt.Fprintf(traceOut, "%s=> %s\n", indent, result.Inspect())
+	}
+	return result
+}
@@ -0,0 +1,63 @@
+package evaluator
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	var out bytes.Buffer
+	Trace(&out)
+	defer Trace(nil)
+
+	testIntegerObject(t, testEval("1 + 2 * 3"), 7)
+
+	expected := `Program (1 + (2 * 3))
+  ExpressionStatement (1 + (2 * 3))
+    InfixExpression (1 + (2 * 3))
+      IntegerLiteral 1
+      => 1
+      InfixExpression (2 * 3)
+        IntegerLiteral 2
+        => 2
+        IntegerLiteral 3
+        => 3
+      => 6
+    => 7
+  => 7
+=> 7
+`
+	if out.String() != expected {
+		t.Errorf("wrong trace. expected=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
+
+func TestTraceStatementWithoutValue(t *testing.T) {
+	var out bytes.Buffer
+	Trace(&out)
+	defer Trace(nil)
+
+	testEval("let x = 1;")
+
+	expected := `Program let x = 1;
+  LetStatement let x = 1;
+    IntegerLiteral 1
+    => 1
+  => (no value)
+=> (no value)
+`
+	if out.String() != expected {
+		t.Errorf("wrong trace. expected=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
+
+func TestTraceDisabled(t *testing.T) {
+	var out bytes.Buffer
+	Trace(&out)
+	Trace(nil)
+
+	testIntegerObject(t, testEval("1 + 2 * 3"), 7)
+	if out.Len() != 0 {
+		t.Errorf("trace written while disabled: %q", out.String())
+	}
+}
@@ -0,0 +1,115 @@
+// Package highlight colors Monkey source code for display.
+package highlight
+
+import (
+	"bytes"
+	"html"
+	"monkey/lexer"
+	"monkey/token"
+)
+
+// class is the kind of highlighting a token gets. Tokens of class plain,
+// like identifiers, operators and whitespace, are left as they are.
+type class int
+
+const (
+	plain class = iota
+	keyword
+	literal
+	str
+	comment
+)
+
+func classOf(t token.TokenType) class {
+	switch t {
+	case token.FUNCTION, token.LET, token.IF, token.ELSE, token.RETURN, token.WHILE:
+		return keyword
+	case token.INT, token.FLOAT, token.TRUE, token.FALSE:
+		return literal
+	case token.STRING, token.TEMPLATE, token.RAW_STRING, token.REGEX:
+		return str
+	case token.COMMENT:
+		return comment
+	default:
+		return plain
+	}
+}
+
+// segment is a token together with the exact source text it was read from,
+// which differs from the literal for strings (the quotes) and numbers (the
+// suffix).
+type segment struct {
+	class class
+	text  string
+}
+
+// segments splits src into tokens, including whitespace and comments, so the
+// texts of the segments add up to src.
+func segments(src string) []segment {
+	l := lexer.New(src)
+	l.EmitTrivia(true)
+	tokens, _ := l.All()
+
+	segs := make([]segment, 0, len(tokens))
+	if start := tokens[0].Pos.Offset; start > 0 {
+		// the byte order mark skipped by the lexer
+		segs = append(segs, segment{class: plain, text: src[:start]})
+	}
+	for i, tok := range tokens[:len(tokens)-1] {
+		text := src[tok.Pos.Offset:tokens[i+1].Pos.Offset]
+		segs = append(segs, segment{class: classOf(tok.Type), text: text})
+	}
+	return segs
+}
+
+const ansiReset = "\x1b[0m"
+
+var ansiColors = map[class]string{
+	keyword: "\x1b[35m", // magenta
+	literal: "\x1b[36m", // cyan
+	str:     "\x1b[32m", // green
+	comment: "\x1b[90m", // gray
+}
+
+// ANSI returns src with keywords, literals, strings and comments wrapped in
+// ANSI color codes for a terminal. Removing the codes gives back src.
+func ANSI(src string) string {
+	var out bytes.Buffer
+	for _, seg := range segments(src) {
+		color, ok := ansiColors[seg.class]
+		if !ok {
+			out.WriteString(seg.text)
+			continue
+		}
+		out.WriteString(color)
+		out.WriteString(seg.text)
+		out.WriteString(ansiReset)
+	}
+	return out.String()
+}
+
+var cssClasses = map[class]string{
+	keyword: "kw",
+	literal: "lit",
+	str:     "str",
+	comment: "com",
+}
+
+// HTML returns src as HTML, with keywords, literals, strings and comments in
+// spans like <span class="kw">let</span>. All text is escaped and whitespace
+// is kept as is, so the result is meant to go inside a <pre> element.
+func HTML(src string) string {
+	var out bytes.Buffer
+	for _, seg := range segments(src) {
+		text := html.EscapeString(seg.text)
+		css, ok := cssClasses[seg.class]
+		if !ok {
+			out.WriteString(text)
+			continue
+		}
+		out.WriteString(`<span class="` + css + `">`)
+		out.WriteString(text)
+		out.WriteString("</span>")
+	}
+	return out.String()
+}
@@ -0,0 +1,82 @@
+package highlight
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var ansiCode = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+func TestANSIColorsKeywords(t *testing.T) {
+	out := ANSI("let x = 5;")
+
+	want := ansiColors[keyword] + "let" + ansiReset
+	if !strings.HasPrefix(out, want) {
+		t.Fatalf("keyword not colored. want prefix %q, got=%q", want, out)
+	}
+	if !strings.Contains(out, ansiColors[literal]+"5"+ansiReset) {
+		t.Errorf("integer not colored. got=%q", out)
+	}
+	if !strings.Contains(out, " x = ") {
+		t.Errorf("identifier or whitespace changed. got=%q", out)
+	}
+}
+
+func TestANSIRoundTrip(t *testing.T) {
+	tests := []string{
+		"let x = 5;",
+		"let add = fn(a, b) {\n\treturn a + b; // sum\n};\n\n// done\n",
+		`let s = "hi ${name}!"; puts(s, 10u, 1.5f);`,
+		"if (true) { 1 } else { 2 } @ #",
+		`"unterminated`,
+		"",
+		"\xEF\xBB\xBFlet x = 1;",
+	}
+
+	for i, input := range tests {
+		out := ANSI(input)
+		if stripped := ansiCode.ReplaceAllString(out, ""); stripped != input {
+			t.Errorf("tests[%d] - stripping colors doesn't give the input back.\nwant=%q\ngot =%q", i, input, stripped)
+		}
+	}
+}
+
+func TestANSIColorsStringsAndComments(t *testing.T) {
+	out := ANSI(`"a" // b`)
+
+	expected := ansiColors[str] + `"a"` + ansiReset + " " + ansiColors[comment] + "// b" + ansiReset
+	if out != expected {
+		t.Errorf("wrong output.\nwant=%q\ngot =%q", expected, out)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`let s = "hi";`,
+			`<span class="kw">let</span> s = <span class="str">&#34;hi&#34;</span>;`,
+		},
+		{
+			"if (a < b && c > 1) { true }",
+			`<span class="kw">if</span> (a &lt; b &amp;&amp; c &gt; <span class="lit">1</span>) { <span class="lit">true</span> }`,
+		},
+		{
+			`"<b>'x' & y</b>" // <i>`,
+			`<span class="str">&#34;&lt;b&gt;&#39;x&#39; &amp; y&lt;/b&gt;&#34;</span> <span class="com">// &lt;i&gt;</span>`,
+		},
+		{
+			"fn() {\n\t1\n}",
+			"<span class=\"kw\">fn</span>() {\n\t<span class=\"lit\">1</span>\n}",
+		},
+	}
+
+	for i, tt := range tests {
+		if got := HTML(tt.input); got != tt.expected {
+			t.Errorf("tests[%d] - wrong HTML.\nwant=%q\ngot =%q", i, tt.expected, got)
+		}
+	}
+}
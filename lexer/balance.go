@@ -0,0 +1,48 @@
+package lexer
+
+import (
+	"fmt"
+	"monkey/diag"
+	"monkey/token"
+)
+
+// closers maps every opening delimiter to the one closing it.
+var closers = map[token.TokenType]token.TokenType{
+	token.LPAREN:   token.RPAREN,
+	token.LBRACKET: token.RBRACKET,
+	token.LBRACE:   token.RBRACE,
+}
+
+// CheckBalanced tokenizes input and reports the delimiters that aren't
+// matched: a closing one without an opening one before it, a closing one of
+// the wrong kind, like the ] in `(]`, and the opening ones still open at the
+// end. The delimiters inside strings and comments are text and don't count.
+// No diagnostics means the parentheses, brackets and braces are balanced,
+// not that the input parses.
+func CheckBalanced(input string) []diag.Diagnostic {
+	diagnostics := []diag.Diagnostic{}
+	var open []token.Token
+	l := New(input)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			open = append(open, tok)
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			if len(open) == 0 {
+				diagnostics = append(diagnostics, diag.Diagnostic{Pos: tok.Pos, Message: fmt.Sprintf("unexpected %s", tok.Literal)})
+				continue
+			}
+			opening := open[len(open)-1]
+			open = open[:len(open)-1]
+			if want := closers[opening.Type]; tok.Type != want {
+				msg := fmt.Sprintf("unexpected %s, want %s to close %s at %d:%d", tok.Literal, want, opening.Literal, opening.Pos.Line, opening.Pos.Column)
+				diagnostics = append(diagnostics, diag.Diagnostic{Pos: tok.Pos, Message: msg})
+			}
+		}
+	}
+	for _, opening := range open {
+		msg := fmt.Sprintf("unclosed %s", opening.Literal)
+		diagnostics = append(diagnostics, diag.Diagnostic{Pos: opening.Pos, Message: msg})
+	}
+	return diagnostics
+}
@@ -1,6 +1,12 @@
 package lexer
 
-import "monkey/token"
+import (
+	"fmt"
+	"monkey/token"
+	"strconv"
+	"strings"
+	"unicode"
+)
 
 // Lexer is a struct representing a lexical analyzer that processes an input string
 // and breaks it down into tokens for easier parsing and interpretation.
@@ -11,24 +17,128 @@ type Lexer struct {
 	position     int    // current position in input (points to current char)
 	readPosition int    // current reading position (after current char)
 	ch           byte   // current char under examination
+	line         int    // line of the current char, starting at 1
+	column       int    // column of the current char, starting at 1
+	lineStarts   []int  // offset of the first char of every line seen so far
+	base         int    // offset of input in the enclosing source, see NewAt
+	trivia       bool   // emit whitespace and comments as tokens, see EmitTrivia
+	newlines     bool   // emit line breaks as tokens, see EmitNewlines
+	errors       []string
+
+	// type of the last token that isn't trivia, which tells whether a /
+	// starts a regex or is a division
+	prev token.TokenType
+
+	// OnProgress, if set, is called as the lexer works its way through the
+	// input, with how many of its total bytes have been read. It is called
+	// about every progressInterval bytes and once more when reaching the end
+	// of the input, with bytesRead equal to total.
+	OnProgress func(bytesRead, total int)
+	reported   int // bytesRead of the last OnProgress call
 }
 
+// progressInterval is how many bytes the lexer reads between two OnProgress calls.
+const progressInterval = 64 * 1024
+
 // New initializes a new Lexer instance with the given input string.
 // It calls readChar to set the first character and returns the Lexer instance.
+// A UTF-8 byte order mark starting the input is skipped. Offsets still count
+// its bytes, columns don't.
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1, lineStarts: []int{0}}
 	l.readChar() // initialize the first character
+	if strings.HasPrefix(input, byteOrderMark) {
+		for i := 0; i < len(byteOrderMark); i++ {
+			l.readChar()
+		}
+		l.column = 1
+	}
 	return l
 }
 
-// NextToken examines the current character in the input string
-// and returns the next token based on the character type (identifier, digit, etc.).
-// It also skips over whitespace and returns an ILLEGAL token for unrecognized characters.
+const byteOrderMark = "\xEF\xBB\xBF"
+
+// NewAt is like New for input that is embedded in a larger source, such as
+// the expressions inside a template string. Token positions are reported
+// relative to the enclosing source, as if input started at start. The line
+// starts returned by All only cover the lines that begin inside input.
+func NewAt(input string, start token.Position) *Lexer {
+	l := &Lexer{input: input, line: start.Line, column: start.Column - 1, base: start.Offset}
+	l.readChar()
+	return l
+}
+
+// EmitTrivia makes the lexer return whitespace and comments as WHITESPACE and
+// COMMENT tokens instead of skipping them, so the tokens cover every byte of
+// the input. Tools like highlighters need that, the parser only does with
+// PreserveTrivia.
+func (l *Lexer) EmitTrivia(on bool) {
+	l.trivia = on
+}
+
+// Text returns the input between the offsets start and end, which are
+// relative to the enclosing source like the token positions.
+func (l *Lexer) Text(start, end int) string {
+	return l.input[start-l.base : end-l.base]
+}
+
+// EmitNewlines makes the lexer return every line break as a NEWLINE token
+// instead of skipping it, for parsers that end statements at the end of the
+// line. Line breaks joined by a line continuation are still skipped.
+func (l *Lexer) EmitNewlines(on bool) {
+	l.newlines = on
+}
+
+// NextToken skips over whitespace and comments and returns the next token,
+// stamped with the position of its first character.
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
+	if !l.trivia {
+		l.skipWhitespace()
+	}
 
-	// Skip any whitespace characters
-	l.skipWhitespace()
+	pos := token.Position{Offset: l.base + l.position, Line: l.line, Column: l.column}
+	tok := l.readToken()
+	tok.Pos = pos
+	switch tok.Type {
+	case token.WHITESPACE, token.COMMENT, token.NEWLINE:
+	default:
+		l.prev = tok.Type
+	}
+	if l.OnProgress != nil {
+		l.reportProgress()
+	}
+	return tok
+}
+
+func (l *Lexer) reportProgress() {
+	read := l.position
+	if read > len(l.input) {
+		read = len(l.input)
+	}
+	if read-l.reported >= progressInterval || read == len(l.input) && l.reported < read {
+		l.reported = read
+		l.OnProgress(read, len(l.input))
+	}
+}
+
+// All reads the rest of the input in one go. It returns the tokens up to and
+// including EOF, and the offset at which every line starts: lineStarts[i]
+// is the offset of line i+1, so lineStarts[0] is always 0.
+func (l *Lexer) All() (tokens []token.Token, lineStarts []int) {
+	for {
+		tok := l.NextToken()
+		tokens = append(tokens, tok)
+		if tok.Type == token.EOF {
+			return tokens, l.lineStarts
+		}
+	}
+}
+
+// readToken examines the current character in the input string
+// and returns the next token based on the character type (identifier, digit, etc.).
+// It returns an ILLEGAL token for unrecognized characters.
+func (l *Lexer) readToken() token.Token {
+	var tok token.Token
 
 	switch l.ch {
 	case '=':
@@ -36,13 +146,16 @@ func (l *Lexer) NextToken() token.Token {
 			ch := l.ch // create a new var with peeked char (= or !)
 			l.readChar()
 			tok = token.Token{Type: token.EQ, Literal: string(ch) + string(l.ch)}
+		} else if l.peekChar() == '>' {
+			l.readChar()
+			tok = token.Token{Type: token.ARROW, Literal: "=>"}
 		} else {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		tok = l.readOperator(token.PLUS, token.PLUS_ASSIGN)
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		tok = l.readOperator(token.MINUS, token.MINUS_ASSIGN)
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ch // create a new var with peeked char (= or !)
@@ -52,17 +165,78 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.BANG, l.ch)
 		}
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		// comments only get here when they are emitted, see EmitTrivia
+		if l.peekChar() == '/' {
+			return token.Token{Type: token.COMMENT, Literal: l.readComment()}
+		}
+		if expectsOperand(l.prev) {
+			if end := l.regexEnd(); end > 0 {
+				return l.readRegex(end)
+			}
+		}
+		tok = l.readOperator(token.SLASH, token.SLASH_ASSIGN)
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		if l.peekChar() == '*' {
+			l.readChar()
+			tok = l.readOperator(token.POWER, token.POWER_ASSIGN)
+		} else {
+			tok = l.readOperator(token.ASTERISK, token.ASTERISK_ASSIGN)
+		}
+	case '%':
+		tok = l.readOperator(token.PERCENT, token.PERCENT_ASSIGN)
+	case '&':
+		tok = l.readOperator(token.AMPERSAND, token.AMPERSAND_ASSIGN)
+	case '|':
+		if l.peekChar() == '>' {
+			l.readChar()
+			tok = token.Token{Type: token.PIPELINE, Literal: token.PIPELINE}
+		} else {
+			tok = l.readOperator(token.PIPE, token.PIPE_ASSIGN)
+		}
+	case '^':
+		tok = l.readOperator(token.CARET, token.CARET_ASSIGN)
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		if l.atHeredoc() {
+			return l.readHeredoc()
+		}
+		if l.peekChar() == '<' {
+			l.readChar()
+			tok = l.readOperator(token.SHIFT_LEFT, token.SHIFT_LEFT_ASSIGN)
+		} else {
+			tok = newToken(token.LT, l.ch)
+		}
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		if l.peekChar() == '>' {
+			l.readChar()
+			tok = l.readOperator(token.SHIFT_RIGHT, token.SHIFT_RIGHT_ASSIGN)
+		} else {
+			tok = newToken(token.GT, l.ch)
+		}
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
+	case ':':
+		tok = newToken(token.COLON, l.ch)
+	case '.':
+		// .5 is a float, any other dot is member access like obj.x
+		if isDigit(l.peekChar()) {
+			return l.readNumber()
+		}
+		if strings.HasPrefix(l.input[l.position:], token.ELLIPSIS) {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: token.ELLIPSIS}
+			break
+		}
+		tok = newToken(token.DOT, l.ch)
+	case '?':
+		if l.peekChar() == '.' {
+			l.readChar()
+			tok = token.Token{Type: token.OPTIONAL_CHAIN, Literal: token.OPTIONAL_CHAIN}
+			break
+		}
+		tok = newToken(token.ILLEGAL, l.ch)
 	case '(':
 		tok = newToken(token.LPAREN, l.ch)
 	case ')':
@@ -71,6 +245,32 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
 		tok = newToken(token.RBRACE, l.ch)
+	case '[':
+		tok = newToken(token.LBRACKET, l.ch)
+	case ']':
+		tok = newToken(token.RBRACKET, l.ch)
+	case ' ', '\t', '\n', '\r':
+		if l.ch == '\n' && l.newlines {
+			tok = newToken(token.NEWLINE, l.ch)
+			break
+		}
+		// like comments, whitespace only gets here when it is emitted
+		return l.readWhitespace()
+	case '\\':
+		if l.atLineContinuation() {
+			return l.readWhitespace()
+		}
+		tok = newToken(token.ILLEGAL, l.ch)
+	case '"':
+		var template bool
+		tok.Literal, template = l.readString()
+		tok.Type = token.STRING
+		if template {
+			tok.Type = token.TEMPLATE
+		}
+	case '`':
+		tok.Type = token.RAW_STRING
+		tok.Literal = l.readRawString()
 	default:
 		// Check if the character is the start of an identifier (e.g., a variable name)
 		if isLetter(l.ch) {
@@ -78,14 +278,14 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Type = token.LookupIdent(tok.Literal)
 			return tok
 		} else if isDigit(l.ch) {
-			// If the character is a digit, read the full number
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
-			return tok
+			// If the character is a digit, read the full number (INT or FLOAT)
+			return l.readNumber()
 		} else if l.ch == 0 {
-			// if it is end of line
+			// End of input. Don't advance, so that every further call returns
+			// an EOF positioned one past the last character.
 			tok.Type = token.EOF
 			tok.Literal = ""
+			return tok
 		} else {
 			// Return an ILLEGAL token for unrecognized characters
 			tok = newToken(token.ILLEGAL, l.ch)
@@ -95,12 +295,29 @@ func (l *Lexer) NextToken() token.Token {
 	return tok
 }
 
+// Errors returns the problems found in the input so far, such as malformed numbers.
+// The offending text is still returned as an ILLEGAL token.
+func (l *Lexer) Errors() []string {
+	return l.errors
+}
+
 // newToken creates a new token of the given type with the literal value as the character.
 // This is used for single-character tokens.
 func newToken(tokenType token.TokenType, ch byte) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
+// readOperator finishes an operator that has a compound assignment form, like
+// + and +=. The current char is the last one of op, if the next one is '='
+// it's the compound form. The literal of both is the same as their type.
+func (l *Lexer) readOperator(op, assign token.TokenType) token.Token {
+	if l.peekChar() == '=' {
+		l.readChar()
+		return token.Token{Type: assign, Literal: string(assign)}
+	}
+	return token.Token{Type: op, Literal: string(op)}
+}
+
 // readIdentifier reads a sequence of letters (a valid identifier) and returns it as a string.
 // This function stops reading when it encounters a non-letter character.
 func (l *Lexer) readIdentifier() string {
@@ -113,7 +330,20 @@ func (l *Lexer) readIdentifier() string {
 
 // readChar updates the Lexer's current character by advancing the readPosition.
 // If the end of the input is reached, it sets the current character to 0.
+// It also keeps line and column up to date: stepping past a newline starts a new line,
+// and columns are counted as described at token.ColumnAfter.
 func (l *Lexer) readChar() {
+	if l.readPosition > len(l.input) {
+		return // already at the end, stay there
+	}
+	if l.ch == '\n' {
+		l.line++
+		l.column = 1
+		l.lineStarts = append(l.lineStarts, l.base+l.readPosition)
+	} else {
+		l.column = token.ColumnAfter(l.column, l.ch)
+	}
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0 // ASCII code for NUL, indicates end of input
 	} else {
@@ -140,12 +370,99 @@ func isLetter(ch byte) bool {
 	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
 }
 
-// skipWhitespace advances the position until it encounters a non-whitespace character.
-// It skips spaces, tabs, newlines, and carriage returns.
+// skipWhitespace advances the position until it encounters a character that is
+// neither whitespace (spaces, tabs, newlines, carriage returns and line
+// continuations) nor part of a comment.
 func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+	for {
+		if l.atLineContinuation() {
+			l.skipLineContinuation()
+		} else if l.ch == ' ' || l.ch == '\t' {
+			l.skipSpaces()
+		} else if l.atBlank() {
+			l.readChar()
+		} else if l.ch == '/' && l.peekChar() == '/' {
+			l.readComment()
+		} else {
+			return
+		}
+	}
+}
+
+func isWhitespace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+}
+
+// skipSpaces advances past a run of spaces and tabs in one step. They don't
+// start a new line, so unlike readChar it only has to keep the column up to
+// date, which matters for input indented with long runs of them.
+func (l *Lexer) skipSpaces() {
+	end := l.position
+	for end < len(l.input) && (l.input[end] == ' ' || l.input[end] == '\t') {
+		l.column = token.ColumnAfter(l.column, l.input[end])
+		end++
+	}
+	l.position = end
+	l.readPosition = end + 1
+	if end < len(l.input) {
+		l.ch = l.input[end]
+	} else {
+		l.ch = 0
+	}
+}
+
+// atBlank reports whether the current character is whitespace that isn't
+// emitted as a token of its own.
+func (l *Lexer) atBlank() bool {
+	return isWhitespace(l.ch) && !(l.ch == '\n' && l.newlines)
+}
+
+// atLineContinuation reports whether the current character is a backslash
+// ending the line, which joins the next line to this one. The backslash and
+// the line break after it count as whitespace.
+func (l *Lexer) atLineContinuation() bool {
+	if l.ch != '\\' {
+		return false
+	}
+	next := l.peekChar()
+	if next == '\r' && l.readPosition+1 < len(l.input) {
+		next = l.input[l.readPosition+1]
+	}
+	return next == '\n'
+}
+
+// skipLineContinuation reads the backslash and line break of a line continuation.
+func (l *Lexer) skipLineContinuation() {
+	for l.ch != '\n' {
+		l.readChar()
+	}
+	l.readChar()
+}
+
+// readWhitespace reads a run of whitespace, line continuations included.
+func (l *Lexer) readWhitespace() token.Token {
+	position := l.position
+	for {
+		if l.atLineContinuation() {
+			l.skipLineContinuation()
+		} else if l.ch == ' ' || l.ch == '\t' {
+			l.skipSpaces()
+		} else if l.atBlank() {
+			l.readChar()
+		} else {
+			break
+		}
+	}
+	return token.Token{Type: token.WHITESPACE, Literal: l.input[position:l.position]}
+}
+
+// readComment reads a // comment up to, but not including, the end of the line.
+func (l *Lexer) readComment() string {
+	position := l.position
+	for l.ch != '\n' && l.ch != 0 {
 		l.readChar()
 	}
+	return l.input[position:l.position]
 }
 
 // isDigit checks if the given character is a digit (0-9).
@@ -153,12 +470,367 @@ func isDigit(ch byte) bool {
 	return '0' <= ch && ch <= '9'
 }
 
-// readNumber reads a sequence of digit characters and returns it as a string.
-// It stops reading when it encounters a non-digit character.
-func (l *Lexer) readNumber() string {
+// readString reads the characters between a pair of double quotes and returns them
+// without the quotes. An unterminated string runs until the end of the input.
+// It also reports whether the string is a template, a string with ${...}
+// interpolations. The interpolated code is left for the parser, the lexer
+// only skips over it so quotes and braces inside it don't end the string.
+func (l *Lexer) readString() (string, bool) {
+	position := l.position + 1
+	template := false
+	for {
+		l.readChar()
+		if l.ch == '\\' {
+			// the escaped character can't end the string or start an interpolation
+			l.readChar()
+			continue
+		}
+		if l.ch == '$' && l.peekChar() == '{' {
+			template = true
+			l.readChar()
+			l.skipInterpolation()
+			continue
+		}
+		if l.ch == '"' || l.ch == 0 {
+			break
+		}
+	}
+	return l.input[position:l.position], template
+}
+
+// atHeredoc reports whether the current character starts a heredoc, <<<
+// directly followed by the name of its delimiter.
+func (l *Lexer) atHeredoc() bool {
+	return strings.HasPrefix(l.input[l.position:], "<<<") &&
+		l.position+3 < len(l.input) && isLetter(l.input[l.position+3])
+}
+
+// readHeredoc reads a heredoc, a string taking up whole lines:
+//
+//	<<<END
+//	the lines of the string
+//	END
+//
+// The string is made of the lines between the line with <<< and the one
+// holding only the delimiter, without the line break before the delimiter.
+// Like in other strings escape sequences are decoded, by the parser.
+func (l *Lexer) readHeredoc() token.Token {
 	position := l.position
-	for isDigit(l.ch) {
+	l.readChar()
+	l.readChar()
+	l.readChar()
+	delimiter := l.readIdentifier()
+	if l.ch == '\r' && l.peekChar() == '\n' {
 		l.readChar()
 	}
+	if l.ch == 0 {
+		return l.heredocError(position, fmt.Sprintf("unterminated heredoc, expected a line with %s", delimiter))
+	}
+	if l.ch != '\n' {
+		return l.heredocError(position, fmt.Sprintf("heredoc delimiter %s must end the line", delimiter))
+	}
+
+	start := l.position + 1 // of the first line of the string
+	for {
+		l.readChar() // past the line break
+		lineStart := l.position
+		for l.ch != '\n' && l.ch != 0 {
+			l.readChar()
+		}
+		if strings.TrimSuffix(l.input[lineStart:l.position], "\r") == delimiter {
+			if lineStart == start {
+				return token.Token{Type: token.STRING, Literal: ""}
+			}
+			text := l.input[start : lineStart-1]
+			return token.Token{Type: token.STRING, Literal: strings.TrimSuffix(text, "\r")}
+		}
+		if l.ch == 0 {
+			return l.heredocError(position, fmt.Sprintf("unterminated heredoc, expected a line with %s", delimiter))
+		}
+	}
+}
+
+// heredocError reports a malformed heredoc and returns it as an ILLEGAL token
+// holding the rest of the input from position on.
+func (l *Lexer) heredocError(position int, msg string) token.Token {
+	for l.ch != 0 {
+		l.readChar()
+	}
+	l.errors = append(l.errors, msg)
+	return token.Token{Type: token.ILLEGAL, Literal: l.input[position:l.position]}
+}
+
+// expectsOperand reports whether a value rather than an operator comes after
+// a token of type prev, so that a / there starts a regex instead of being a
+// division. An empty prev is the start of the input.
+func expectsOperand(prev token.TokenType) bool {
+	switch prev {
+	case token.IDENT, token.INT, token.FLOAT, token.STRING, token.TEMPLATE, token.RAW_STRING, token.REGEX,
+		token.TRUE, token.FALSE, token.UNDERSCORE, token.RPAREN, token.RBRACKET, token.RBRACE:
+		return false
+	}
+	return true
+}
+
+// regexEnd returns the offset in input of the slash that would close a regex
+// starting at the current slash, or 0 if there is none. A regex has to end on
+// the line it starts on, and a backslash escapes the character after it, so
+// \/ doesn't end it. Without a closing slash the / is an operator after all.
+func (l *Lexer) regexEnd() int {
+	for i := l.position + 1; i < len(l.input) && l.input[i] != '\n'; i++ {
+		switch l.input[i] {
+		case '\\':
+			if i+1 < len(l.input) && l.input[i+1] == '\n' {
+				return 0
+			}
+			i++
+		case '/':
+			return i
+		}
+	}
+	return 0
+}
+
+// readRegex reads a regular expression ending at the slash at offset end, see
+// regexEnd, and returns it as a REGEX token without the slashes.
+func (l *Lexer) readRegex(end int) token.Token {
+	position := l.position
+	for l.position <= end {
+		l.readChar()
+	}
+	return token.Token{Type: token.REGEX, Literal: l.input[position+1 : end]}
+}
+
+// readRawString reads the characters between a pair of backquotes. They are
+// taken as they are, line breaks included, only a backquote ends the string.
+func (l *Lexer) readRawString() string {
+	position := l.position + 1
+	for {
+		l.readChar()
+		if l.ch == '`' || l.ch == 0 {
+			break
+		}
+	}
 	return l.input[position:l.position]
-}
\ No newline at end of file
+}
+
+// Unescape returns the text of a string literal with its escape sequences
+// replaced by the characters they stand for: \n, \t, \r, \\, \", \$ and
+// \u{...} with the hex code point of a Unicode character.
+func Unescape(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			out.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i == len(s) {
+			return "", fmt.Errorf("unterminated escape sequence")
+		}
+		switch s[i] {
+		case 'n':
+			out.WriteByte('\n')
+		case 't':
+			out.WriteByte('\t')
+		case 'r':
+			out.WriteByte('\r')
+		case '\\', '"', '$':
+			out.WriteByte(s[i])
+		case 'u':
+			end := strings.IndexByte(s[i:], '}')
+			if i+1 == len(s) || s[i+1] != '{' || end < 0 {
+				return "", fmt.Errorf("\\u must be followed by a code point in braces, like \\u{e9}")
+			}
+			digits := s[i+2 : i+end]
+			code, err := strconv.ParseUint(digits, 16, 32)
+			if err != nil || code > unicode.MaxRune {
+				return "", fmt.Errorf("invalid code point \\u{%s}", digits)
+			}
+			out.WriteRune(rune(code))
+			i += end
+		default:
+			return "", fmt.Errorf("unknown escape sequence \\%c", s[i])
+		}
+	}
+	return out.String(), nil
+}
+
+// skipInterpolation moves from the { of a ${ to the } closing it, stepping
+// over nested braces and strings.
+func (l *Lexer) skipInterpolation() {
+	depth := 1
+	for depth > 0 {
+		l.readChar()
+		switch l.ch {
+		case 0:
+			return
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '"':
+			l.readString()
+		}
+	}
+}
+
+// readNumber reads a numeric literal and returns it as an INT or FLOAT token.
+// It works like a small state machine:
+//
+//	prefix:   0x / 0b / 0o select base 16, 2 or 8, anything else is decimal
+//	digits:   digits of the base, '_' is allowed between two digits
+//	fraction: '.' followed by decimal digits (decimal numbers only)
+//	exponent: 'e' or 'E', an optional sign and decimal digits (decimal numbers only)
+//	suffix:   letters right after the number, stored in Token.Suffix (see applySuffix)
+//
+// The integer part may be missing (.5 is the float 0.5) and so may the digits
+// of the fraction (5. is the float 5.0). A dot followed by a letter is never
+// part of a number though, so 5.abs stays member access on the integer 5.
+//
+// The token is always maximal: if a malformed number runs into letters, digits
+// or a fraction (like 0x1.5 or 12ab), all of it becomes a single ILLEGAL token
+// and the reason is recorded in Errors.
+func (l *Lexer) readNumber() token.Token {
+	position := l.position
+	tokType := token.TokenType(token.INT)
+
+	base, baseName := 10, "decimal literal"
+	problem := ""
+	// a number like .5 has no integer part and starts right at its fraction
+	if l.ch != '.' {
+		if l.ch == '0' {
+			switch l.peekChar() {
+			case 'x', 'X':
+				base, baseName = 16, "hexadecimal literal"
+			case 'b', 'B':
+				base, baseName = 2, "binary literal"
+			case 'o', 'O':
+				base, baseName = 8, "octal literal"
+			}
+			if base != 10 {
+				l.readChar()
+				l.readChar()
+			}
+		}
+		problem = l.readDigits(base, baseName)
+	}
+
+	if base == 10 && problem == "" {
+		if l.ch == '.' && isDigit(l.peekChar()) {
+			tokType = token.FLOAT
+			l.readChar()
+			problem = l.readDigits(10, "fraction")
+		} else if l.ch == '.' && !isLetter(l.peekChar()) {
+			tokType = token.FLOAT
+			l.readChar()
+		}
+		if problem == "" && (l.ch == 'e' || l.ch == 'E') {
+			tokType = token.FLOAT
+			l.readChar()
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+			problem = l.readDigits(10, "exponent")
+		}
+	} else if problem == "" && l.ch == '.' && isDigit(l.peekChar()) {
+		problem = baseName + "s cannot have a fraction"
+	}
+
+	end := l.position
+	suffix := ""
+	if problem == "" && isLetter(l.ch) {
+		for isLetter(l.ch) || isDigit(l.ch) {
+			l.readChar()
+		}
+		suffix = l.input[end:l.position]
+		tokType, problem = applySuffix(suffix, tokType, baseName)
+	} else if problem == "" && isDigit(l.ch) {
+		problem = fmt.Sprintf("unexpected %q in number", l.ch)
+	}
+
+	if problem != "" {
+		// swallow the rest of the malformed number so it is reported only once
+		for isLetter(l.ch) || isDigit(l.ch) || (l.ch == '.' && isDigit(l.peekChar())) {
+			l.readChar()
+		}
+		literal := l.input[position:l.position]
+		l.errors = append(l.errors, fmt.Sprintf("malformed number %q: %s", literal, problem))
+		return token.Token{Type: token.ILLEGAL, Literal: literal}
+	}
+	return token.Token{Type: tokType, Literal: l.input[position:end], Suffix: suffix}
+}
+
+// applySuffix checks the type suffix of a number and returns the type of the
+// number with the suffix applied, or a description of what is wrong with it.
+// The suffixes are u (unsigned) and L (long) for integers and f for floats;
+// an integer literal with an f is a float.
+func applySuffix(suffix string, tokType token.TokenType, baseName string) (token.TokenType, string) {
+	switch suffix {
+	case "u", "L":
+		if tokType == token.FLOAT {
+			return tokType, fmt.Sprintf("suffix %q is not allowed on a float", suffix)
+		}
+	case "f":
+		if baseName != "decimal literal" {
+			return tokType, fmt.Sprintf("suffix %q is not allowed on a %s", suffix, baseName)
+		}
+		tokType = token.FLOAT
+	default:
+		return tokType, fmt.Sprintf("unknown suffix %q", suffix)
+	}
+	return tokType, ""
+}
+
+// readDigits reads a run of digits of the given base, allowing single underscores
+// between digits. It returns a description of what is wrong with the run,
+// or an empty string if it is well formed. what names the run in that description.
+func (l *Lexer) readDigits(base int, what string) string {
+	count := 0
+	lastUnderscore := false
+	for {
+		switch {
+		case l.ch == '_':
+			if count == 0 || lastUnderscore {
+				return "'_' must separate digits"
+			}
+			lastUnderscore = true
+		case isDigit(l.ch) || (base == 16 && isHexDigit(l.ch)):
+			if digitValue(l.ch) >= base {
+				return fmt.Sprintf("invalid digit %q in %s", l.ch, what)
+			}
+			count++
+			lastUnderscore = false
+		default:
+			if count == 0 {
+				return fmt.Sprintf("%s has no digits", what)
+			}
+			if lastUnderscore {
+				return "'_' must separate digits"
+			}
+			return ""
+		}
+		l.readChar()
+	}
+}
+
+// isHexDigit checks if the given character is a hexadecimal digit (0-9, a-f, A-F).
+func isHexDigit(ch byte) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+// digitValue returns the numeric value of a (hexadecimal) digit character.
+func digitValue(ch byte) int {
+	switch {
+	case isDigit(ch):
+		return int(ch - '0')
+	case 'a' <= ch && ch <= 'f':
+		return int(ch-'a') + 10
+	default:
+		return int(ch-'A') + 10
+	}
+}
@@ -2,6 +2,7 @@ package lexer
 
 import (
 	"monkey/token"
+	"strings"
 	"testing"
 )
 
@@ -27,6 +28,10 @@ func TestNextToken(t *testing.T) {
 
         10 == 10;
         10 != 9;
+        "foobar"
+        "foo bar"
+        [1, 2];
+        {"foo": "bar"}
     `
 
     tests := []struct {
@@ -110,6 +115,19 @@ func TestNextToken(t *testing.T) {
         {token.NOT_EQ, "!="},
         {token.INT, "9"},
         {token.SEMICOLON, ";"},
+        {token.STRING, "foobar"},
+        {token.STRING, "foo bar"},
+        {token.LBRACKET, "["},
+        {token.INT, "1"},
+        {token.COMMA, ","},
+        {token.INT, "2"},
+        {token.RBRACKET, "]"},
+        {token.SEMICOLON, ";"},
+        {token.LBRACE, "{"},
+        {token.STRING, "foo"},
+        {token.COLON, ":"},
+        {token.STRING, "bar"},
+        {token.RBRACE, "}"},
 
         {token.EOF, ""},
     }
@@ -128,4 +146,817 @@ func TestNextToken(t *testing.T) {
 				i, tt.expectedLiteral, tok.Literal)
 		}
 	}
-}
\ No newline at end of file
+}
+func TestNumberLiterals(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedError   string
+	}{
+		{"0", token.INT, "0", ""},
+		{"00", token.INT, "00", ""},
+		{"007", token.INT, "007", ""},
+		{"1_000_000", token.INT, "1_000_000", ""},
+		{"0x1F", token.INT, "0x1F", ""},
+		{"0b1010_1010", token.INT, "0b1010_1010", ""},
+		{"0o777", token.INT, "0o777", ""},
+		{"3.14", token.FLOAT, "3.14", ""},
+		{"1_000.5e-3", token.FLOAT, "1_000.5e-3", ""},
+		{"2e10", token.FLOAT, "2e10", ""},
+		{"6.02E+23", token.FLOAT, "6.02E+23", ""},
+		{".5e2", token.FLOAT, ".5e2", ""},
+		{"0x1.5", token.ILLEGAL, "0x1.5", `malformed number "0x1.5": hexadecimal literals cannot have a fraction`},
+		{"0b102", token.ILLEGAL, "0b102", `malformed number "0b102": invalid digit '2' in binary literal`},
+		{"0x", token.ILLEGAL, "0x", `malformed number "0x": hexadecimal literal has no digits`},
+		{"0x.5", token.ILLEGAL, "0x.5", `malformed number "0x.5": hexadecimal literal has no digits`},
+		{"1__0", token.ILLEGAL, "1__0", `malformed number "1__0": '_' must separate digits`},
+		{"1_", token.ILLEGAL, "1_", `malformed number "1_": '_' must separate digits`},
+		{"1e", token.ILLEGAL, "1e", `malformed number "1e": exponent has no digits`},
+		{"1.5e+x", token.ILLEGAL, "1.5e+x", `malformed number "1.5e+x": exponent has no digits`},
+		{"12ab", token.ILLEGAL, "12ab", `malformed number "12ab": unknown suffix "ab"`},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input + ";")
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		// the number must be maximal, so the next token is the semicolon
+		if next := l.NextToken(); next.Type != token.SEMICOLON {
+			t.Fatalf("tests[%d] - number not maximal, next token is %q", i, next.Literal)
+		}
+
+		errors := l.Errors()
+		if tt.expectedError == "" {
+			if len(errors) != 0 {
+				t.Fatalf("tests[%d] - unexpected errors: %v", i, errors)
+			}
+			continue
+		}
+		if len(errors) != 1 || errors[0] != tt.expectedError {
+			t.Fatalf("tests[%d] - errors wrong. expected=%q, got=%q", i, tt.expectedError, errors)
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  x +\n\"ab\" 10"
+
+	tests := []struct {
+		expectedLiteral string
+		expectedPos     token.Position
+	}{
+		{"let", token.Position{Offset: 0, Line: 1, Column: 1}},
+		{"x", token.Position{Offset: 4, Line: 1, Column: 5}},
+		{"=", token.Position{Offset: 6, Line: 1, Column: 7}},
+		{"5", token.Position{Offset: 8, Line: 1, Column: 9}},
+		{";", token.Position{Offset: 9, Line: 1, Column: 10}},
+		{"x", token.Position{Offset: 13, Line: 2, Column: 3}},
+		{"+", token.Position{Offset: 15, Line: 2, Column: 5}},
+		{"ab", token.Position{Offset: 17, Line: 3, Column: 1}},
+		{"10", token.Position{Offset: 22, Line: 3, Column: 6}},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Pos != tt.expectedPos {
+			t.Fatalf("tests[%d] - position of %q wrong. expected=%+v, got=%+v", i, tok.Literal, tt.expectedPos, tok.Pos)
+		}
+	}
+}
+
+func TestRegexOrDivision(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Token
+	}{
+		{"x / y", []token.Token{{Type: token.IDENT, Literal: "x"}, {Type: token.SLASH, Literal: "/"}, {Type: token.IDENT, Literal: "y"}}},
+		{"match(/ab+/)", []token.Token{{Type: token.MATCH, Literal: "match"}, {Type: token.LPAREN, Literal: "("}, {Type: token.REGEX, Literal: "ab+"}, {Type: token.RPAREN, Literal: ")"}}},
+		{"(/a/)", []token.Token{{Type: token.LPAREN, Literal: "("}, {Type: token.REGEX, Literal: "a"}, {Type: token.RPAREN, Literal: ")"}}},
+		{"a / b / c", []token.Token{{Type: token.IDENT, Literal: "a"}, {Type: token.SLASH, Literal: "/"}, {Type: token.IDENT, Literal: "b"}, {Type: token.SLASH, Literal: "/"}, {Type: token.IDENT, Literal: "c"}}},
+		{"(a) / 2 / 1", []token.Token{{Type: token.LPAREN, Literal: "("}, {Type: token.IDENT, Literal: "a"}, {Type: token.RPAREN, Literal: ")"}, {Type: token.SLASH, Literal: "/"}, {Type: token.INT, Literal: "2"}, {Type: token.SLASH, Literal: "/"}, {Type: token.INT, Literal: "1"}}},
+		{"x = /a\\/b/ // c", []token.Token{{Type: token.IDENT, Literal: "x"}, {Type: token.ASSIGN, Literal: "="}, {Type: token.REGEX, Literal: "a\\/b"}}},
+		{"[/=/, /x/]", []token.Token{{Type: token.LBRACKET, Literal: "["}, {Type: token.REGEX, Literal: "="}, {Type: token.COMMA, Literal: ","}, {Type: token.REGEX, Literal: "x"}, {Type: token.RBRACKET, Literal: "]"}}},
+		// without a closing slash on the line, a / is an operator
+		{"-/2\n/", []token.Token{{Type: token.MINUS, Literal: "-"}, {Type: token.SLASH, Literal: "/"}, {Type: token.INT, Literal: "2"}, {Type: token.SLASH, Literal: "/"}}},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		for j, expected := range append(tt.expected, token.Token{Type: token.EOF}) {
+			tok := l.NextToken()
+			if tok.Type != expected.Type || tok.Literal != expected.Literal {
+				t.Fatalf("tests[%d] (%q) - token %d wrong. expected=%s %q, got=%s %q",
+					i, tt.input, j, expected.Type, expected.Literal, tok.Type, tok.Literal)
+			}
+		}
+	}
+
+	// trivia between the tokens doesn't change their context
+	l := New("2 // two\n /a/")
+	l.EmitTrivia(true)
+	types := []token.TokenType{}
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		types = append(types, tok.Type)
+	}
+	expected := []token.TokenType{token.INT, token.WHITESPACE, token.COMMENT, token.WHITESPACE, token.SLASH, token.IDENT, token.SLASH}
+	if len(types) != len(expected) {
+		t.Fatalf("wrong tokens. expected=%v, got=%v", expected, types)
+	}
+	for i := range expected {
+		if types[i] != expected[i] {
+			t.Errorf("token %d wrong. expected=%s, got=%s", i, expected[i], types[i])
+		}
+	}
+}
+
+func TestDotDisambiguation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Token
+	}{
+		{"obj.x", []token.Token{{Type: token.IDENT, Literal: "obj"}, {Type: token.DOT, Literal: "."}, {Type: token.IDENT, Literal: "x"}}},
+		{"5.", []token.Token{{Type: token.FLOAT, Literal: "5."}}},
+		{"5. + 1", []token.Token{{Type: token.FLOAT, Literal: "5."}, {Type: token.PLUS, Literal: "+"}, {Type: token.INT, Literal: "1"}}},
+		{".5", []token.Token{{Type: token.FLOAT, Literal: ".5"}}},
+		{"5.5", []token.Token{{Type: token.FLOAT, Literal: "5.5"}}},
+		{"5.abs", []token.Token{{Type: token.INT, Literal: "5"}, {Type: token.DOT, Literal: "."}, {Type: token.IDENT, Literal: "abs"}}},
+		{"a . b", []token.Token{{Type: token.IDENT, Literal: "a"}, {Type: token.DOT, Literal: "."}, {Type: token.IDENT, Literal: "b"}}},
+		{"...a", []token.Token{{Type: token.ELLIPSIS, Literal: "..."}, {Type: token.IDENT, Literal: "a"}}},
+		{"a..b", []token.Token{{Type: token.IDENT, Literal: "a"}, {Type: token.DOT, Literal: "."}, {Type: token.DOT, Literal: "."}, {Type: token.IDENT, Literal: "b"}}},
+		{"....5", []token.Token{{Type: token.ELLIPSIS, Literal: "..."}, {Type: token.FLOAT, Literal: ".5"}}},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		for j, expected := range append(tt.expected, token.Token{Type: token.EOF}) {
+			tok := l.NextToken()
+			if tok.Type != expected.Type || tok.Literal != expected.Literal {
+				t.Fatalf("tests[%d] (%q) - token %d wrong. expected=%s %q, got=%s %q",
+					i, tt.input, j, expected.Type, expected.Literal, tok.Type, tok.Literal)
+			}
+		}
+		if len(l.Errors()) != 0 {
+			t.Fatalf("tests[%d] - unexpected errors: %v", i, l.Errors())
+		}
+	}
+}
+
+func TestEOFPosition(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedPos token.Position
+	}{
+		{"abc", token.Position{Offset: 3, Line: 1, Column: 4}},
+		{"abc\n", token.Position{Offset: 4, Line: 2, Column: 1}},
+		{"", token.Position{Offset: 0, Line: 1, Column: 1}},
+		{"a\n  ", token.Position{Offset: 4, Line: 2, Column: 3}},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+
+		tok := l.NextToken()
+		for tok.Type != token.EOF {
+			tok = l.NextToken()
+		}
+		if tok.Pos != tt.expectedPos {
+			t.Fatalf("tests[%d] - EOF position wrong. expected=%+v, got=%+v", i, tt.expectedPos, tok.Pos)
+		}
+
+		again := l.NextToken()
+		if again.Type != token.EOF || again.Pos != tt.expectedPos {
+			t.Fatalf("tests[%d] - repeated EOF wrong. expected=%+v, got=%s at %+v", i, tt.expectedPos, again.Type, again.Pos)
+		}
+	}
+}
+
+func TestAll(t *testing.T) {
+	input := "let x = 5;\n\n  x +\n\"a\nb\" 10\n"
+
+	tokens, lineStarts := New(input).All()
+
+	expectedStarts := []int{0, 11, 12, 18, 21, 27}
+	if len(lineStarts) != len(expectedStarts) {
+		t.Fatalf("wrong number of line starts. expected=%v, got=%v", expectedStarts, lineStarts)
+	}
+	for i, start := range expectedStarts {
+		if lineStarts[i] != start {
+			t.Fatalf("lineStarts[%d] wrong. expected=%d, got=%d", i, start, lineStarts[i])
+		}
+	}
+
+	if len(tokens) != 10 || tokens[len(tokens)-1].Type != token.EOF {
+		t.Fatalf("wrong tokens. got=%+v", tokens)
+	}
+
+	for i, tok := range tokens {
+		line := 0
+		for line+1 < len(lineStarts) && lineStarts[line+1] <= tok.Pos.Offset {
+			line++
+		}
+		column := tok.Pos.Offset - lineStarts[line] + 1
+		if line+1 != tok.Pos.Line || column != tok.Pos.Column {
+			t.Errorf("tokens[%d] - %q at offset %d maps to %d:%d, reported %d:%d",
+				i, tok.Literal, tok.Pos.Offset, line+1, column, tok.Pos.Line, tok.Pos.Column)
+		}
+	}
+}
+
+func TestTemplateStrings(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{`"plain"`, token.STRING, "plain"},
+		{`"$x {y}"`, token.STRING, "$x {y}"},
+		{`"a ${b} c"`, token.TEMPLATE, "a ${b} c"},
+		{`"${b}"`, token.TEMPLATE, "${b}"},
+		{`"${f("}")} x"`, token.TEMPLATE, `${f("}")} x`},
+		{`"${ {"a": 1}["a"] }"`, token.TEMPLATE, `${ {"a": 1}["a"] }`},
+		{`"${"${x}"}"`, token.TEMPLATE, `${"${x}"}`},
+		{`"${x`, token.TEMPLATE, "${x"},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		if next := l.NextToken(); next.Type != token.EOF {
+			t.Fatalf("tests[%d] - expected EOF after the string. got=%q (%q)", i, next.Type, next.Literal)
+		}
+	}
+}
+
+func TestNewAt(t *testing.T) {
+	l := NewAt("a +\n b", token.Position{Offset: 10, Line: 3, Column: 5})
+
+	expected := []token.Position{
+		{Offset: 10, Line: 3, Column: 5},
+		{Offset: 12, Line: 3, Column: 7},
+		{Offset: 15, Line: 4, Column: 2},
+		{Offset: 16, Line: 4, Column: 3},
+	}
+	for i, pos := range expected {
+		tok := l.NextToken()
+		if tok.Pos != pos {
+			t.Fatalf("tests[%d] - position of %q wrong. expected=%+v, got=%+v", i, tok.Literal, pos, tok.Pos)
+		}
+	}
+}
+
+func TestNumberSuffixes(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedSuffix  string
+		expectedError   string
+	}{
+		{"10", token.INT, "10", "", ""},
+		{"10u", token.INT, "10", "u", ""},
+		{"42L", token.INT, "42", "L", ""},
+		{"0xffu", token.INT, "0xff", "u", ""},
+		{"3.0f", token.FLOAT, "3.0", "f", ""},
+		{"3f", token.FLOAT, "3", "f", ""},
+		{"1e3f", token.FLOAT, "1e3", "f", ""},
+		{"3.0", token.FLOAT, "3.0", "", ""},
+		{"10z", token.ILLEGAL, "10z", "", `malformed number "10z": unknown suffix "z"`},
+		{"10uL", token.ILLEGAL, "10uL", "", `malformed number "10uL": unknown suffix "uL"`},
+		{"1.5u", token.ILLEGAL, "1.5u", "", `malformed number "1.5u": suffix "u" is not allowed on a float`},
+		{"0b1f", token.ILLEGAL, "0b1f", "", `malformed number "0b1f": suffix "f" is not allowed on a binary literal`},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input + ";")
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Suffix != tt.expectedSuffix {
+			t.Fatalf("tests[%d] - suffix wrong. expected=%q, got=%q", i, tt.expectedSuffix, tok.Suffix)
+		}
+		if next := l.NextToken(); next.Type != token.SEMICOLON {
+			t.Fatalf("tests[%d] - number not maximal, next token is %q", i, next.Literal)
+		}
+
+		errors := l.Errors()
+		if tt.expectedError == "" && len(errors) != 0 {
+			t.Fatalf("tests[%d] - unexpected errors: %v", i, errors)
+		}
+		if tt.expectedError != "" && (len(errors) != 1 || errors[0] != tt.expectedError) {
+			t.Fatalf("tests[%d] - errors wrong. expected=%q, got=%q", i, tt.expectedError, errors)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := "let x = 1; // the count\n// a whole line\nx / 2 //"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%q %q, got=%q %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestEmitTrivia(t *testing.T) {
+	input := "let  x = 1; // the count\n\tx"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedOffset  int
+	}{
+		{token.LET, "let", 0},
+		{token.WHITESPACE, "  ", 3},
+		{token.IDENT, "x", 5},
+		{token.WHITESPACE, " ", 6},
+		{token.ASSIGN, "=", 7},
+		{token.WHITESPACE, " ", 8},
+		{token.INT, "1", 9},
+		{token.SEMICOLON, ";", 10},
+		{token.WHITESPACE, " ", 11},
+		{token.COMMENT, "// the count", 12},
+		{token.WHITESPACE, "\n\t", 24},
+		{token.IDENT, "x", 26},
+		{token.EOF, "", 27},
+	}
+
+	l := New(input)
+	l.EmitTrivia(true)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%q %q, got=%q %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		if tok.Pos.Offset != tt.expectedOffset {
+			t.Fatalf("tests[%d] - offset wrong. expected=%d, got=%d", i, tt.expectedOffset, tok.Pos.Offset)
+		}
+	}
+}
+
+func TestLongWhitespaceRun(t *testing.T) {
+	spaces := strings.Repeat(" \t  ", 250)
+	input := "x" + spaces + "y\n" + spaces + "z"
+
+	l := New(input)
+	l.EmitTrivia(true)
+	tokens, _ := l.All()
+
+	expected := []token.Token{
+		{Type: token.IDENT, Literal: "x", Pos: token.Position{Offset: 0, Line: 1, Column: 1}},
+		{Type: token.WHITESPACE, Literal: spaces, Pos: token.Position{Offset: 1, Line: 1, Column: 2}},
+		{Type: token.IDENT, Literal: "y", Pos: token.Position{Offset: 1001, Line: 1, Column: 1002}},
+		{Type: token.WHITESPACE, Literal: "\n" + spaces, Pos: token.Position{Offset: 1002, Line: 1, Column: 1003}},
+		{Type: token.IDENT, Literal: "z", Pos: token.Position{Offset: 2003, Line: 2, Column: 1001}},
+		{Type: token.EOF, Literal: "", Pos: token.Position{Offset: 2004, Line: 2, Column: 1002}},
+	}
+	if len(tokens) != len(expected) {
+		t.Fatalf("wrong number of tokens. expected=%d, got=%d", len(expected), len(tokens))
+	}
+	for i, tok := range tokens {
+		if tok.Type != expected[i].Type || tok.Literal != expected[i].Literal || tok.Pos != expected[i].Pos {
+			t.Errorf("tests[%d] - wrong token. expected=%q at %+v, got=%q at %+v", i, expected[i].Type, expected[i].Pos, tok.Type, tok.Pos)
+		}
+	}
+
+	// skipping the run gives the same positions
+	l = New(input)
+	l.NextToken()
+	if tok := l.NextToken(); tok.Pos != expected[2].Pos {
+		t.Errorf("wrong position after skipped whitespace. expected=%+v, got=%+v", expected[2].Pos, tok.Pos)
+	}
+}
+
+func BenchmarkLexIndented(b *testing.B) {
+	line := strings.Repeat(" ", 64) + "let x = [1,    2,    3];\n"
+	input := strings.Repeat(line, 100)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l := New(input)
+		for l.NextToken().Type != token.EOF {
+		}
+	}
+}
+
+func TestOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.TokenType
+	}{
+		{"x+=1", []token.TokenType{token.IDENT, token.PLUS_ASSIGN, token.INT}},
+		{"x-=1", []token.TokenType{token.IDENT, token.MINUS_ASSIGN, token.INT}},
+		{"x*=1", []token.TokenType{token.IDENT, token.ASTERISK_ASSIGN, token.INT}},
+		{"x/=1", []token.TokenType{token.IDENT, token.SLASH_ASSIGN, token.INT}},
+		{"x%=1", []token.TokenType{token.IDENT, token.PERCENT_ASSIGN, token.INT}},
+		{"x**=1", []token.TokenType{token.IDENT, token.POWER_ASSIGN, token.INT}},
+		{"x&=1", []token.TokenType{token.IDENT, token.AMPERSAND_ASSIGN, token.INT}},
+		{"x|=1", []token.TokenType{token.IDENT, token.PIPE_ASSIGN, token.INT}},
+		{"x^=1", []token.TokenType{token.IDENT, token.CARET_ASSIGN, token.INT}},
+		{"x<<=1", []token.TokenType{token.IDENT, token.SHIFT_LEFT_ASSIGN, token.INT}},
+		{"x>>=1", []token.TokenType{token.IDENT, token.SHIFT_RIGHT_ASSIGN, token.INT}},
+		{"x%1", []token.TokenType{token.IDENT, token.PERCENT, token.INT}},
+		{"x**1", []token.TokenType{token.IDENT, token.POWER, token.INT}},
+		{"x*-1", []token.TokenType{token.IDENT, token.ASTERISK, token.MINUS, token.INT}},
+		{"x&y|z^w", []token.TokenType{token.IDENT, token.AMPERSAND, token.IDENT, token.PIPE, token.IDENT, token.CARET, token.IDENT}},
+		{"x|>f|y", []token.TokenType{token.IDENT, token.PIPELINE, token.IDENT, token.PIPE, token.IDENT}},
+		{"x?.0?.y", []token.TokenType{token.IDENT, token.OPTIONAL_CHAIN, token.INT, token.OPTIONAL_CHAIN, token.IDENT}},
+		{"x<<1", []token.TokenType{token.IDENT, token.SHIFT_LEFT, token.INT}},
+		{"x>>1", []token.TokenType{token.IDENT, token.SHIFT_RIGHT, token.INT}},
+		{"x<1", []token.TokenType{token.IDENT, token.LT, token.INT}},
+		{"x>1", []token.TokenType{token.IDENT, token.GT, token.INT}},
+		{"x<=1", []token.TokenType{token.IDENT, token.LT, token.ASSIGN, token.INT}},
+		{"x<<==1", []token.TokenType{token.IDENT, token.SHIFT_LEFT_ASSIGN, token.ASSIGN, token.INT}},
+		{"x=+1", []token.TokenType{token.IDENT, token.ASSIGN, token.PLUS, token.INT}},
+		{"x==1", []token.TokenType{token.IDENT, token.EQ, token.INT}},
+		{"x***1", []token.TokenType{token.IDENT, token.POWER, token.ASTERISK, token.INT}},
+		{"x/ /1", []token.TokenType{token.IDENT, token.SLASH, token.SLASH, token.INT}},
+		{"x=>1", []token.TokenType{token.IDENT, token.ARROW, token.INT}},
+		{"_=>_x", []token.TokenType{token.UNDERSCORE, token.ARROW, token.IDENT}},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		for j, expectedType := range append(tt.expected, token.EOF) {
+			tok := l.NextToken()
+			if tok.Type != expectedType {
+				t.Fatalf("tests[%d] - token %d of %q wrong. expected=%q, got=%q", i, j, tt.input, expectedType, tok.Type)
+			}
+			if expectedType != token.IDENT && expectedType != token.INT && expectedType != token.EOF && tok.Literal != string(expectedType) {
+				t.Fatalf("tests[%d] - literal of token %d wrong. expected=%q, got=%q", i, j, expectedType, tok.Literal)
+			}
+		}
+	}
+}
+
+func TestLineContinuation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.TokenType
+	}{
+		{"1 + \\\n2", []token.TokenType{token.INT, token.PLUS, token.INT}},
+		{"1 + \\\r\n  2", []token.TokenType{token.INT, token.PLUS, token.INT}},
+		{"x \\\n\\\n+ 1", []token.TokenType{token.IDENT, token.PLUS, token.INT}},
+		// a backslash anywhere else is illegal
+		{"1 \\ 2", []token.TokenType{token.INT, token.ILLEGAL, token.INT}},
+		{"1 \\", []token.TokenType{token.INT, token.ILLEGAL}},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		for j, expectedType := range append(tt.expected, token.EOF) {
+			tok := l.NextToken()
+			if tok.Type != expectedType {
+				t.Fatalf("tests[%d] - token %d of %q wrong. expected=%q, got=%q", i, j, tt.input, expectedType, tok.Type)
+			}
+		}
+	}
+
+	// the continued line still counts as a line of its own
+	l := New("1 + \\\n2")
+	l.NextToken()
+	l.NextToken()
+	if tok := l.NextToken(); tok.Pos.Line != 2 || tok.Pos.Column != 1 {
+		t.Errorf("wrong position after continuation. got=%d:%d", tok.Pos.Line, tok.Pos.Column)
+	}
+
+	// with trivia the backslash is part of the whitespace
+	l = New("1\\\n2")
+	l.EmitTrivia(true)
+	l.NextToken()
+	if tok := l.NextToken(); tok.Type != token.WHITESPACE || tok.Literal != "\\\n" {
+		t.Errorf("wrong trivia token. got=%q %q", tok.Type, tok.Literal)
+	}
+}
+
+func TestEmitNewlines(t *testing.T) {
+	input := "let x = 1 // one\n\nx \\\n+ 2\r\n"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.NEWLINE, "\n"},
+		{token.NEWLINE, "\n"},
+		{token.IDENT, "x"},
+		// the continued line break is skipped
+		{token.PLUS, "+"},
+		{token.INT, "2"},
+		{token.NEWLINE, "\n"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	l.EmitNewlines(true)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%q %q, got=%q %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestStringSpans(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedType token.TokenType
+		expectedPos  token.Position // of the token after the string
+	}{
+		// escapes, even \n, are on the line they are written on
+		{`"a\nb\"c" x`, token.STRING, token.Position{Offset: 10, Line: 1, Column: 11}},
+		{`"caf\u{e9}" x`, token.STRING, token.Position{Offset: 12, Line: 1, Column: 13}},
+		// a real line break moves to the next line
+		{"`a\nb` x", token.RAW_STRING, token.Position{Offset: 6, Line: 2, Column: 4}},
+		{"\"a\nb\" x", token.STRING, token.Position{Offset: 6, Line: 2, Column: 4}},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		str := l.NextToken()
+		if str.Type != tt.expectedType || str.Pos != (token.Position{Offset: 0, Line: 1, Column: 1}) {
+			t.Fatalf("tests[%d] - wrong string token. got=%q at %+v", i, str.Type, str.Pos)
+		}
+		if next := l.NextToken(); next.Pos != tt.expectedPos {
+			t.Errorf("tests[%d] - wrong position after the string. expected=%+v, got=%+v", i, tt.expectedPos, next.Pos)
+		}
+	}
+}
+
+func TestByteOrderMark(t *testing.T) {
+	input := "let x = 1;\nx"
+	plain, _ := New(input).All()
+	withBOM, _ := New("\xEF\xBB\xBF" + input).All()
+
+	if len(withBOM) != len(plain) {
+		t.Fatalf("wrong number of tokens. expected=%d, got=%d", len(plain), len(withBOM))
+	}
+	for i, tok := range withBOM {
+		expected := plain[i]
+		expected.Pos.Offset += 3
+		if tok != expected {
+			t.Errorf("tests[%d] - wrong token. expected=%+v, got=%+v", i, expected, tok)
+		}
+	}
+
+	// anywhere else the bytes are illegal
+	l := New("x\xEF\xBB\xBF")
+	l.NextToken()
+	for i := 0; i < 3; i++ {
+		if tok := l.NextToken(); tok.Type != token.ILLEGAL {
+			t.Errorf("wrong token for byte %d of the mark. got=%q %q", i, tok.Type, tok.Literal)
+		}
+	}
+	if tok := New("\xEF\xBB").NextToken(); tok.Type != token.ILLEGAL {
+		t.Errorf("part of a mark was skipped. got=%q", tok.Type)
+	}
+}
+
+func TestHeredoc(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedError   string
+		expectedNext    token.Position // of the token after the heredoc
+	}{
+		{"<<<END\nhello\nworld\nEND\nx", token.STRING, "hello\nworld", "", token.Position{Offset: 23, Line: 5, Column: 1}},
+		{"<<<END\nEND", token.STRING, "", "", token.Position{Offset: 10, Line: 2, Column: 4}},
+		{"<<<END\r\nhi\r\nEND\r\n", token.STRING, "hi", "", token.Position{Offset: 17, Line: 4, Column: 1}},
+		// the delimiter only ends the heredoc on a line of its own
+		{"<<<END\nthe END is near\n END\nEND\n", token.STRING, "the END is near\n END", "", token.Position{Offset: 32, Line: 5, Column: 1}},
+		{"<<<END\nno end\n", token.ILLEGAL, "<<<END\nno end\n", "unterminated heredoc, expected a line with END", token.Position{Offset: 14, Line: 3, Column: 1}},
+		{"<<<END", token.ILLEGAL, "<<<END", "unterminated heredoc, expected a line with END", token.Position{Offset: 6, Line: 1, Column: 7}},
+		{"<<<END x\nEND", token.ILLEGAL, "<<<END x\nEND", "heredoc delimiter END must end the line", token.Position{Offset: 12, Line: 2, Column: 4}},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Errorf("tests[%d] - wrong token. expected=%q %q, got=%q %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		if next := l.NextToken(); next.Pos != tt.expectedNext {
+			t.Errorf("tests[%d] - wrong position after the heredoc. expected=%+v, got=%+v", i, tt.expectedNext, next.Pos)
+		}
+		errors := l.Errors()
+		if tt.expectedError == "" && len(errors) != 0 || tt.expectedError != "" && (len(errors) != 1 || errors[0] != tt.expectedError) {
+			t.Errorf("tests[%d] - wrong errors. expected=%q, got=%q", i, tt.expectedError, errors)
+		}
+	}
+
+	// << and < are still operators
+	l := New("a <<< b")
+	for _, expected := range []token.TokenType{token.IDENT, token.SHIFT_LEFT, token.LT, token.IDENT} {
+		if tok := l.NextToken(); tok.Type != expected {
+			t.Errorf("wrong token. expected=%q, got=%q", expected, tok.Type)
+		}
+	}
+}
+
+func TestUnescape(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		err      string
+	}{
+		{`plain`, "plain", ""},
+		{`a\nb\tc\rd`, "a\nb\tc\rd", ""},
+		{`\"quoted\" \\ \$`, `"quoted" \ $`, ""},
+		{`caf\u{e9} \u{1F600}`, "café 😀", ""},
+		{`\q`, "", `unknown escape sequence \q`},
+		{`\u00e9`, "", `\u must be followed by a code point in braces, like \u{e9}`},
+		{`\u{110000}`, "", `invalid code point \u{110000}`},
+		{`\u{}`, "", `invalid code point \u{}`},
+		{`a\`, "", "unterminated escape sequence"},
+	}
+
+	for i, tt := range tests {
+		got, err := Unescape(tt.input)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("tests[%d] - wrong error. expected=%q, got=%v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.expected {
+			t.Errorf("tests[%d] - wrong result. expected=%q, got=%q (%v)", i, tt.expected, got, err)
+		}
+	}
+}
+
+func TestPositionsMatchOffsetToPosition(t *testing.T) {
+	input := "let add = fn(a, b) {\n\ta + b // sum\n};\n\n\"two\nlines\" add(1, 2)\n"
+
+	tokens, _ := New(input).All()
+	for i, tok := range tokens {
+		if expected := token.OffsetToPosition(input, tok.Pos.Offset); tok.Pos != expected {
+			t.Errorf("tokens[%d] - %q at %+v, OffsetToPosition says %+v", i, tok.Literal, tok.Pos, expected)
+		}
+	}
+}
+
+func TestColumnsWithTabsAndMultibyteRunes(t *testing.T) {
+	defer func(width int) { token.TabWidth = width }(token.TabWidth)
+
+	tests := []struct {
+		input string
+		tabWidth int
+		expectedColumn int // of the x at the end
+	}{
+		{"\"äé\"\tx", 1, 6},
+		{"\"äé\"\tx", 4, 9},
+		// a tab inside the string, between a letter and a multibyte rune
+		{"\"a\tü\"\tx", 1, 7},
+		{"\"a\tü\"\tx", 4, 9},
+		{"\t\"日本\"\tx", 1, 7},
+		{"\t\"日本\"\tx", 4, 13},
+		// a run of blanks skipped in one go
+		{"\"é\" \t\t x", 1, 8},
+		{"\"é\" \t\t x", 4, 14},
+		{"// ü\n\t\tx", 4, 9},
+	}
+
+	for i, tt := range tests {
+		token.TabWidth = tt.tabWidth
+		tokens, _ := New(tt.input).All()
+		x := tokens[len(tokens)-2]
+		if x.Literal != "x" || x.Pos.Column != tt.expectedColumn {
+			t.Errorf("tests[%d] - %q at column %d with tab width %d, expected column %d",
+				i, x.Literal, x.Pos.Column, tt.tabWidth, tt.expectedColumn)
+		}
+		if expected := token.OffsetToPosition(tt.input, x.Pos.Offset); x.Pos != expected {
+			t.Errorf("tests[%d] - x at %+v, OffsetToPosition says %+v", i, x.Pos, expected)
+		}
+	}
+}
+
+func TestTokenSpan(t *testing.T) {
+	input := "let max_len = 0x1F + 2.5e3 * 10u;\nif (a < b) { a != b } // done"
+
+	l := New(input)
+	count := 0
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		start, end := tok.Span()
+		if input[start:end] != tok.Literal {
+			t.Errorf("span of %s %q is %d:%d, which is %q", tok.Type, tok.Literal, start, end, input[start:end])
+		}
+		count++
+	}
+	if count != 20 {
+		t.Errorf("wrong number of tokens. got=%d", count)
+	}
+
+	// spans of an embedded lexer are offsets into the enclosing source
+	src := "x = ${a + bc}"
+	l = NewAt(src[7:12], token.Position{Offset: 7, Line: 1, Column: 8})
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if start, end := tok.Span(); src[start:end] != tok.Literal {
+			t.Errorf("span of %q is %d:%d, which is %q", tok.Literal, start, end, src[start:end])
+		}
+	}
+}
+
+func TestOnProgress(t *testing.T) {
+	input := strings.Repeat("let x = [1, 2.5, \"three\"]; // comment\n", 10000)
+
+	var reads []int
+	l := New(input)
+	l.OnProgress = func(bytesRead, total int) {
+		if total != len(input) {
+			t.Errorf("total is %d, want %d", total, len(input))
+		}
+		reads = append(reads, bytesRead)
+	}
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+	}
+	l.NextToken()
+
+	if len(reads) < 2 {
+		t.Fatalf("OnProgress called %d times, want several", len(reads))
+	}
+	for i := 1; i < len(reads); i++ {
+		if reads[i] <= reads[i-1] {
+			t.Errorf("bytes read went from %d to %d", reads[i-1], reads[i])
+		}
+	}
+	if last := reads[len(reads)-1]; last != len(input) {
+		t.Errorf("last bytes read is %d, want %d", last, len(input))
+	}
+}
+
+func TestCheckBalanced(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let f = fn(x) { [x, {\"a\": (1)}] };", []string{}},
+		{"let s = \"(]\"; // {\n`${ \"}\" }` + r\"[\"", []string{}},
+		{"puts((1 + 2)", []string{"1:5: unclosed ("}},
+		{"let x = 1;\n}", []string{"2:1: unexpected }"}},
+		{"(1]", []string{"1:3: unexpected ], want ) to close ( at 1:1"}},
+		{"{ [ ( }\n)", []string{
+			"1:7: unexpected }, want ) to close ( at 1:5",
+			"2:1: unexpected ), want ] to close [ at 1:3",
+			"1:1: unclosed {",
+		}},
+	}
+
+	for _, tt := range tests {
+		diagnostics := CheckBalanced(tt.input)
+
+		if len(diagnostics) != len(tt.expected) {
+			t.Errorf("%q: wrong number of diagnostics. expected=%q, got=%v", tt.input, tt.expected, diagnostics)
+			continue
+		}
+		for i, d := range diagnostics {
+			if d.String() != tt.expected[i] {
+				t.Errorf("%q: diagnostics[%d] wrong. expected=%q, got=%q", tt.input, i, tt.expected[i], d.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/repl"
+	"os"
+)
+
+// main runs the file given as the first argument, or starts the REPL
+// on stdin/stdout when there is none.
+func main() {
+	// report likely mistakes like `if (x = 5)` as warnings
+	parser.Lint = true
+
+	if len(os.Args) > 1 {
+		os.Exit(runFile(os.Args[1]))
+	}
+
+	fmt.Println("This is the Monkey programming language!")
+	fmt.Println("Feel free to type in commands")
+	repl.Start(os.Stdin, os.Stdout, repl.DefaultConfig())
+}
+
+// runFile evaluates the program in path and returns the exit code.
+func runFile(path string) int {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	for _, msg := range p.Warnings() {
+		fmt.Fprintln(os.Stderr, "warning: "+msg)
+	}
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			fmt.Fprintln(os.Stderr, msg)
+		}
+		return 1
+	}
+
+	// readLine() in the program reads from our stdin
+	evaluator.SetInput(os.Stdin)
+	evaluated := evaluator.Eval(program, object.NewEnvironment())
+	if errObj, ok := evaluated.(*object.Error); ok {
+		fmt.Fprintln(os.Stderr, errObj.Inspect())
+		return 1
+	}
+	return 0
+}
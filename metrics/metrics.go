@@ -0,0 +1,45 @@
+// Package metrics measures the size and complexity of programs.
+package metrics
+
+import "monkey/ast"
+
+// Metrics describes a program.
+type Metrics struct {
+	// Statements counts every statement, including those nested in blocks.
+	Statements int
+	// MaxDepth is the deepest nesting of blocks: 0 for a program without
+	// blocks, 1 for a function body or an if branch at the top level, and so on.
+	MaxDepth int
+	// Complexity is the cyclomatic complexity: 1 plus one for every point where
+	// the control flow branches, which is every if and loop and every && and ||.
+	Complexity int
+}
+
+// Compute walks p and returns its metrics.
+func Compute(p *ast.Program) Metrics {
+	m := Metrics{Complexity: 1}
+	m.visit(p, 0)
+	return m
+}
+
+func (m *Metrics) visit(node ast.Node, depth int) {
+	switch n := node.(type) {
+	case *ast.BlockStatement:
+		depth++
+		if depth > m.MaxDepth {
+			m.MaxDepth = depth
+		}
+	case ast.Statement:
+		m.Statements++
+	case *ast.IfExpression, *ast.WhileExpression, *ast.DoWhileExpression, *ast.ForExpression:
+		m.Complexity++
+	case *ast.InfixExpression:
+		if n.Operator == "&&" || n.Operator == "||" {
+			m.Complexity++
+		}
+	}
+
+	for _, child := range ast.Children(node) {
+		m.visit(child, depth)
+	}
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program
+}
+
+func TestCompute(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Metrics
+	}{
+		{"", Metrics{Statements: 0, MaxDepth: 0, Complexity: 1}},
+		{"let x = 1; let y = x + 2; puts(y);", Metrics{Statements: 3, MaxDepth: 0, Complexity: 1}},
+		{
+			`let f = fn(n) {
+				let i = 0;
+				while (i < n) {
+					if (i > 2) {
+						puts(i);
+					} else {
+						if (i == 1) { return i; }
+					}
+					i = i + 1;
+				}
+				i
+			};`,
+			// let f, let i, while, if, puts, if, return, i = i + 1, i
+			Metrics{Statements: 9, MaxDepth: 4, Complexity: 4},
+		},
+		{
+			"if (true) { 1 }; if (false) { 2 } else { 3 }; while (false) { }",
+			Metrics{Statements: 6, MaxDepth: 1, Complexity: 4},
+		},
+		{"for (x in [1, 2]) { puts(x) }", Metrics{Statements: 2, MaxDepth: 1, Complexity: 2}},
+	}
+
+	for i, tt := range tests {
+		if got := Compute(parse(t, tt.input)); got != tt.expected {
+			t.Errorf("tests[%d] - wrong metrics. expected=%+v, got=%+v", i, tt.expected, got)
+		}
+	}
+}
+
+func TestComputeCountsLogicalOperators(t *testing.T) {
+	// a && (b || c), built by hand since it doesn't depend on parsing
+	ident := func(name string) *ast.Identifier {
+		return &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+	}
+	expr := &ast.InfixExpression{
+		Left:     ident("a"),
+		Operator: "&&",
+		Right:    &ast.InfixExpression{Left: ident("b"), Operator: "||", Right: ident("c")},
+	}
+	program := &ast.Program{Statements: []ast.Statement{&ast.ExpressionStatement{Expression: expr}}}
+
+	expected := Metrics{Statements: 1, MaxDepth: 0, Complexity: 3}
+	if got := Compute(program); got != expected {
+		t.Errorf("wrong metrics. expected=%+v, got=%+v", expected, got)
+	}
+}
@@ -0,0 +1,75 @@
+package object
+
+// DeepCopy returns a copy of e that evaluating code in can't change e
+// through: the environments e encloses are copied along with it, and so is
+// every value reachable from their bindings that can change in place, the
+// arrays, hashes and priority queues, and the functions with the
+// environments they close over. A value reached more than once is copied
+// once, so the copies share what the originals share.
+//
+// Builtins are kept as they are. Those closing over values in Go, like the
+// functions compose returns, still work on the originals.
+func (e *Environment) DeepCopy() *Environment {
+	c := &copier{envs: map[*Environment]*Environment{}, objects: map[Object]Object{}}
+	return c.env(e)
+}
+
+// copier makes the copies for DeepCopy and remembers them.
+type copier struct {
+	envs    map[*Environment]*Environment
+	objects map[Object]Object
+}
+
+func (c *copier) env(e *Environment) *Environment {
+	if e == nil {
+		return nil
+	}
+	if copied, ok := c.envs[e]; ok {
+		return copied
+	}
+	env := &Environment{store: make(map[string]Object, len(e.store))}
+	c.envs[e] = env
+	env.outer = c.env(e.outer)
+	for name, val := range e.store {
+		env.store[name] = c.object(val)
+	}
+	return env
+}
+
+func (c *copier) object(obj Object) Object {
+	if copied, ok := c.objects[obj]; ok {
+		return copied
+	}
+
+	switch obj := obj.(type) {
+	case *Array:
+		arr := &Array{Elements: make([]Object, len(obj.Elements))}
+		c.objects[obj] = arr
+		for i, el := range obj.Elements {
+			arr.Elements[i] = c.object(el)
+		}
+		return arr
+	case *Hash:
+		hash := &Hash{Pairs: make(map[HashKey]HashPair, len(obj.Pairs))}
+		c.objects[obj] = hash
+		for key, pair := range obj.Pairs {
+			hash.Pairs[key] = HashPair{Key: pair.Key, Value: c.object(pair.Value)}
+		}
+		return hash
+	case *PriorityQueue:
+		pq := &PriorityQueue{items: make(pqItems, len(obj.items)), seq: obj.seq}
+		c.objects[obj] = pq
+		for i, item := range obj.items {
+			item.value = c.object(item.value)
+			pq.items[i] = item
+		}
+		return pq
+	case *Function:
+		fn := &Function{Parameters: obj.Parameters, Defaults: obj.Defaults, Body: obj.Body}
+		c.objects[obj] = fn
+		fn.Env = c.env(obj.Env)
+		return fn
+	default:
+		return obj
+	}
+}
@@ -0,0 +1,68 @@
+package object
+
+import "math"
+
+// Equals reports whether a and b are the same value. Integers, booleans,
+// strings and null compare by value, arrays and hashes compare their
+// elements with Equals. Anything else, like functions, is only equal to
+// itself.
+func Equals(a, b Object) bool {
+	return equals(a, b, false)
+}
+
+// DeepEqual is like Equals, except that NaN equals NaN, so a structure
+// holding NaN still equals a copy of itself. Other floats compare as they
+// do with ==, and 0.0 equals -0.0.
+func DeepEqual(a, b Object) bool {
+	return equals(a, b, true)
+}
+
+func equals(a, b Object, nanEqual bool) bool {
+	if a == b {
+		return true
+	}
+	if a.Type() != b.Type() {
+		return false
+	}
+
+	switch a := a.(type) {
+	case *Integer:
+		return a.Value == b.(*Integer).Value
+	case *Float:
+		other := b.(*Float).Value
+		if nanEqual && math.IsNaN(a.Value) && math.IsNaN(other) {
+			return true
+		}
+		return a.Value == other
+	case *Boolean:
+		return a.Value == b.(*Boolean).Value
+	case *String:
+		return a.Value == b.(*String).Value
+	case *Null:
+		return true
+	case *Array:
+		other := b.(*Array)
+		if len(a.Elements) != len(other.Elements) {
+			return false
+		}
+		for i, el := range a.Elements {
+			if !equals(el, other.Elements[i], nanEqual) {
+				return false
+			}
+		}
+		return true
+	case *Hash:
+		other := b.(*Hash)
+		if len(a.Pairs) != len(other.Pairs) {
+			return false
+		}
+		for key, pair := range a.Pairs {
+			otherPair, ok := other.Pairs[key]
+			if !ok || !equals(pair.Value, otherPair.Value, nanEqual) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
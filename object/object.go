@@ -0,0 +1,319 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"monkey/ast"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type ObjectType string
+
+const (
+	INTEGER_OBJ      = "INTEGER"
+	BOOLEAN_OBJ      = "BOOLEAN"
+	NULL_OBJ         = "NULL"
+	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
+	ERROR_OBJ        = "ERROR"
+	FUNCTION_OBJ     = "FUNCTION"
+	STRING_OBJ       = "STRING"
+	BUILTIN_OBJ      = "BUILTIN"
+	ARRAY_OBJ        = "ARRAY"
+	HASH_OBJ         = "HASH"
+
+	FLOAT_OBJ          = "FLOAT"
+	RANGE_OBJ          = "RANGE"
+	PRIORITY_QUEUE_OBJ = "PRIORITY_QUEUE"
+)
+
+// Object is the representation of every value the evaluator produces.
+type Object interface {
+	Type() ObjectType
+	Inspect() string
+}
+
+// Integer wraps a 64 bit signed integer.
+type Integer struct {
+	Value int64
+}
+
+func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
+func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
+
+// SmallInts is the range of integers, from SmallInts[0] to SmallInts[1],
+// that NewInteger interns: asking for one of them gives the same object
+// every time instead of allocating a new one, which saves most of the
+// allocations of loops counting up to small numbers. A range with
+// SmallInts[0] above SmallInts[1] interns none.
+var SmallInts = [2]int64{-128, 255}
+
+// smallInts holds the interned integers of the range they were made for.
+var smallInts struct {
+	min, max int64
+	ints     []*Integer
+}
+
+// NewInteger returns the integer object for value, the interned one if value
+// is in the range of SmallInts. Integers are never changed in place, so the
+// evaluator, the compiler and the vm all build them with it.
+func NewInteger(value int64) *Integer {
+	min, max := SmallInts[0], SmallInts[1]
+	if value < min || value > max {
+		return &Integer{Value: value}
+	}
+	if smallInts.ints == nil || smallInts.min != min || smallInts.max != max {
+		smallInts.min, smallInts.max = min, max
+		smallInts.ints = make([]*Integer, max-min+1)
+		for i := range smallInts.ints {
+			smallInts.ints[i] = &Integer{Value: min + int64(i)}
+		}
+	}
+	return smallInts.ints[value-min]
+}
+
+// Float wraps a 64 bit floating point number.
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+
+// Inspect writes the value rounded to 15 significant digits, which hides the
+// rounding error in results like 0.1 + 0.2. Whole numbers are written without
+// a decimal point, so 1.0 is 1, and negative zero is 0. Numbers of 1e15 or
+// more, or less than 1e-4, in magnitude are written with an exponent, as in
+// 1e+15 and 2.5e-05.
+func (f *Float) Inspect() string {
+	if f.Value == 0 {
+		return "0"
+	}
+	return strconv.FormatFloat(f.Value, 'g', 15, 64)
+}
+
+type Boolean struct {
+	Value bool
+}
+
+func (b *Boolean) Type() ObjectType { return BOOLEAN_OBJ }
+func (b *Boolean) Inspect() string  { return fmt.Sprintf("%t", b.Value) }
+
+// Null represents the absence of a value.
+type Null struct{}
+
+func (n *Null) Type() ObjectType { return NULL_OBJ }
+func (n *Null) Inspect() string  { return "null" }
+
+// ReturnValue wraps the value of a return statement so the evaluator
+// can stop evaluating the enclosing statements and unwrap it at the
+// function (or program) boundary.
+type ReturnValue struct {
+	Value Object
+}
+
+func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
+func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
+
+// Break is what a break statement evaluates to. Like ReturnValue it stops
+// the evaluation of the statements, up to the innermost loop, which ends.
+type Break struct{}
+
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+func (b *Break) Inspect() string  { return "break" }
+
+// Continue is what a continue statement evaluates to. It stops the
+// evaluation of the statements up to the innermost loop, which goes on with
+// its next iteration.
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+func (c *Continue) Inspect() string  { return "continue" }
+
+// Error is an internal error produced during evaluation, e.g. a type
+// mismatch. Like ReturnValue it stops the evaluation of the statements.
+type Error struct {
+	Message string
+}
+
+func (e *Error) Type() ObjectType { return ERROR_OBJ }
+func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
+
+// Function is a function literal together with the environment it was
+// defined in, which makes closures possible.
+type Function struct {
+	Parameters []*ast.Identifier
+	Defaults   []ast.Expression // default values, nil where a parameter has none
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
+func (f *Function) Inspect() string {
+	var out bytes.Buffer
+	params := []string{}
+	for i, p := range f.Parameters {
+		if i < len(f.Defaults) && f.Defaults[i] != nil {
+			params = append(params, p.String()+" = "+f.Defaults[i].String())
+		} else {
+			params = append(params, p.String())
+		}
+	}
+	out.WriteString("fn(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(f.Body.String())
+	out.WriteString("\n}")
+	return out.String()
+}
+
+type String struct {
+	Value string
+
+	length int // number of characters plus one, 0 until Len has counted them
+}
+
+func (s *String) Type() ObjectType { return STRING_OBJ }
+func (s *String) Inspect() string  { return s.Value }
+
+// Len returns the number of characters of the string. Counting them means
+// going through the whole string, so the count is remembered for the next
+// time. That is safe because the value of a String never changes.
+func (s *String) Len() int {
+	if s.length == 0 {
+		s.length = utf8.RuneCountInString(s.Value) + 1
+	}
+	return s.length - 1
+}
+
+// BuiltinFunction is the signature of functions implemented in Go
+// and made available to Monkey programs.
+type BuiltinFunction func(args ...Object) Object
+
+// Builtin wraps a BuiltinFunction so it can be bound to a name like any other value.
+type Builtin struct {
+	Fn BuiltinFunction
+}
+
+func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
+func (b *Builtin) Inspect() string  { return "builtin function" }
+
+// Array is an ordered list of objects. Builtins never modify an array in
+// place, they return a new one. Only index assignment, `a[i] = x`, does.
+type Array struct {
+	Elements []Object
+
+	// used is set for arrays made by Append, which may share the storage
+	// behind Elements with other arrays. It is shared by all of them and
+	// holds the length of the longest one: the storage past that length
+	// isn't part of any array yet.
+	used *int
+}
+
+// Append returns a new array with el added to the end of ao, ao itself is
+// left untouched. The new array can take over the spare capacity behind
+// ao's elements, so building an array by appending to the latest version
+// again and again costs amortized constant time per element. Appending to
+// an older version, which another array has already grown past, copies.
+func (ao *Array) Append(el Object) *Array {
+	n := len(ao.Elements)
+	if ao.used != nil && *ao.used == n && n < cap(ao.Elements) {
+		*ao.used = n + 1
+		return &Array{Elements: append(ao.Elements, el), used: ao.used}
+	}
+
+	elements := make([]Object, n+1, 2*n+4)
+	copy(elements, ao.Elements)
+	elements[n] = el
+	used := n + 1
+	return &Array{Elements: elements, used: &used}
+}
+
+// Set replaces the element at index i, which must be in range. Arrays made by
+// Append may share their storage with other arrays, so such an array is
+// given storage of its own first, the others don't see the change.
+func (ao *Array) Set(i int, el Object) {
+	if ao.used != nil {
+		elements := make([]Object, len(ao.Elements))
+		copy(elements, ao.Elements)
+		ao.Elements = elements
+		ao.used = nil
+	}
+	ao.Elements[i] = el
+}
+
+func (ao *Array) Type() ObjectType { return ARRAY_OBJ }
+func (ao *Array) Inspect() string {
+	var out bytes.Buffer
+	elements := []string{}
+	for _, e := range ao.Elements {
+		elements = append(elements, e.Inspect())
+	}
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+	return out.String()
+}
+
+// HashKey identifies a hash key by type and value, so two different
+// String objects with the same contents find the same entry.
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+// Hashable is implemented by the objects that can be used as hash keys.
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	}
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
+// HashPair keeps the original key next to the value, since the HashKey
+// alone can't be turned back into an object.
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+type Hash struct {
+	Pairs map[HashKey]HashPair
+}
+
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+
+// Inspect prints the pairs sorted by key, so the output doesn't depend on
+// the iteration order of the map.
+func (h *Hash) Inspect() string {
+	var out bytes.Buffer
+	pairs := []string{}
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
+	}
+	sort.Strings(pairs)
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+	return out.String()
+}
@@ -0,0 +1,63 @@
+package object
+
+import (
+	"container/heap"
+	"fmt"
+)
+
+// PriorityQueue hands out its values lowest priority first. Values pushed
+// with the same priority come out in the order they were pushed.
+type PriorityQueue struct {
+	items pqItems
+	seq   int // number of pushes so far, breaks ties between equal priorities
+}
+
+func (pq *PriorityQueue) Type() ObjectType { return PRIORITY_QUEUE_OBJ }
+func (pq *PriorityQueue) Inspect() string {
+	return fmt.Sprintf("priority queue (%d items)", len(pq.items))
+}
+
+// Len returns the number of values in the queue.
+func (pq *PriorityQueue) Len() int { return len(pq.items) }
+
+// Push adds value to the queue with the given priority.
+func (pq *PriorityQueue) Push(priority int64, value Object) {
+	heap.Push(&pq.items, pqItem{priority: priority, seq: pq.seq, value: value})
+	pq.seq++
+}
+
+// Pop removes and returns the value with the lowest priority. It reports
+// false when the queue is empty.
+func (pq *PriorityQueue) Pop() (Object, bool) {
+	if len(pq.items) == 0 {
+		return nil, false
+	}
+	item := heap.Pop(&pq.items).(pqItem)
+	return item.value, true
+}
+
+type pqItem struct {
+	priority int64
+	seq      int
+	value    Object
+}
+
+// pqItems is a min-heap of items implementing heap.Interface.
+type pqItems []pqItem
+
+func (h pqItems) Len() int { return len(h) }
+func (h pqItems) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority < h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+func (h pqItems) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *pqItems) Push(x interface{}) { *h = append(*h, x.(pqItem)) }
+func (h *pqItems) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
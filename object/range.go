@@ -0,0 +1,70 @@
+package object
+
+import (
+	"fmt"
+	"math"
+)
+
+// Range is the sequence of numbers from Start up to, but not including, Stop,
+// Step apart. Step may be negative to count down. The numbers are computed
+// as they are asked for, so a range of a million numbers takes no more
+// memory than a range of ten.
+//
+// A range is of floats if any of Start, Stop or Step is a *Float, otherwise
+// they are all *Integer and so are its elements.
+type Range struct {
+	Start, Stop, Step Object
+}
+
+func (r *Range) Type() ObjectType { return RANGE_OBJ }
+func (r *Range) Inspect() string {
+	return fmt.Sprintf("range(%s, %s, %s)", r.Start.Inspect(), r.Stop.Inspect(), r.Step.Inspect())
+}
+
+func (r *Range) isFloat() bool {
+	for _, o := range []Object{r.Start, r.Stop, r.Step} {
+		if _, ok := o.(*Float); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of elements.
+func (r *Range) Len() int64 {
+	if r.isFloat() {
+		start, stop, step := toFloat(r.Start), toFloat(r.Stop), toFloat(r.Step)
+		n := math.Ceil((stop - start) / step)
+		if n <= 0 || math.IsNaN(n) {
+			return 0
+		}
+		return int64(n)
+	}
+
+	start, stop, step := r.Start.(*Integer).Value, r.Stop.(*Integer).Value, r.Step.(*Integer).Value
+	switch {
+	case step > 0 && start < stop:
+		return (stop-start-1)/step + 1
+	case step < 0 && start > stop:
+		return (start-stop-1)/-step + 1
+	default:
+		return 0
+	}
+}
+
+// At returns the element at index i, which must be less than Len. Float
+// elements are computed as Start + i*Step rather than by adding up steps,
+// so rounding errors don't accumulate.
+func (r *Range) At(i int64) Object {
+	if r.isFloat() {
+		return &Float{Value: toFloat(r.Start) + float64(i)*toFloat(r.Step)}
+	}
+	return NewInteger(r.Start.(*Integer).Value + i*r.Step.(*Integer).Value)
+}
+
+func toFloat(o Object) float64 {
+	if i, ok := o.(*Integer); ok {
+		return float64(i.Value)
+	}
+	return o.(*Float).Value
+}
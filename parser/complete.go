@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"monkey/lexer"
+	"monkey/token"
+	"strings"
+)
+
+var closers = map[token.TokenType]token.TokenType{
+	token.LPAREN:   token.RPAREN,
+	token.LBRACE:   token.RBRACE,
+	token.LBRACKET: token.RBRACKET,
+}
+
+// IsComplete reports whether src could be parsed as it is, or whether it stops
+// in the middle of something more input would finish: inside an open paren,
+// brace or bracket, or inside a string or heredoc. The REPL uses it to keep reading lines
+// of a multiline function before evaluating them.
+//
+// Complete input isn't necessarily valid. Input with a closing bracket that
+// doesn't match is complete, since no further input can fix it.
+func IsComplete(src string) bool {
+	tokens, _ := lexer.New(src).All()
+
+	open := []token.TokenType{}
+	for _, tok := range tokens {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			open = append(open, closers[tok.Type])
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			if len(open) == 0 || open[len(open)-1] != tok.Type {
+				return true
+			}
+			open = open[:len(open)-1]
+		case token.STRING, token.TEMPLATE, token.RAW_STRING:
+			// a terminated string is followed by its closing quote
+			if tok.Pos.Offset+1+len(tok.Literal) >= len(src) {
+				return false
+			}
+		case token.ILLEGAL:
+			if isUnterminatedHeredoc(tok.Literal) {
+				return false
+			}
+		}
+	}
+	return len(open) == 0
+}
+
+// isUnterminatedHeredoc reports whether the ILLEGAL token literal is a heredoc
+// missing its closing delimiter line, rather than one with something after
+// the delimiter on its first line.
+func isUnterminatedHeredoc(literal string) bool {
+	if !strings.HasPrefix(literal, "<<<") {
+		return false
+	}
+	first, _, _ := strings.Cut(literal[3:], "\n")
+	return !strings.ContainsFunc(strings.TrimSuffix(first, "\r"), func(r rune) bool {
+		return !(r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z')
+	})
+}
@@ -1 +1,1456 @@
 package parser
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/token"
+	"strconv"
+	"strings"
+)
+
+// operator precedences, from lowest to highest
+const (
+	_ int = iota
+	LOWEST
+	ASSIGN      // x = y
+	PIPE        // x |> f
+	EQUALS      // ==
+	LESSGREATER // > or <
+	SUM         // +
+	PRODUCT     // *
+	PREFIX      // -X or !X
+	CALL        // myFunction(X)
+	INDEX       // array[index]
+)
+
+// precedences maps an infix operator token to its binding power.
+var precedences = map[token.TokenType]int{
+	token.ASSIGN:         ASSIGN,
+	token.PIPELINE:       PIPE,
+	token.EQ:             EQUALS,
+	token.NOT_EQ:         EQUALS,
+	token.LT:             LESSGREATER,
+	token.GT:             LESSGREATER,
+	token.PLUS:           SUM,
+	token.MINUS:          SUM,
+	token.SLASH:          PRODUCT,
+	token.ASTERISK:       PRODUCT,
+	token.PERCENT:        PRODUCT,
+	token.LPAREN:         CALL,
+	token.LBRACKET:       INDEX,
+	token.DOT:            INDEX,
+	token.OPTIONAL_CHAIN: INDEX,
+
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+	token.PERCENT_ASSIGN:  ASSIGN,
+}
+
+// Lint turns on extra checks for code that parses fine but is probably a
+// mistake, like `if (x = 5)`. Their findings are reported by Warnings and
+// never change the parse result.
+var Lint = false
+
+// NewlineTerminates makes a line break end a statement like a semicolon
+// does, so `x\n-1` is two statements rather than `x - 1`. Line breaks inside
+// parentheses and brackets don't count, one can break a long argument list
+// or array there.
+var NewlineTerminates = false
+
+// IdentifierKeys makes a bare identifier in the key position of a hash
+// literal a string key, so `{name: "Sam"}` is `{"name": "Sam"}`. A variable
+// can still be used as a key by wrapping it in parentheses, as in
+// `{(name): "Sam"}`.
+var IdentifierKeys = false
+
+// PreserveTrivia makes the parser keep the whitespace and comments of the
+// source in the Trivia of the statements and the Trailing of the program, so
+// the program's Source reproduces the source exactly. A formatter keeping
+// comments needs that.
+var PreserveTrivia = false
+
+// CommentStatements makes the parser keep the comments between statements
+// as CommentStatement nodes, in the statement lists of the program and the
+// blocks, instead of dropping them. A comment ending the line of a statement
+// is one too, following the statement. Comments inside a statement, like one
+// between two arguments, are still dropped, or kept as trivia with
+// PreserveTrivia.
+var CommentStatements = false
+
+// PreserveParens makes the parser keep the parentheses around expressions
+// as GroupedExpression nodes, so a formatter can write them back even where
+// they don't change the precedence. Without it they only shape the tree.
+var PreserveParens = false
+
+type (
+	prefixParseFn func() ast.Expression
+	infixParseFn  func(ast.Expression) ast.Expression // argument is the left side of the operator
+)
+
+// Parser is a Pratt (top down operator precedence) parser. It pulls tokens
+// from the lexer and builds the AST, collecting errors as it goes instead of
+// stopping at the first one.
+type Parser struct {
+	l        *lexer.Lexer
+	errors   []string
+	warnings []string
+
+	curToken  token.Token // token under examination
+	peekToken token.Token // token after curToken, used to decide what to do next
+
+	prefixParseFns map[token.TokenType]prefixParseFn
+	infixParseFns  map[token.TokenType]infixParseFn
+
+	blocks     int  // how many blocks are open around curToken
+	loops      int  // how many loop bodies are open around curToken, within the innermost function
+	strayBrace bool // an expression was expected but a } was found
+
+	// for NewlineTerminates
+	newlineAhead bool              // a line break comes before peekToken
+	open         []token.TokenType // the brackets open around peekToken, innermost last
+
+	// for PreserveTrivia
+	leading []token.Token // the whitespace and comments before curToken
+	trivia  []token.Token // the whitespace and comments before peekToken
+}
+
+// New creates a Parser reading from the given lexer and registers the
+// parse functions for every token type that can start or continue an expression.
+func New(l *lexer.Lexer) *Parser {
+	p := &Parser{
+		l:        l,
+		errors:   []string{},
+		warnings: []string{},
+	}
+
+	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
+	p.registerPrefix(token.IDENT, p.parseIdentifier)
+	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
+	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.TEMPLATE, p.parseTemplateLiteral)
+	p.registerPrefix(token.RAW_STRING, p.parseRawStringLiteral)
+	p.registerPrefix(token.BANG, p.parsePrefixExpression)
+	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.PLUS, p.parsePrefixExpression)
+	p.registerPrefix(token.TRUE, p.parseBoolean)
+	p.registerPrefix(token.FALSE, p.parseBoolean)
+	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
+	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.WHILE, p.parseWhileExpression)
+	p.registerPrefix(token.DO, p.parseDoWhileExpression)
+	p.registerPrefix(token.FOR, p.parseForExpression)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
+	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.LBRACE, p.parseBraceExpression)
+	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+	p.registerPrefix(token.ELLIPSIS, p.parseSpreadElement)
+	p.registerPrefix(token.UNDERSCORE, p.parsePlaceholder)
+
+	if NewlineTerminates {
+		l.EmitNewlines(true)
+	}
+	if PreserveTrivia || CommentStatements {
+		l.EmitTrivia(true)
+	}
+
+	p.infixParseFns = make(map[token.TokenType]infixParseFn)
+	for _, tt := range []token.TokenType{
+		token.PLUS, token.MINUS, token.SLASH, token.ASTERISK, token.PERCENT,
+		token.EQ, token.NOT_EQ, token.LT, token.GT,
+	} {
+		p.registerInfix(tt, p.parseInfixExpression)
+	}
+	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseDotExpression)
+	p.registerInfix(token.OPTIONAL_CHAIN, p.parseOptionalIndexExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PIPELINE, p.parsePipeExpression)
+	for tt := range compoundOperators {
+		p.registerInfix(tt, p.parseAssignExpression)
+	}
+
+	// read two tokens, so curToken and peekToken are both set
+	p.nextToken()
+	p.nextToken()
+
+	return p
+}
+
+// Errors returns the errors encountered while parsing.
+func (p *Parser) Errors() []string {
+	return p.errors
+}
+
+// Warnings returns the findings of the Lint checks.
+func (p *Parser) Warnings() []string {
+	return p.warnings
+}
+
+// ParseProgram parses the whole input and returns the root node of the AST.
+// The end of the input ends the last statement, so it doesn't need a
+// semicolon, and neither does a statement at the end of its line. Two
+// statements on the same line need one between them: `let x = 5 let y = 6`
+// is an error, see checkTerminator.
+func (p *Parser) ParseProgram() *ast.Program {
+	program := &ast.Program{}
+	program.Statements = []ast.Statement{}
+
+	program.Statements = p.takeComments(program.Statements)
+	for !p.curTokenIs(token.EOF) {
+		errors := len(p.errors)
+		stmt, trivia := p.parseStatementWithTrivia()
+		if stmt != nil {
+			program.Statements = append(program.Statements, stmt)
+		}
+		if len(p.errors) > errors {
+			p.synchronize()
+		} else {
+			p.checkTerminator()
+		}
+		p.nextToken()
+		program.Statements = p.takeComments(program.Statements)
+		p.takeTrailing(trivia)
+	}
+	program.End = p.curToken.Pos.Offset
+	program.Trailing = p.leading
+	return program
+}
+
+// checkTerminator records an error when the statement ending at curToken
+// is followed by another one on the same line with no semicolon between
+// them. The end of the input, the } closing a block and the end of the line
+// end a statement as well, and so does a } ending the statement itself, as
+// in `if (a) { return a } return b`. NewlineTerminates has its own rules for where
+// statements end, so nothing is checked then.
+func (p *Parser) checkTerminator() {
+	if NewlineTerminates || p.curTokenIs(token.SEMICOLON) || p.curTokenIs(token.RBRACE) {
+		return
+	}
+	switch p.peekToken.Type {
+	case token.EOF, token.RBRACE, token.SEMICOLON:
+		return
+	}
+	if p.peekToken.Pos.Line != p.curToken.Pos.Line {
+		return
+	}
+	msg := fmt.Sprintf("expected ; between statements on the same line, got %s instead", p.peekToken.Type)
+	p.errors = append(p.errors, msg)
+}
+
+// synchronize skips the rest of a statement that had an error, so its
+// leftovers aren't parsed as statements of their own and reported again.
+// It stops on the semicolon ending the statement, or before a let or return
+// starting a new one, or before the } closing the enclosing block, if any.
+// Brackets opened on the way are skipped as a whole.
+func (p *Parser) synchronize() {
+	depth := 0
+	for !p.curTokenIs(token.EOF) {
+		switch p.curToken.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			if depth > 0 {
+				depth--
+			}
+		case token.SEMICOLON:
+			if depth == 0 {
+				return
+			}
+		}
+		if depth == 0 {
+			switch p.peekToken.Type {
+			case token.LET, token.RETURN, token.EOF:
+				return
+			case token.RBRACE:
+				if p.blocks > 0 {
+					return
+				}
+			}
+		}
+		p.nextToken()
+	}
+}
+
+func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
+	p.prefixParseFns[tokenType] = fn
+}
+
+func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
+	p.infixParseFns[tokenType] = fn
+}
+
+// nextToken advances both curToken and peekToken by one. NEWLINE tokens are
+// skipped, newlineAhead records whether there was one before peekToken.
+func (p *Parser) nextToken() {
+	p.curToken = p.peekToken
+	p.leading, p.trivia = p.trivia, nil
+	p.peekToken = p.l.NextToken()
+
+	p.newlineAhead = false
+	for isTrivia(p.peekToken.Type) {
+		if p.peekToken.Type == token.NEWLINE {
+			p.newlineAhead = true
+		}
+		p.trivia = append(p.trivia, p.peekToken)
+		p.peekToken = p.l.NextToken()
+	}
+
+	switch p.curToken.Type {
+	case token.LPAREN, token.LBRACKET, token.LBRACE:
+		p.open = append(p.open, p.curToken.Type)
+	case token.RPAREN, token.RBRACKET, token.RBRACE:
+		if len(p.open) > 0 {
+			p.open = p.open[:len(p.open)-1]
+		}
+	}
+}
+
+func isTrivia(t token.TokenType) bool {
+	return t == token.NEWLINE || t == token.WHITESPACE || t == token.COMMENT
+}
+
+// parseStatementWithTrivia is parseStatement recording the trivia of the
+// statement with PreserveTrivia, which it returns as well. The trailing
+// trivia is only known once the next token has been read, see takeTrailing.
+func (p *Parser) parseStatementWithTrivia() (ast.Statement, *ast.Trivia) {
+	if !PreserveTrivia {
+		return p.parseStatement(), nil
+	}
+	leading := p.leading
+	start := p.curToken.Pos.Offset
+	stmt := p.parseStatement()
+
+	end := p.peekToken.Pos.Offset
+	if len(p.trivia) > 0 {
+		end = p.trivia[0].Pos.Offset
+	}
+	trivia := &ast.Trivia{Leading: leading, Source: p.l.Text(start, end)}
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		if stmt != nil {
+			stmt.Trivia = trivia
+		}
+	case *ast.ReturnStatement:
+		if stmt != nil {
+			stmt.Trivia = trivia
+		}
+	case *ast.ExpressionStatement:
+		if stmt != nil {
+			stmt.Trivia = trivia
+		}
+	case *ast.BreakStatement:
+		stmt.Trivia = trivia
+	case *ast.ContinueStatement:
+		stmt.Trivia = trivia
+	}
+	return stmt, trivia
+}
+
+// takeTrailing moves the trivia before curToken that is still on the last
+// line of the statement with the given trivia, spaces and a comment, over to
+// its trailing trivia.
+func (p *Parser) takeTrailing(trivia *ast.Trivia) {
+	if trivia == nil {
+		return
+	}
+	n := 0
+	for n < len(p.leading) {
+		tok := p.leading[n]
+		if tok.Type == token.NEWLINE || strings.Contains(tok.Literal, "\n") {
+			break
+		}
+		n++
+		if tok.Type == token.COMMENT {
+			break
+		}
+	}
+	trivia.Trailing, p.leading = p.leading[:n], p.leading[n:]
+}
+
+// takeComments turns the comments before curToken into CommentStatements
+// appended to stmts, with CommentStatements. With PreserveTrivia the
+// whitespace before each comment becomes its leading trivia, the whitespace
+// after the last one stays before curToken.
+func (p *Parser) takeComments(stmts []ast.Statement) []ast.Statement {
+	if !CommentStatements {
+		return stmts
+	}
+	start := 0
+	for i, tok := range p.leading {
+		if tok.Type != token.COMMENT {
+			continue
+		}
+		stmt := &ast.CommentStatement{Token: tok}
+		if PreserveTrivia {
+			stmt.Trivia = &ast.Trivia{Leading: p.leading[start:i], Source: tok.Literal}
+		}
+		stmts = append(stmts, stmt)
+		start = i + 1
+	}
+	p.leading = p.leading[start:]
+	return stmts
+}
+
+// peekOnNewLine reports whether a line break ends the statement before
+// peekToken. Only line breaks outside parentheses and brackets do.
+func (p *Parser) peekOnNewLine() bool {
+	if !p.newlineAhead {
+		return false
+	}
+	return len(p.open) == 0 || p.open[len(p.open)-1] == token.LBRACE
+}
+
+func (p *Parser) curTokenIs(t token.TokenType) bool {
+	return p.curToken.Type == t
+}
+
+func (p *Parser) peekTokenIs(t token.TokenType) bool {
+	return p.peekToken.Type == t
+}
+
+// expectPeek advances only if the next token has the expected type,
+// otherwise it records an error and leaves the tokens untouched.
+func (p *Parser) expectPeek(t token.TokenType) bool {
+	if p.peekTokenIs(t) {
+		p.nextToken()
+		return true
+	}
+	p.peekError(t)
+	return false
+}
+
+// expectIdent is expectPeek(token.IDENT) for a name being bound, with a
+// clearer error when the name is a keyword, as in `let fn = 1`.
+func (p *Parser) expectIdent() bool {
+	if isKeyword(p.peekToken) {
+		p.keywordError(p.peekToken)
+		return false
+	}
+	return p.expectPeek(token.IDENT)
+}
+
+func isKeyword(tok token.Token) bool {
+	return tok.Type != token.IDENT && token.LookupIdent(tok.Literal) == tok.Type
+}
+
+func (p *Parser) keywordError(tok token.Token) {
+	p.errors = append(p.errors, fmt.Sprintf("cannot use keyword '%s' as identifier", tok.Literal))
+}
+
+func (p *Parser) peekError(t token.TokenType) {
+	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
+	p.errors = append(p.errors, msg)
+}
+
+func (p *Parser) noPrefixParseFnError(t token.TokenType) {
+	msg := fmt.Sprintf("no prefix parse function for %s found", t)
+	p.errors = append(p.errors, msg)
+}
+
+func (p *Parser) peekPrecedence() int {
+	if p, ok := precedences[p.peekToken.Type]; ok {
+		return p
+	}
+	return LOWEST
+}
+
+func (p *Parser) curPrecedence() int {
+	if p, ok := precedences[p.curToken.Type]; ok {
+		return p
+	}
+	return LOWEST
+}
+
+// parseStatement dispatches on the current token to the matching statement parser.
+//
+// It returns an untyped nil when the statement can't be parsed, never a nil
+// *ast.LetStatement or the like, so callers can compare the result to nil.
+func (p *Parser) parseStatement() ast.Statement {
+	switch p.curToken.Type {
+	case token.LET:
+		if stmt := p.parseLetStatement(); stmt != nil {
+			return stmt
+		}
+	case token.RETURN:
+		if stmt := p.parseReturnStatement(); stmt != nil {
+			return stmt
+		}
+	case token.BREAK, token.CONTINUE:
+		return p.parseLoopControl()
+	default:
+		if stmt := p.parseExpressionStatement(); stmt != nil {
+			return stmt
+		}
+	}
+	return nil
+}
+
+// parseLetStatement parses `let <identifier> = <expression>;`, or with a
+// destructuring pattern in place of the identifier, `let [a, b] = <expression>;`.
+func (p *Parser) parseLetStatement() *ast.LetStatement {
+	stmt := &ast.LetStatement{Token: p.curToken}
+
+	if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		stmt.Pattern = p.parsePattern()
+		if stmt.Pattern == nil {
+			return nil
+		}
+	} else {
+		if !p.expectIdent() {
+			return nil
+		}
+		stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	if !p.expectPeek(token.ASSIGN) {
+		return nil
+	}
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+// parsePattern parses the destructuring pattern starting at the current
+// token: an identifier, `[<pattern>, ...]` or `{<key>, <key>: <pattern>, ...}`.
+func (p *Parser) parsePattern() ast.Expression {
+	switch p.curToken.Type {
+	case token.IDENT:
+		return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	case token.LBRACKET:
+		pattern := &ast.ArrayPattern{Token: p.curToken}
+		for !p.peekTokenIs(token.RBRACKET) {
+			p.nextToken()
+			el := p.parsePattern()
+			if el == nil {
+				return nil
+			}
+			pattern.Elements = append(pattern.Elements, el)
+			if !p.peekTokenIs(token.RBRACKET) && !p.expectPeek(token.COMMA) {
+				return nil
+			}
+		}
+		p.nextToken()
+		pattern.Rbracket = p.curToken.Pos
+		return pattern
+
+	case token.LBRACE:
+		pattern := &ast.HashPattern{Token: p.curToken}
+		for !p.peekTokenIs(token.RBRACE) {
+			if !p.expectIdent() {
+				return nil
+			}
+			key := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			var value ast.Expression = key
+			if p.peekTokenIs(token.COLON) {
+				p.nextToken()
+				p.nextToken()
+				if value = p.parsePattern(); value == nil {
+					return nil
+				}
+			}
+			pattern.Keys = append(pattern.Keys, key)
+			pattern.Values = append(pattern.Values, value)
+			if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+				return nil
+			}
+		}
+		p.nextToken()
+		pattern.Rbrace = p.curToken.Pos
+		return pattern
+
+	default:
+		if isKeyword(p.curToken) {
+			p.keywordError(p.curToken)
+			return nil
+		}
+		p.errors = append(p.errors, fmt.Sprintf("expected a name or a pattern to bind, got %s instead", p.curToken.Type))
+		return nil
+	}
+}
+
+// parseReturnStatement parses `return <expression>;`
+func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
+	stmt := &ast.ReturnStatement{Token: p.curToken}
+
+	p.nextToken()
+
+	stmt.ReturnValue = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+// parseLoopControl parses `break;` and `continue;`, which are only allowed
+// in the body of a loop. A function in the body is not part of the loop.
+func (p *Parser) parseLoopControl() ast.Statement {
+	tok := p.curToken
+	if p.loops == 0 {
+		p.errors = append(p.errors, fmt.Sprintf("%s outside of a loop", tok.Literal))
+		return nil
+	}
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	if tok.Type == token.BREAK {
+		return &ast.BreakStatement{Token: tok}
+	}
+	return &ast.ContinueStatement{Token: tok}
+}
+
+// parseLoopBody parses the body of a loop, where break and continue are allowed.
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loops++
+	defer func() { p.loops-- }()
+	return p.parseBlockStatement()
+}
+
+// parseExpressionStatement parses an expression standing on its own.
+// The trailing semicolon is optional so `5 + 5` works in the REPL.
+func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
+	stmt := &ast.ExpressionStatement{Token: p.curToken}
+
+	stmt.Expression = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+// parseExpression is the heart of the Pratt parser. It parses a prefix
+// expression for the current token and then keeps folding infix operators
+// into it for as long as they bind tighter than the given precedence.
+func (p *Parser) parseExpression(precedence int) ast.Expression {
+	prefix := p.prefixParseFns[p.curToken.Type]
+	if prefix == nil {
+		p.noPrefixParseFnError(p.curToken.Type)
+		p.strayBrace = p.curTokenIs(token.RBRACE)
+		return nil
+	}
+	errors := len(p.errors)
+	leftExp := prefix()
+
+	// after an error the rest of the expression is left to synchronize
+	for len(p.errors) == errors && !p.peekTokenIs(token.SEMICOLON) && !p.peekOnNewLine() && precedence < p.peekPrecedence() {
+		infix := p.infixParseFns[p.peekToken.Type]
+		if infix == nil {
+			return leftExp
+		}
+		p.nextToken()
+		leftExp = infix(leftExp)
+	}
+	return leftExp
+}
+
+func (p *Parser) parseIdentifier() ast.Expression {
+	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if p.peekTokenIs(token.TEMPLATE) && p.peekToken.Pos.Offset == ident.Pos().Offset+len(ident.Value) {
+		return p.parseTaggedTemplate(ident)
+	}
+	return ident
+}
+
+// parseTaggedTemplate parses a template string written right after an
+// identifier, `tag"Hello ${name}"`. It is a call of the identifier with the
+// literal parts and the interpolated values: `tag(["Hello ", ""], [name])`.
+func (p *Parser) parseTaggedTemplate(tag *ast.Identifier) ast.Expression {
+	p.nextToken()
+	template, ok := p.parseTemplateLiteral().(*ast.TemplateLiteral)
+	if !ok {
+		return nil
+	}
+
+	parts := &ast.ArrayLiteral{Token: template.Token, Elements: []ast.Expression{}}
+	for _, part := range template.Parts {
+		tok := token.Token{Type: token.STRING, Literal: part, Pos: template.Token.Pos}
+		parts.Elements = append(parts.Elements, &ast.StringLiteral{Token: tok, Value: part})
+	}
+	values := &ast.ArrayLiteral{Token: template.Token, Elements: template.Values}
+
+	return &ast.CallExpression{
+		Token:     template.Token,
+		Function:  tag,
+		Arguments: []ast.Expression{parts, values},
+	}
+}
+
+// parseIntegerLiteral parses an integer in any of the bases the lexer reads.
+// Leading zeros don't change the base, 007 is the decimal 7 and 010 is 10:
+// octal numbers are written with 0o.
+func (p *Parser) parseIntegerLiteral() ast.Expression {
+	lit := &ast.IntegerLiteral{Token: p.curToken}
+
+	digits := p.curToken.Literal
+	if len(digits) > 1 && digits[0] == '0' && (isDecimalDigit(digits[1]) || digits[1] == '_') {
+		digits = strings.TrimLeft(digits, "0_")
+		if digits == "" {
+			digits = "0"
+		}
+	}
+	value, err := strconv.ParseInt(digits, 0, 64)
+	if err != nil {
+		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+	lit.Value = value
+	return lit
+}
+
+func isDecimalDigit(ch byte) bool {
+	return '0' <= ch && ch <= '9'
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		msg := fmt.Sprintf("could not parse %q as float", p.curToken.Literal)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+	lit.Value = value
+	return lit
+}
+
+func (p *Parser) parseStringLiteral() ast.Expression {
+	value, ok := p.unescape(p.curToken.Literal)
+	if !ok {
+		return nil
+	}
+	return &ast.StringLiteral{Token: p.curToken, Value: value}
+}
+
+func (p *Parser) parseRawStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+}
+
+// unescape replaces the escape sequences in the text of a string, see
+// lexer.Unescape. It records an error if there is an invalid one.
+func (p *Parser) unescape(text string) (string, bool) {
+	value, err := lexer.Unescape(text)
+	if err != nil {
+		p.errors = append(p.errors, fmt.Sprintf("invalid string %q: %s", text, err))
+		return "", false
+	}
+	return value, true
+}
+
+// parseTemplateLiteral splits a template string into its literal parts and
+// the expressions in its ${...} interpolations. Each expression is parsed by
+// a parser of its own, reading from a lexer that reports positions in the
+// enclosing source.
+func (p *Parser) parseTemplateLiteral() ast.Expression {
+	lit := &ast.TemplateLiteral{Token: p.curToken}
+	raw := p.curToken.Literal
+
+	// the contents start right after the opening quote
+	start := p.curToken.Pos
+	start.Offset++
+	start.Column++
+
+	last := 0
+	for i := 0; i < len(raw); i++ {
+		if raw[i] == '\\' {
+			i++ // an escaped $ doesn't start an interpolation
+			continue
+		}
+		if raw[i] != '$' || i+1 >= len(raw) || raw[i+1] != '{' {
+			continue
+		}
+		part, ok := p.unescape(raw[last:i])
+		if !ok {
+			return nil
+		}
+		lit.Parts = append(lit.Parts, part)
+
+		exprStart := advancePosition(start, raw[:i+2])
+		value, length := p.parseInterpolation(raw[i+2:], exprStart)
+		if value == nil {
+			return nil
+		}
+		lit.Values = append(lit.Values, value)
+
+		i += 2 + length // the closing }
+		last = i + 1
+	}
+	part, ok := p.unescape(raw[last:])
+	if !ok {
+		return nil
+	}
+	lit.Parts = append(lit.Parts, part)
+	return lit
+}
+
+// parseInterpolation parses the expression at the start of src, which has to
+// be followed by the } closing the interpolation. It returns the expression
+// and the length of the source it took up, not counting the }.
+func (p *Parser) parseInterpolation(src string, start token.Position) (ast.Expression, int) {
+	sub := New(lexer.NewAt(src, start))
+	if sub.curTokenIs(token.RBRACE) {
+		p.errors = append(p.errors, "empty interpolation ${} in string")
+		return nil, 0
+	}
+
+	value := sub.parseExpression(LOWEST)
+	ok := sub.expectPeek(token.RBRACE)
+	p.errors = append(p.errors, sub.errors...)
+	p.warnings = append(p.warnings, sub.warnings...)
+	if !ok || value == nil {
+		return nil, 0
+	}
+	return value, sub.curToken.Pos.Offset - start.Offset
+}
+
+// advancePosition returns the position just after text, when text starts at pos.
+func advancePosition(pos token.Position, text string) token.Position {
+	for i := 0; i < len(text); i++ {
+		pos.Offset++
+		if text[i] == '\n' {
+			pos.Line++
+			pos.Column = 1
+		} else {
+			pos.Column = token.ColumnAfter(pos.Column, text[i])
+		}
+	}
+	return pos
+}
+
+func (p *Parser) parseBoolean() ast.Expression {
+	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
+}
+
+func (p *Parser) parsePrefixExpression() ast.Expression {
+	expression := &ast.PrefixExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+	}
+	p.nextToken()
+	expression.Right = p.parseExpression(PREFIX)
+	return expression
+}
+
+func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
+	expression := &ast.InfixExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+		Left:     left,
+	}
+	precedence := p.curPrecedence()
+	p.nextToken()
+	expression.Right = p.parseExpression(precedence)
+	return expression
+}
+
+// parsePipeExpression parses `<left> |> <right>`. Pipes are left
+// associative, `x |> f |> g` is g(f(x)).
+func (p *Parser) parsePipeExpression(left ast.Expression) ast.Expression {
+	expression := &ast.PipeExpression{Token: p.curToken, Left: left}
+	precedence := p.curPrecedence()
+	p.nextToken()
+	expression.Right = p.parseExpression(precedence)
+	p.checkPlaceholders(expression.Right)
+	return expression
+}
+
+// checkPlaceholders records an error for every placeholder on the right of
+// a pipe that isn't an argument of the call there, like the one in
+// `x |> f(g(_))`, which would otherwise look like it is passed to g. The
+// right of a pipe nested in it has been checked by that pipe.
+func (p *Parser) checkPlaceholders(right ast.Expression) {
+	arguments := map[ast.Node]bool{}
+	if call, ok := ast.Unparen(right).(*ast.CallExpression); ok {
+		for _, arg := range call.Arguments {
+			arguments[arg] = true
+		}
+	}
+	var check func(ast.Node) bool
+	check = func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.Placeholder:
+			if !arguments[n] {
+				p.errors = append(p.errors, "`_` must be an argument of the call after |>, not inside one")
+			}
+		case *ast.PipeExpression:
+			ast.Walk(n.Left, check)
+			return false
+		}
+		return true
+	}
+	ast.Walk(right, check)
+}
+
+// parsePlaceholder parses `_` in an expression. It only means something
+// as an argument of a call after |>, anywhere else it is an error once
+// evaluated.
+func (p *Parser) parsePlaceholder() ast.Expression {
+	return &ast.Placeholder{Token: p.curToken}
+}
+
+// compoundOperators maps the compound assignment operators to the operator
+// they apply to the old value.
+var compoundOperators = map[token.TokenType]token.TokenType{
+	token.PLUS_ASSIGN:     token.PLUS,
+	token.MINUS_ASSIGN:    token.MINUS,
+	token.ASTERISK_ASSIGN: token.ASTERISK,
+	token.SLASH_ASSIGN:    token.SLASH,
+	token.PERCENT_ASSIGN:  token.PERCENT,
+}
+
+// parseAssignExpression parses `<name> = <value>`. Assignment is right
+// associative, so `a = b = 1` assigns 1 to both.
+//
+// A compound assignment like `x += 2` is parsed as `x = x + 2`, so it obeys
+// the same rules as the operator and the assignment: x has to be declared
+// already. For `a[i] += 2`, a and i are evaluated twice.
+func (p *Parser) parseAssignExpression(left ast.Expression) ast.Expression {
+	expression := &ast.AssignExpression{Token: p.curToken}
+	switch target := ast.Unparen(left).(type) {
+	case *ast.Identifier:
+		expression.Name = target
+	case *ast.IndexExpression:
+		expression.Index = target
+	default:
+		p.errors = append(p.errors, fmt.Sprintf("cannot assign to %s", left.String()))
+		return nil
+	}
+
+	p.nextToken()
+	expression.Value = p.parseExpression(ASSIGN - 1)
+
+	if op, ok := compoundOperators[expression.Token.Type]; ok {
+		operator := string(op)
+		expression.Value = &ast.InfixExpression{
+			Token:    token.Token{Type: op, Literal: operator, Pos: expression.Token.Pos},
+			Left:     left,
+			Operator: operator,
+			Right:    expression.Value,
+		}
+	}
+	return expression
+}
+
+// parseGroupedExpression parses `( <expression> )`. The parentheses only
+// influence precedence, so no node is created for them unless
+// PreserveParens asks for one.
+func (p *Parser) parseGroupedExpression() ast.Expression {
+	lparen := p.curToken
+	p.nextToken()
+
+	exp := p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if PreserveParens {
+		return &ast.GroupedExpression{Token: lparen, Expression: exp, Rparen: p.curToken.Pos}
+	}
+	return exp
+}
+
+// parseIfExpression parses `if (<condition>) { ... } else { ... }`.
+func (p *Parser) parseIfExpression() ast.Expression {
+	expression := &ast.IfExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	expression.Condition = p.parseExpression(LOWEST)
+	p.lintCondition("if", expression.Condition)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	expression.Consequence = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.ELSE) {
+		p.nextToken()
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		expression.Alternative = p.parseBlockStatement()
+	}
+	return expression
+}
+
+// parseForExpression parses `for (<identifier> in <expression>) { ... }`.
+func (p *Parser) parseForExpression() ast.Expression {
+	expression := &ast.ForExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	if !p.expectIdent() {
+		return nil
+	}
+	expression.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+	p.nextToken()
+	expression.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	expression.Body = p.parseLoopBody()
+	return expression
+}
+
+// parseMatchExpression parses `match <subject> { <pattern> => <value>, ... }`.
+// The arms are separated by commas, a trailing comma is allowed.
+func (p *Parser) parseMatchExpression() ast.Expression {
+	expression := &ast.MatchExpression{Token: p.curToken}
+
+	p.nextToken()
+	expression.Subject = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		arm := &ast.MatchArm{Token: p.curToken}
+		if !p.curTokenIs(token.UNDERSCORE) {
+			arm.Pattern = p.parseExpression(LOWEST)
+		}
+		if !p.expectPeek(token.ARROW) {
+			return nil
+		}
+		p.nextToken()
+		arm.Value = p.parseExpression(LOWEST)
+		expression.Arms = append(expression.Arms, arm)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+	expression.Rbrace = p.curToken.Pos
+
+	if len(expression.Arms) == 0 {
+		p.errors = append(p.errors, "match without arms")
+		return nil
+	}
+	return expression
+}
+
+// parseWhileExpression parses `while (<condition>) { ... }`.
+func (p *Parser) parseWhileExpression() ast.Expression {
+	expression := &ast.WhileExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	expression.Condition = p.parseExpression(LOWEST)
+	p.lintCondition("while", expression.Condition)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	expression.Body = p.parseLoopBody()
+	return expression
+}
+
+// parseDoWhileExpression parses `do { ... } while (<condition>)`.
+func (p *Parser) parseDoWhileExpression() ast.Expression {
+	expression := &ast.DoWhileExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	expression.Body = p.parseLoopBody()
+
+	if !p.expectPeek(token.WHILE) {
+		return nil
+	}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	expression.Condition = p.parseExpression(LOWEST)
+	p.lintCondition("while", expression.Condition)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	expression.Rparen = p.curToken.Pos
+	return expression
+}
+
+// lintCondition warns about an assignment used as the whole condition of
+// an if or while, which is usually a mistyped ==.
+func (p *Parser) lintCondition(keyword string, condition ast.Expression) {
+	if !Lint {
+		return
+	}
+	if assign, ok := ast.Unparen(condition).(*ast.AssignExpression); ok {
+		pos := assign.Target().Pos()
+		msg := fmt.Sprintf("%d:%d: assignment used as %s condition, did you mean ==?", pos.Line, pos.Column, keyword)
+		p.warnings = append(p.warnings, msg)
+	}
+}
+
+// parseBlockStatement parses statements until the closing brace.
+// It expects curToken to be the opening brace and leaves curToken on the
+// closing one.
+func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+	block := &ast.BlockStatement{Token: p.curToken}
+	block.Statements = []ast.Statement{}
+
+	p.nextToken()
+	p.parseBlockStatements(block)
+	return block
+}
+
+// parseBlockStatements appends statements to block until curToken is the
+// closing brace.
+func (p *Parser) parseBlockStatements(block *ast.BlockStatement) {
+	p.blocks++
+	defer func() { p.blocks-- }()
+	block.Statements = p.takeComments(block.Statements)
+	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		errors := len(p.errors)
+		stmt, trivia := p.parseStatementWithTrivia()
+		if stmt != nil {
+			block.Statements = append(block.Statements, stmt)
+		}
+		if len(p.errors) > errors {
+			if p.strayBrace {
+				// the statement was cut short by the } closing this
+				// block, which has been consumed already
+				p.strayBrace = false
+				return
+			}
+			p.synchronize()
+		} else {
+			p.checkTerminator()
+		}
+		p.nextToken()
+		block.Statements = p.takeComments(block.Statements)
+		p.takeTrailing(trivia)
+	}
+	if !p.curTokenIs(token.RBRACE) {
+		p.errors = append(p.errors, "expected } to close block, got EOF instead")
+	}
+	block.Rbrace = p.curToken.Pos
+}
+
+// parseBraceExpression parses a `{` in expression position, which is either a
+// hash literal or a block expression. `{}` is the empty hash and a block can't
+// start with a key, so it is a hash exactly when the first expression inside
+// the braces is followed by a colon.
+func (p *Parser) parseBraceExpression() ast.Expression {
+	braceToken := p.curToken
+
+	if p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		return &ast.HashLiteral{Token: braceToken, Pairs: []ast.HashPair{}, Rbrace: p.curToken.Pos}
+	}
+	if p.peekTokenIs(token.LET) || p.peekTokenIs(token.RETURN) {
+		return &ast.BlockExpression{Token: braceToken, Block: p.parseBlockStatement()}
+	}
+
+	p.nextToken()
+	firstToken := p.curToken
+	first := p.parseHashKey()
+
+	if _, spread := first.(*ast.SpreadElement); spread || p.peekTokenIs(token.COLON) {
+		return p.parseHashLiteral(braceToken, first)
+	}
+
+	// it's a block, and first is its first expression statement
+	stmt := &ast.ExpressionStatement{Token: firstToken, Expression: first}
+	block := &ast.BlockStatement{Token: braceToken, Statements: []ast.Statement{stmt}}
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	p.nextToken()
+	p.parseBlockStatements(block)
+	return &ast.BlockExpression{Token: braceToken, Block: block}
+}
+
+// parseHashLiteral parses the rest of `{<key>: <value>, ...}` once the first
+// key has been parsed. curToken is the last token of that key. An entry can
+// also spread another hash, `{...<hash>, <key>: <value>}`.
+func (p *Parser) parseHashLiteral(braceToken token.Token, firstKey ast.Expression) ast.Expression {
+	hash := &ast.HashLiteral{Token: braceToken, Pairs: []ast.HashPair{}}
+
+	key := firstKey
+	for {
+		if _, spread := key.(*ast.SpreadElement); spread {
+			hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key})
+		} else {
+			if !p.expectPeek(token.COLON) {
+				return nil
+			}
+			p.nextToken()
+			value := p.parseExpression(LOWEST)
+			hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+		}
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+		// allow a trailing comma
+		if p.peekTokenIs(token.RBRACE) {
+			break
+		}
+		p.nextToken()
+		key = p.parseHashKey()
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	hash.Rbrace = p.curToken.Pos
+	return hash
+}
+
+// parseHashKey parses an expression that may be the key of a hash literal.
+// With IdentifierKeys, an identifier followed by a colon is a string key.
+func (p *Parser) parseHashKey() ast.Expression {
+	if IdentifierKeys && p.curTokenIs(token.IDENT) && p.peekTokenIs(token.COLON) {
+		return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+	}
+	return p.parseExpression(LOWEST)
+}
+
+// parseArrayLiteral parses `[<expression>, ...]`.
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.Rbracket = p.curToken.Pos
+	return array
+}
+
+// parseSpreadElement parses `...<expression>`. Only array and hash literals
+// and call arguments can be spread into, which the evaluator checks.
+func (p *Parser) parseSpreadElement() ast.Expression {
+	spread := &ast.SpreadElement{Token: p.curToken}
+	p.nextToken()
+	spread.Value = p.parseExpression(LOWEST)
+	return spread
+}
+
+// parseIndexExpression parses `<left>[<index>]`.
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+
+	p.nextToken()
+	exp.Index = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	exp.Rbracket = p.curToken.Pos
+	return exp
+}
+
+// parseDotExpression parses `<left>.<name>`.
+func (p *Parser) parseDotExpression(left ast.Expression) ast.Expression {
+	exp := &ast.DotExpression{Token: p.curToken, Left: left}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	return exp
+}
+
+// parseOptionalIndexExpression parses `<left>?.<name>` and
+// `<left>?.<index>`, where index is an integer literal like in `a?.0`.
+func (p *Parser) parseOptionalIndexExpression(left ast.Expression) ast.Expression {
+	exp := &ast.OptionalIndexExpression{Token: p.curToken, Left: left}
+
+	switch p.peekToken.Type {
+	case token.IDENT:
+		p.nextToken()
+		exp.Index = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+	case token.INT:
+		p.nextToken()
+		exp.Index = p.parseIntegerLiteral()
+		if exp.Index == nil {
+			return nil
+		}
+	default:
+		msg := fmt.Sprintf("expected a name or an index after ?., got %s instead", p.peekToken.Type)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+	return exp
+}
+
+// parseFunctionLiteral parses `fn(<parameters>) { ... }`.
+func (p *Parser) parseFunctionLiteral() ast.Expression {
+	lit := &ast.FunctionLiteral{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	lit.Parameters, lit.Defaults = p.parseFunctionParameters()
+	if lit.Parameters == nil {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	loops := p.loops
+	p.loops = 0
+	lit.Body = p.parseBlockStatement()
+	p.loops = loops
+	return lit
+}
+
+// parseFunctionParameters parses a comma separated list of parameters up
+// to and including the closing paren. A parameter is an identifier with an
+// optional default value, `y = 10`. Once a parameter has a default, all the
+// parameters after it need one too. The returned defaults line up with the
+// parameters and are nil for parameters without a default.
+func (p *Parser) parseFunctionParameters() ([]*ast.Identifier, []ast.Expression) {
+	identifiers := []*ast.Identifier{}
+	defaults := []ast.Expression{}
+
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return identifiers, defaults
+	}
+
+	for {
+		if !p.expectIdent() {
+			return nil, nil
+		}
+		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+		var def ast.Expression
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			def = p.parseExpression(ASSIGN)
+		} else if len(defaults) > 0 && defaults[len(defaults)-1] != nil {
+			msg := fmt.Sprintf("parameter %s without a default follows a parameter with a default", ident.Value)
+			p.errors = append(p.errors, msg)
+		}
+		identifiers = append(identifiers, ident)
+		defaults = append(defaults, def)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil, nil
+	}
+	return identifiers, defaults
+}
+
+func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	exp := &ast.CallExpression{Token: p.curToken, Function: function}
+	exp.Arguments = p.parseCallArguments()
+	exp.Rparen = p.curToken.Pos
+	return exp
+}
+
+// parseCallArguments parses the arguments of a call up to and including the
+// closing paren. An argument is either an expression or `<name> = <expression>`,
+// and named arguments have to come after the positional ones.
+func (p *Parser) parseCallArguments() []ast.Expression {
+	args := []ast.Expression{}
+
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return args
+	}
+
+	seenNamed := false
+	for {
+		p.nextToken()
+		arg := p.parseCallArgument()
+
+		if _, named := arg.(*ast.NamedArgument); named {
+			seenNamed = true
+		} else if seenNamed {
+			p.errors = append(p.errors, "positional argument after named argument")
+		}
+		args = append(args, arg)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	return args
+}
+
+// parseCallArgument parses a single call argument, which is a named argument
+// when it is an identifier followed by `=`.
+func (p *Parser) parseCallArgument() ast.Expression {
+	if p.curTokenIs(token.IDENT) && p.peekTokenIs(token.ASSIGN) {
+		arg := &ast.NamedArgument{Token: p.curToken}
+		arg.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		p.nextToken()
+		p.nextToken()
+		arg.Value = p.parseExpression(LOWEST)
+		return arg
+	}
+	return p.parseExpression(LOWEST)
+}
+
+// parseExpressionList parses a comma separated list of expressions
+// up to and including the end token.
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	list := []ast.Expression{}
+
+	if p.peekTokenIs(end) {
+		p.nextToken()
+		return list
+	}
+
+	p.nextToken()
+	list = append(list, p.parseExpression(LOWEST))
+
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		p.nextToken()
+		list = append(list, p.parseExpression(LOWEST))
+	}
+
+	if !p.expectPeek(end) {
+		return nil
+	}
+	return list
+}
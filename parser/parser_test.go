@@ -0,0 +1,1893 @@
+package parser
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLetStatements(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedIdentifier string
+		expectedValue      interface{}
+	}{
+		{"let x = 5;", "x", 5},
+		{"let y = true;", "y", true},
+		{"let foobar = y;", "foobar", "y"},
+	}
+
+	for _, tt := range tests {
+		program := parseProgram(t, tt.input)
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+		stmt := program.Statements[0]
+		if !testLetStatement(t, stmt, tt.expectedIdentifier) {
+			return
+		}
+		val := stmt.(*ast.LetStatement).Value
+		if !testLiteralExpression(t, val, tt.expectedValue) {
+			return
+		}
+	}
+}
+
+func TestDestructuringLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b] = [1, 2];", "let [a, b] = [1, 2];"},
+		{"let [a, [b, c]] = x;", "let [a, [b, c]] = x;"},
+		{"let [] = x;", "let [] = x;"},
+		{`let {x, y} = h;`, "let {x, y} = h;"},
+		{`let {x: [a, b], y: {z}} = h;`, "let {x: [a, b], y: {z}} = h;"},
+	}
+
+	for i, tt := range tests {
+		program := parseProgram(t, tt.input)
+		stmt, ok := program.Statements[0].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("tests[%d] - stmt is not *ast.LetStatement. got=%T", i, program.Statements[0])
+		}
+		if stmt.Name != nil || stmt.Pattern == nil {
+			t.Fatalf("tests[%d] - expected a pattern instead of a name. got Name=%v", i, stmt.Name)
+		}
+		if stmt.String() != tt.expected {
+			t.Errorf("tests[%d] - wrong String(). expected=%q, got=%q", i, tt.expected, stmt.String())
+		}
+	}
+
+	program := parseProgram(t, "let [a, {b}] = x;")
+	pattern, ok := program.Statements[0].(*ast.LetStatement).Pattern.(*ast.ArrayPattern)
+	if !ok {
+		t.Fatalf("pattern is not *ast.ArrayPattern")
+	}
+	testIdentifier(t, pattern.Elements[0], "a")
+	hash, ok := pattern.Elements[1].(*ast.HashPattern)
+	if !ok {
+		t.Fatalf("nested pattern is not *ast.HashPattern. got=%T", pattern.Elements[1])
+	}
+	testIdentifier(t, hash.Keys[0], "b")
+}
+
+func TestDestructuringErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [1] = x;", "expected a name or a pattern to bind, got INT instead"},
+		{"let [a b] = x;", "expected next token to be ,, got IDENT instead"},
+		{`let {"x"} = h;`, "expected next token to be IDENT, got STRING instead"},
+	}
+
+	for i, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if errors := p.Errors(); len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("tests[%d] - wrong errors. expected first=%q, got=%q", i, tt.expected, errors)
+		}
+	}
+}
+
+func TestReturnStatements(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedValue interface{}
+	}{
+		{"return 5;", 5},
+		{"return true;", true},
+		{"return foobar;", "foobar"},
+	}
+
+	for _, tt := range tests {
+		program := parseProgram(t, tt.input)
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+		returnStmt, ok := program.Statements[0].(*ast.ReturnStatement)
+		if !ok {
+			t.Fatalf("stmt not *ast.ReturnStatement. got=%T", program.Statements[0])
+		}
+		if !testLiteralExpression(t, returnStmt.ReturnValue, tt.expectedValue) {
+			return
+		}
+	}
+}
+
+func TestMissingFinalSemicolon(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 5", "let x = 5;"},
+		{"let x = 5;\nlet y = x", "let x = 5;let y = x;"},
+		{"return 5", "return 5;"},
+		{"x + 1", "(x + 1)"},
+		{"let [a, b] = pair", "let [a, b] = pair;"},
+		{"let f = fn() { let y = 1 }", "let f = fn() let y = 1;;"},
+		{"let x = 5 // five", "let x = 5;"},
+		{"let x = 5\n\n", "let x = 5;"},
+		// so do the end of a line and a closing brace
+		{"let x = 5\nlet y = 6", "let x = 5;let y = 6;"},
+		{"if (x) { 1 } let y = 2", "ifx 1let y = 2;"},
+		{"let f = fn() { 1 }\nf()", "let f = fn() 1;f()"},
+	}
+
+	for _, tt := range tests {
+		program := parseProgram(t, tt.input)
+		if program.String() != tt.expected {
+			t.Errorf("wrong program for %q. expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+
+	// two statements on one line need a semicolon between them
+	errorTests := []struct {
+		input string
+		next  string
+	}{
+		{"let x = 5 let y = 6", "LET"},
+		{"let x = 5 let y = 6; y", "LET"},
+		{"x + 1 y", "IDENT"},
+		{"return 1 2", "INT"},
+		{"let f = fn() { let y = 1 return y }", "RETURN"},
+	}
+	for _, tt := range errorTests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		expected := "expected ; between statements on the same line, got " + tt.next + " instead"
+		if errors := p.Errors(); len(errors) != 1 || errors[0] != expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%q", tt.input, expected, errors)
+		}
+	}
+
+	NewlineTerminates = true
+	defer func() { NewlineTerminates = false }()
+	p := New(lexer.New("let x = 5\nlet y = 6\ny"))
+	if program := p.ParseProgram(); len(p.Errors()) != 0 || len(program.Statements) != 3 {
+		t.Errorf("wrong program with NewlineTerminates. errors=%q, got=%s", p.Errors(), program)
+	}
+}
+
+func TestParsingErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"let = 5;", "expected next token to be IDENT, got = instead"},
+		{"let x 5;", "expected next token to be =, got INT instead"},
+		{"{ 1", "expected } to close block, got EOF instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("expected errors for %q, got none", tt.input)
+		}
+		if errors[0] != tt.expectedError {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expectedError, errors[0])
+		}
+	}
+}
+
+func TestIdentifierExpression(t *testing.T) {
+	program := parseProgram(t, "foobar;")
+	stmt := singleExpressionStatement(t, program)
+	testIdentifier(t, stmt.Expression, "foobar")
+}
+
+func TestIntegerLiteralExpression(t *testing.T) {
+	program := parseProgram(t, "5;")
+	stmt := singleExpressionStatement(t, program)
+	testIntegerLiteral(t, stmt.Expression, 5)
+}
+
+func TestLeadingZeros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0", 0},
+		{"00", 0},
+		{"0_0", 0},
+		{"007", 7},
+		{"010", 10},
+		{"09", 9},
+		{"0_1_000", 1000},
+		{"0o10", 8},
+	}
+
+	for i, tt := range tests {
+		stmt := singleExpressionStatement(t, parseProgram(t, tt.input))
+		lit, ok := stmt.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Fatalf("tests[%d] - exp not *ast.IntegerLiteral. got=%T", i, stmt.Expression)
+		}
+		if lit.Value != tt.expected {
+			t.Errorf("tests[%d] - wrong value for %s. expected=%d, got=%d", i, tt.input, tt.expected, lit.Value)
+		}
+	}
+
+	stmt := singleExpressionStatement(t, parseProgram(t, "0+1"))
+	testInfixExpression(t, stmt.Expression, 0, "+", 1)
+}
+
+func TestParsingPrefixExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		operator string
+		value    interface{}
+	}{
+		{"!5;", "!", 5},
+		{"-15;", "-", 15},
+		{"+15;", "+", 15},
+		{"!true;", "!", true},
+		{"!foobar;", "!", "foobar"},
+	}
+
+	for _, tt := range tests {
+		program := parseProgram(t, tt.input)
+		stmt := singleExpressionStatement(t, program)
+		exp, ok := stmt.Expression.(*ast.PrefixExpression)
+		if !ok {
+			t.Fatalf("stmt is not ast.PrefixExpression. got=%T", stmt.Expression)
+		}
+		if exp.Operator != tt.operator {
+			t.Fatalf("exp.Operator is not '%s'. got=%s", tt.operator, exp.Operator)
+		}
+		if !testLiteralExpression(t, exp.Right, tt.value) {
+			return
+		}
+	}
+}
+
+func TestParsingInfixExpressions(t *testing.T) {
+	tests := []struct {
+		input      string
+		leftValue  interface{}
+		operator   string
+		rightValue interface{}
+	}{
+		{"5 + 5;", 5, "+", 5},
+		{"5 - 5;", 5, "-", 5},
+		{"5 * 5;", 5, "*", 5},
+		{"5 / 5;", 5, "/", 5},
+		{"5 % 5;", 5, "%", 5},
+		{"5 > 5;", 5, ">", 5},
+		{"5 < 5;", 5, "<", 5},
+		{"5 == 5;", 5, "==", 5},
+		{"5 != 5;", 5, "!=", 5},
+		{"true == false", true, "==", false},
+		{"a != b", "a", "!=", "b"},
+	}
+
+	for _, tt := range tests {
+		program := parseProgram(t, tt.input)
+		stmt := singleExpressionStatement(t, program)
+		if !testInfixExpression(t, stmt.Expression, tt.leftValue, tt.operator, tt.rightValue) {
+			return
+		}
+	}
+}
+
+func TestOperatorPrecedenceParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"-a * b", "((-a) * b)"},
+		{"+a * b", "((+a) * b)"},
+		{"a + +b", "(a + (+b))"},
+		{"!-a", "(!(-a))"},
+		{"a + b + c", "((a + b) + c)"},
+		{"a + b * c + d / e - f", "(((a + (b * c)) + (d / e)) - f)"},
+		{"a + b % c * d", "(a + ((b % c) * d))"},
+		{"5 > 4 == 3 < 4", "((5 > 4) == (3 < 4))"},
+		{"3 + 4 * 5 == 3 * 1 + 4 * 5", "((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))"},
+		{"1 + (2 + 3) + 4", "((1 + (2 + 3)) + 4)"},
+		{"-(5 + 5)", "(-(5 + 5))"},
+		{"!(true == true)", "(!(true == true))"},
+		{"a + add(b * c) + d", "((a + add((b * c))) + d)"},
+		{"add(a, b, 1, 2 * 3, add(6, 7 * 8))", "add(a, b, 1, (2 * 3), add(6, (7 * 8)))"},
+		{"{ 1 } + 2", "({ 1 } + 2)"},
+		{"a * [1, 2, 3, 4][b * c] * d", "((a * ([1, 2, 3, 4][(b * c)])) * d)"},
+		{"add(a * b[2], b[1], 2 * [1, 2][1])", "add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))"},
+		{"a = b = 1 + 2", "(a = (b = (1 + 2)))"},
+		{"x = y == z", "(x = (y == z))"},
+		{"x += 1 * 2", "(x = (x + (1 * 2)))"},
+		{"x -= y = 2", "(x = (x - (y = 2)))"},
+		{"a[0] *= 3", "((a[0]) = ((a[0]) * 3))"},
+		{"x /= 2; x %= 3", "(x = (x / 2))(x = (x % 3))"},
+	}
+
+	for _, tt := range tests {
+		program := parseProgram(t, tt.input)
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestIfElseExpression(t *testing.T) {
+	program := parseProgram(t, `if (x < y) { x } else { y }`)
+	stmt := singleExpressionStatement(t, program)
+
+	exp, ok := stmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.IfExpression. got=%T", stmt.Expression)
+	}
+	if !testInfixExpression(t, exp.Condition, "x", "<", "y") {
+		return
+	}
+	if len(exp.Consequence.Statements) != 1 {
+		t.Fatalf("consequence is not 1 statement. got=%d", len(exp.Consequence.Statements))
+	}
+	consequence, ok := exp.Consequence.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Statements[0] is not ast.ExpressionStatement. got=%T", exp.Consequence.Statements[0])
+	}
+	testIdentifier(t, consequence.Expression, "x")
+
+	if exp.Alternative == nil || len(exp.Alternative.Statements) != 1 {
+		t.Fatalf("exp.Alternative is not 1 statement. got=%+v", exp.Alternative)
+	}
+	alternative, ok := exp.Alternative.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Statements[0] is not ast.ExpressionStatement. got=%T", exp.Alternative.Statements[0])
+	}
+	testIdentifier(t, alternative.Expression, "y")
+}
+
+func TestFunctionLiteralParsing(t *testing.T) {
+	program := parseProgram(t, `fn(x, y) { x + y; }`)
+	stmt := singleExpressionStatement(t, program)
+
+	function, ok := stmt.Expression.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.FunctionLiteral. got=%T", stmt.Expression)
+	}
+	if len(function.Parameters) != 2 {
+		t.Fatalf("function literal parameters wrong. want 2, got=%d", len(function.Parameters))
+	}
+	testLiteralExpression(t, function.Parameters[0], "x")
+	testLiteralExpression(t, function.Parameters[1], "y")
+
+	if len(function.Body.Statements) != 1 {
+		t.Fatalf("function.Body.Statements has not 1 statement. got=%d", len(function.Body.Statements))
+	}
+	bodyStmt, ok := function.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("function body stmt is not ast.ExpressionStatement. got=%T", function.Body.Statements[0])
+	}
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+}
+
+func TestFunctionParameterParsing(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string
+	}{
+		{"fn() {};", []string{}},
+		{"fn(x) {};", []string{"x"}},
+		{"fn(x, y, z) {};", []string{"x", "y", "z"}},
+	}
+
+	for _, tt := range tests {
+		program := parseProgram(t, tt.input)
+		stmt := singleExpressionStatement(t, program)
+		function := stmt.Expression.(*ast.FunctionLiteral)
+
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Errorf("length parameters wrong. want %d, got=%d", len(tt.expectedParams), len(function.Parameters))
+		}
+		for i, ident := range tt.expectedParams {
+			testLiteralExpression(t, function.Parameters[i], ident)
+		}
+	}
+}
+
+func TestDefaultParameterParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(x, y = 10) { x + y }", "fn(x, y = 10) (x + y)"},
+		{"fn(x = 1, y = x * 2) { y }", "fn(x = 1, y = (x * 2)) y"},
+		{"fn(f = fn(a) { a }) { f(1) }", "fn(f = fn(a) a) f(1)"},
+	}
+
+	for _, tt := range tests {
+		program := parseProgram(t, tt.input)
+		stmt := singleExpressionStatement(t, program)
+		function := stmt.Expression.(*ast.FunctionLiteral)
+
+		if len(function.Defaults) != len(function.Parameters) {
+			t.Fatalf("%s: defaults don't line up with parameters. got=%d, want=%d",
+				tt.input, len(function.Defaults), len(function.Parameters))
+		}
+		if function.String() != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, function.String())
+		}
+	}
+
+	program := parseProgram(t, "fn(x, y = 10) {}")
+	function := singleExpressionStatement(t, program).Expression.(*ast.FunctionLiteral)
+	if function.Defaults[0] != nil {
+		t.Errorf("x has a default. got=%s", function.Defaults[0])
+	}
+	testIntegerLiteral(t, function.Defaults[1], 10)
+}
+
+func TestRequiredParameterAfterDefault(t *testing.T) {
+	p := New(lexer.New("fn(x = 1, y) { x }"))
+	p.ParseProgram()
+
+	errors := p.Errors()
+	expected := "parameter y without a default follows a parameter with a default"
+	if len(errors) != 1 || errors[0] != expected {
+		t.Fatalf("wrong errors. want=%q, got=%q", expected, errors)
+	}
+}
+
+func TestCallExpressionParsing(t *testing.T) {
+	program := parseProgram(t, "add(1, 2 * 3, 4 + 5);")
+	stmt := singleExpressionStatement(t, program)
+
+	exp, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T", stmt.Expression)
+	}
+	if !testIdentifier(t, exp.Function, "add") {
+		return
+	}
+	if len(exp.Arguments) != 3 {
+		t.Fatalf("wrong length of arguments. got=%d", len(exp.Arguments))
+	}
+	testLiteralExpression(t, exp.Arguments[0], 1)
+	testInfixExpression(t, exp.Arguments[1], 2, "*", 3)
+	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
+}
+
+func TestNamedArgumentParsing(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedNames []string // "" for a positional argument
+	}{
+		{`greet(name = "Sam", greeting = "hi")`, []string{"name", "greeting"}},
+		{`greet("Sam", greeting = "hi")`, []string{"", "greeting"}},
+		{`greet(a, b == c)`, []string{"", ""}},
+	}
+
+	for _, tt := range tests {
+		program := parseProgram(t, tt.input)
+		stmt := singleExpressionStatement(t, program)
+		call, ok := stmt.Expression.(*ast.CallExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T", stmt.Expression)
+		}
+		if len(call.Arguments) != len(tt.expectedNames) {
+			t.Fatalf("wrong number of arguments. want=%d, got=%d", len(tt.expectedNames), len(call.Arguments))
+		}
+		for i, name := range tt.expectedNames {
+			named, isNamed := call.Arguments[i].(*ast.NamedArgument)
+			if name == "" {
+				if isNamed {
+					t.Errorf("%s: argument %d is named, expected positional", tt.input, i)
+				}
+				continue
+			}
+			if !isNamed {
+				t.Errorf("%s: argument %d is not ast.NamedArgument. got=%T", tt.input, i, call.Arguments[i])
+				continue
+			}
+			testIdentifier(t, named.Name, name)
+		}
+	}
+}
+
+func TestPositionalAfterNamedArgument(t *testing.T) {
+	p := New(lexer.New(`greet(name = "Sam", "hi")`))
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 || errors[0] != "positional argument after named argument" {
+		t.Errorf("wrong errors. got=%q", errors)
+	}
+}
+
+func TestBlockExpressionParsing(t *testing.T) {
+	program := parseProgram(t, "let y = { let x = 1; x + 1 };")
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+	let, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("stmt is not ast.LetStatement. got=%T", program.Statements[0])
+	}
+	block, ok := let.Value.(*ast.BlockExpression)
+	if !ok {
+		t.Fatalf("let.Value is not ast.BlockExpression. got=%T", let.Value)
+	}
+	if len(block.Block.Statements) != 2 {
+		t.Fatalf("block has not 2 statements. got=%d", len(block.Block.Statements))
+	}
+	if !testLetStatement(t, block.Block.Statements[0], "x") {
+		return
+	}
+	last, ok := block.Block.Statements[1].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("block.Statements[1] is not ast.ExpressionStatement. got=%T", block.Block.Statements[1])
+	}
+	testInfixExpression(t, last.Expression, "x", "+", 1)
+}
+
+func TestNestedBlockExpressionParsing(t *testing.T) {
+	program := parseProgram(t, "{ let a = 1; { a + 2 } }")
+	stmt := singleExpressionStatement(t, program)
+
+	outer, ok := stmt.Expression.(*ast.BlockExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.BlockExpression. got=%T", stmt.Expression)
+	}
+	if len(outer.Block.Statements) != 2 {
+		t.Fatalf("outer block has not 2 statements. got=%d", len(outer.Block.Statements))
+	}
+	inner, ok := outer.Block.Statements[1].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("outer.Statements[1] is not ast.ExpressionStatement. got=%T", outer.Block.Statements[1])
+	}
+	if _, ok := inner.Expression.(*ast.BlockExpression); !ok {
+		t.Fatalf("inner expression is not ast.BlockExpression. got=%T", inner.Expression)
+	}
+}
+
+func TestParsingArrayLiterals(t *testing.T) {
+	program := parseProgram(t, "[1, 2 * 2, 3 + 3]")
+	stmt := singleExpressionStatement(t, program)
+
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+	if len(array.Elements) != 3 {
+		t.Fatalf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	}
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+}
+
+func TestParsingIndexExpressions(t *testing.T) {
+	program := parseProgram(t, "myArray[1 + 1]")
+	stmt := singleExpressionStatement(t, program)
+
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+	}
+	if !testIdentifier(t, indexExp.Left, "myArray") {
+		return
+	}
+	testInfixExpression(t, indexExp.Index, 1, "+", 1)
+}
+
+func TestParsingHashLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{}`, "{}"},
+		{`{"one": 1, "two": 2, "three": 3}`, "{one: 1, two: 2, three: 3}"},
+		{`{"one": 0 + 1, "two": 10 - 8,}`, "{one: (0 + 1), two: (10 - 8)}"},
+		{`{1: true, false: "no"}`, "{1: true, false: no}"},
+		{`{key: 1}`, "{key: 1}"},
+		{`{"a": {"b": 1}}`, "{a: {b: 1}}"},
+	}
+
+	for _, tt := range tests {
+		program := parseProgram(t, tt.input)
+		stmt := singleExpressionStatement(t, program)
+
+		hash, ok := stmt.Expression.(*ast.HashLiteral)
+		if !ok {
+			t.Fatalf("%s: exp is not ast.HashLiteral. got=%T", tt.input, stmt.Expression)
+		}
+		if hash.String() != tt.expected {
+			t.Errorf("%s: wrong hash. expected=%q, got=%q", tt.input, tt.expected, hash.String())
+		}
+	}
+}
+
+func TestIdentifierKeys(t *testing.T) {
+	IdentifierKeys = true
+	defer func() { IdentifierKeys = false }()
+
+	program := parseProgram(t, `{name: x, "age": 30, (keyVar): y}`)
+	stmt := singleExpressionStatement(t, program)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+	if len(hash.Pairs) != 3 {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	name, ok := hash.Pairs[0].Key.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("first key is not ast.StringLiteral. got=%T", hash.Pairs[0].Key)
+	}
+	if name.Value != "name" {
+		t.Errorf("first key has wrong value. got=%q", name.Value)
+	}
+	testIdentifier(t, hash.Pairs[0].Value, "x")
+
+	if _, ok := hash.Pairs[1].Key.(*ast.StringLiteral); !ok {
+		t.Errorf("second key is not ast.StringLiteral. got=%T", hash.Pairs[1].Key)
+	}
+	testIdentifier(t, hash.Pairs[2].Key, "keyVar")
+
+	// only a bare identifier is a string key, a longer expression isn't
+	program = parseProgram(t, `{a + b: 1}`)
+	stmt = singleExpressionStatement(t, program)
+	hash = stmt.Expression.(*ast.HashLiteral)
+	testInfixExpression(t, hash.Pairs[0].Key, "a", "+", "b")
+
+	// and without the option an identifier key is still a variable
+	IdentifierKeys = false
+	program = parseProgram(t, `{name: x}`)
+	stmt = singleExpressionStatement(t, program)
+	hash = stmt.Expression.(*ast.HashLiteral)
+	testIdentifier(t, hash.Pairs[0].Key, "name")
+}
+
+func TestPreserveParens(t *testing.T) {
+	program := parseProgram(t, "(1 + 2) * 3")
+	stmt := singleExpressionStatement(t, program)
+	infix, ok := stmt.Expression.(*ast.InfixExpression)
+	if !ok {
+		t.Fatalf("exp is not *ast.InfixExpression. got=%T", stmt.Expression)
+	}
+	if _, ok := infix.Left.(*ast.InfixExpression); !ok {
+		t.Errorf("parentheses were kept without PreserveParens. got=%T", infix.Left)
+	}
+
+	PreserveParens = true
+	defer func() { PreserveParens = false }()
+
+	program = parseProgram(t, "(1 + 2) * 3")
+	stmt = singleExpressionStatement(t, program)
+	infix = stmt.Expression.(*ast.InfixExpression)
+	grouped, ok := infix.Left.(*ast.GroupedExpression)
+	if !ok {
+		t.Fatalf("left is not *ast.GroupedExpression. got=%T", infix.Left)
+	}
+	testInfixExpression(t, grouped.Expression, 1, "+", 2)
+	if grouped.Pos().Column != 1 || grouped.Rparen.Column != 7 {
+		t.Errorf("wrong positions of the parentheses. got=%+v, %+v", grouped.Pos(), grouped.Rparen)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(1 + 2) * 3", "(((1 + 2)) * 3)"},
+		{"(x) + 1", "((x) + 1)"},
+		{"((x))", "((x))"},
+		{"-(a)", "(-(a))"},
+		{"(f)(1)", "(f)(1)"},
+		{"(x) = 1", "(x = 1)"},
+		{"(a[0]) += 1", "((a[0]) = (((a[0])) + 1))"},
+	}
+	for _, tt := range tests {
+		program := parseProgram(t, tt.input)
+		if program.String() != tt.expected {
+			t.Errorf("wrong string for %q. expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestPreserveTrivia(t *testing.T) {
+	PreserveTrivia = true
+	defer func() { PreserveTrivia = false }()
+
+	inputs := []string{
+		"",
+		"// only a comment\n",
+		"let x = 1;",
+		"let x=1;let y=2",
+		"// the answer\nlet x = 42; // always\n\n\nx\n",
+		"  let   add = fn(a,b) {\n    // add them\n    a+b;   // done\n\n  };\r\nadd(1,  2) // three",
+		"let s = \"a\\tb\"; let t = `raw ${x}`; let u = \"${ s }!\"\t\n",
+		"if (x) { 1 } else {\n  // nothing\n}\n  \\\n1 \n",
+		"return   [1,\n  2]  ;  \n// the end",
+	}
+
+	for _, input := range inputs {
+		program := parseProgram(t, input)
+		if program.Source() != input {
+			t.Errorf("wrong source. expected=%q, got=%q", input, program.Source())
+		}
+	}
+
+	program := parseProgram(t, "// the answer\nlet x = 42; // always\n\nx")
+	if len(program.Statements) != 2 {
+		t.Fatalf("program has wrong number of statements. got=%d", len(program.Statements))
+	}
+	let := ast.TriviaOf(program.Statements[0])
+	if literals(let.Leading) != "// the answer\n" || let.Source != "let x = 42;" || literals(let.Trailing) != " // always" {
+		t.Errorf("wrong trivia of let. got=%q %q %q", literals(let.Leading), let.Source, literals(let.Trailing))
+	}
+	exp := ast.TriviaOf(program.Statements[1])
+	if literals(exp.Leading) != "\n\n" || exp.Source != "x" || len(exp.Trailing) != 0 {
+		t.Errorf("wrong trivia of x. got=%q %q %q", literals(exp.Leading), exp.Source, literals(exp.Trailing))
+	}
+
+	// trivia doesn't change the statements themselves
+	PreserveTrivia = false
+	plain := parseProgram(t, "// the answer\nlet x = 42; // always\n\nx")
+	if plain.String() != program.String() {
+		t.Errorf("trivia changed the parse result. want=%q, got=%q", plain.String(), program.String())
+	}
+	if ast.TriviaOf(plain.Statements[0]) != nil {
+		t.Errorf("trivia recorded without PreserveTrivia")
+	}
+}
+
+func TestTrailingComments(t *testing.T) {
+	PreserveTrivia = true
+	defer func() { PreserveTrivia = false }()
+
+	program := parseProgram(t, "let x = 5; // the count\n// the next one\nlet y = x\nlet f = fn() {\n  y // inner\n}; //outer\n")
+	if len(program.Statements) != 3 {
+		t.Fatalf("program has wrong number of statements. got=%d", len(program.Statements))
+	}
+	expected := []string{"the count", "", "outer"}
+	for i, stmt := range program.Statements {
+		if got := ast.TriviaOf(stmt).TrailingComment(); got != expected[i] {
+			t.Errorf("statements[%d] - wrong trailing comment. expected=%q, got=%q", i, expected[i], got)
+		}
+	}
+
+	// the comment on a line of its own leads the next statement
+	next := ast.TriviaOf(program.Statements[1])
+	if literals(next.Leading) != "\n// the next one\n" {
+		t.Errorf("wrong leading trivia of y. got=%q", literals(next.Leading))
+	}
+
+	f := program.Statements[2].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	if got := ast.TriviaOf(f.Body.Statements[0]).TrailingComment(); got != "inner" {
+		t.Errorf("wrong trailing comment in the body. got=%q", got)
+	}
+}
+
+func TestCommentStatements(t *testing.T) {
+	CommentStatements = true
+	defer func() { CommentStatements = false }()
+
+	input := "// start\nlet x = 1; // one\nlet f = fn() {\n  // body\n  x + // inside\n  1\n  // end of body\n};\n// done"
+	program := parseProgram(t, input)
+	expected := []string{"// start", "let x = 1;", "// one", "let f = fn() // body(x + 1)// end of body;", "// done"}
+	if len(program.Statements) != len(expected) {
+		t.Fatalf("program has wrong number of statements. want=%d, got=%d", len(expected), len(program.Statements))
+	}
+	for i, stmt := range program.Statements {
+		if stmt.String() != expected[i] {
+			t.Errorf("statements[%d] wrong. want=%q, got=%q", i, expected[i], stmt.String())
+		}
+	}
+	comment, ok := program.Statements[2].(*ast.CommentStatement)
+	if !ok {
+		t.Fatalf("statements[2] is not *ast.CommentStatement. got=%T", program.Statements[2])
+	}
+	if comment.Text() != "one" || comment.Pos().Line != 2 || comment.Pos().Column != 12 {
+		t.Errorf("wrong comment. got=%q at %+v", comment.Text(), comment.Pos())
+	}
+
+	// with PreserveTrivia the comments are still part of the source
+	PreserveTrivia = true
+	defer func() { PreserveTrivia = false }()
+	input += "\n\n  // really\n"
+	if source := parseProgram(t, input).Source(); source != input {
+		t.Errorf("wrong source. expected=%q, got=%q", input, source)
+	}
+}
+
+func TestPreserveTriviaWithNewlineTerminates(t *testing.T) {
+	PreserveTrivia = true
+	NewlineTerminates = true
+	defer func() { PreserveTrivia, NewlineTerminates = false, false }()
+
+	input := "let x = 1 // one\nx\n-1\n\n// end\n"
+	program := parseProgram(t, input)
+	if len(program.Statements) != 3 {
+		t.Fatalf("program has wrong number of statements. got=%d", len(program.Statements))
+	}
+	if program.Source() != input {
+		t.Errorf("wrong source. expected=%q, got=%q", input, program.Source())
+	}
+}
+
+func literals(tokens []token.Token) string {
+	var out strings.Builder
+	for _, tok := range tokens {
+		out.WriteString(tok.Literal)
+	}
+	return out.String()
+}
+
+func TestBraceExpressionDisambiguation(t *testing.T) {
+	tests := []struct {
+		input   string
+		isBlock bool
+	}{
+		{`{}`, false},
+		{`{"a": 1}`, false},
+		{`{ x + 1 }`, true},
+		{`{ "a"; 1 }`, true},
+		{`{ let a = 1; a }`, true},
+		{`{ return 1; }`, true},
+		{`{ {"a": 1} }`, true},
+	}
+
+	for _, tt := range tests {
+		program := parseProgram(t, tt.input)
+		stmt := singleExpressionStatement(t, program)
+
+		_, isBlock := stmt.Expression.(*ast.BlockExpression)
+		if isBlock != tt.isBlock {
+			t.Errorf("%s: wrong kind of expression. got=%T", tt.input, stmt.Expression)
+		}
+	}
+}
+
+func TestSpreadElements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[...a, 4]", "[...a, 4]"},
+		{"[1, ...f(x), ...[2]]", "[1, ...f(x), ...[2]]"},
+		{"[...a + b]", "[...(a + b)]"},
+		{`{...base, "x": 1}`, "{...base, x: 1}"},
+		{`{"x": 1, ...base,}`, "{x: 1, ...base}"},
+		{"{...a}", "{...a}"},
+		{"f(1, ...a, ...g(b))", "f(1, ...a, ...g(b))"},
+	}
+
+	for _, tt := range tests {
+		program := parseProgram(t, tt.input)
+		stmt := singleExpressionStatement(t, program)
+		if stmt.String() != tt.expected {
+			t.Errorf("%s: wrong result. expected=%q, got=%q", tt.input, tt.expected, stmt.String())
+		}
+	}
+
+	hash, ok := singleExpressionStatement(t, parseProgram(t, "{...a}")).Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("{...a} is not a hash literal")
+	}
+	if _, ok := hash.Pairs[0].Key.(*ast.SpreadElement); !ok || hash.Pairs[0].Value != nil {
+		t.Errorf("wrong spread entry. got=%+v", hash.Pairs[0])
+	}
+}
+
+func TestPipeExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		piped    string
+	}{
+		{"x |> f(_, 2)", "(x |> f(_, 2))", "f(_, 2)"},
+		{"x |> f(2)", "(x |> f(2))", "f(_, 2)"},
+		{"x |> f", "(x |> f)", "f(_)"},
+		{"a + 1 |> f(_, 2)", "((a + 1) |> f(_, 2))", "f(_, 2)"},
+		{"1 |> f(2 |> g(_, 3), _)", "(1 |> f((2 |> g(_, 3)), _))", "f((2 |> g(_, 3)), _)"},
+		{"x |> f |> g(1)", "((x |> f) |> g(1))", "g(_, 1)"},
+		{"y = x |> f", "(y = (x |> f))", ""},
+		{"x |> a.b(1)", "(x |> (a.b)(1))", "(a.b)(_, 1)"},
+	}
+
+	for _, tt := range tests {
+		program := parseProgram(t, tt.input)
+		stmt := singleExpressionStatement(t, program)
+		if stmt.String() != tt.expected {
+			t.Errorf("%s: wrong result. expected=%q, got=%q", tt.input, tt.expected, stmt.String())
+		}
+		if pipe, ok := stmt.Expression.(*ast.PipeExpression); ok {
+			if got := pipe.Piped().String(); got != tt.piped {
+				t.Errorf("%s: wrong piped call. expected=%q, got=%q", tt.input, tt.piped, got)
+			}
+		}
+	}
+
+	// a placeholder only stands for the value as an argument of the call
+	nested := []string{
+		"x |> f(g(_))",
+		"x |> f(_ * 2)",
+		"x |> f([_])",
+		"x |> f(fn() { _ })",
+		"x |> _",
+		"x |> _(1)",
+		"x |> f(_ |> g)",
+	}
+	for _, input := range nested {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0] != "`_` must be an argument of the call after |>, not inside one" {
+			t.Errorf("%s: wrong errors. got=%q", input, errors)
+		}
+	}
+}
+
+func TestOptionalIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a?.b", "(a?.b)"},
+		{"a?.b?.c", "((a?.b)?.c)"},
+		{"a?.0", "(a?.0)"},
+		{"a?.b[1] + 1", "(((a?.b)[1]) + 1)"},
+		{"f(x)?.y.z()", "((f(x)?.y).z)()"},
+	}
+
+	for _, tt := range tests {
+		program := parseProgram(t, tt.input)
+		stmt := singleExpressionStatement(t, program)
+		if stmt.String() != tt.expected {
+			t.Errorf("%s: wrong result. expected=%q, got=%q", tt.input, tt.expected, stmt.String())
+		}
+	}
+
+	exp, ok := singleExpressionStatement(t, parseProgram(t, "a?.b")).Expression.(*ast.OptionalIndexExpression)
+	if !ok {
+		t.Fatalf("a?.b is not an optional index expression")
+	}
+	if key, ok := exp.Index.(*ast.StringLiteral); !ok || key.Value != "b" {
+		t.Errorf("wrong index. got=%#v", exp.Index)
+	}
+
+	l := lexer.New("a?.(b)")
+	p := New(l)
+	p.ParseProgram()
+	if errors := p.Errors(); len(errors) == 0 || errors[0] != "expected a name or an index after ?., got ( instead" {
+		t.Errorf("wrong errors. got=%q", errors)
+	}
+}
+
+func TestWhileExpression(t *testing.T) {
+	program := parseProgram(t, `while (x < 10) { x = x + 1 }`)
+	stmt := singleExpressionStatement(t, program)
+
+	exp, ok := stmt.Expression.(*ast.WhileExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.WhileExpression. got=%T", stmt.Expression)
+	}
+	if !testInfixExpression(t, exp.Condition, "x", "<", 10) {
+		return
+	}
+	if len(exp.Body.Statements) != 1 {
+		t.Fatalf("body is not 1 statement. got=%d", len(exp.Body.Statements))
+	}
+	body, ok := exp.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Statements[0] is not ast.ExpressionStatement. got=%T", exp.Body.Statements[0])
+	}
+	assign, ok := body.Expression.(*ast.AssignExpression)
+	if !ok {
+		t.Fatalf("body.Expression is not ast.AssignExpression. got=%T", body.Expression)
+	}
+	testIdentifier(t, assign.Name, "x")
+	testInfixExpression(t, assign.Value, "x", "+", 1)
+}
+
+func TestDoWhileExpression(t *testing.T) {
+	program := parseProgram(t, `do { x = x + 1 } while (x < 10);`)
+	stmt := singleExpressionStatement(t, program)
+
+	exp, ok := stmt.Expression.(*ast.DoWhileExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.DoWhileExpression. got=%T", stmt.Expression)
+	}
+	if len(exp.Body.Statements) != 1 {
+		t.Fatalf("body is not 1 statement. got=%d", len(exp.Body.Statements))
+	}
+	body, ok := exp.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Statements[0] is not ast.ExpressionStatement. got=%T", exp.Body.Statements[0])
+	}
+	if _, ok := body.Expression.(*ast.AssignExpression); !ok {
+		t.Fatalf("body.Expression is not ast.AssignExpression. got=%T", body.Expression)
+	}
+	testInfixExpression(t, exp.Condition, "x", "<", 10)
+
+	if exp.String() != "do (x = (x + 1)) while(x < 10)" {
+		t.Errorf("exp.String() wrong. got=%q", exp.String())
+	}
+}
+
+func TestForExpression(t *testing.T) {
+	program := parseProgram(t, `for (x in range(10)) { puts(x) }`)
+	stmt := singleExpressionStatement(t, program)
+
+	exp, ok := stmt.Expression.(*ast.ForExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.ForExpression. got=%T", stmt.Expression)
+	}
+	testIdentifier(t, exp.Variable, "x")
+	if exp.Iterable.String() != "range(10)" {
+		t.Errorf("wrong iterable. got=%s", exp.Iterable)
+	}
+	if len(exp.Body.Statements) != 1 {
+		t.Fatalf("body is not 1 statement. got=%d", len(exp.Body.Statements))
+	}
+
+	p := New(lexer.New(`for (x, xs) { x }`))
+	p.ParseProgram()
+	if errors := p.Errors(); len(errors) == 0 || errors[0] != "expected next token to be IN, got , instead" {
+		t.Errorf("wrong errors. got=%q", errors)
+	}
+}
+
+func TestLoopControl(t *testing.T) {
+	program := parseProgram(t, `while (true) { break; continue }`)
+	stmt := singleExpressionStatement(t, program)
+	body := stmt.Expression.(*ast.WhileExpression).Body
+	if len(body.Statements) != 2 {
+		t.Fatalf("body is not 2 statements. got=%d", len(body.Statements))
+	}
+	if _, ok := body.Statements[0].(*ast.BreakStatement); !ok {
+		t.Errorf("Statements[0] is not ast.BreakStatement. got=%T", body.Statements[0])
+	}
+	if _, ok := body.Statements[1].(*ast.ContinueStatement); !ok {
+		t.Errorf("Statements[1] is not ast.ContinueStatement. got=%T", body.Statements[1])
+	}
+	if program.String() != "whiletrue break;continue;" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+
+	for _, input := range []string{
+		`do { if (x) { break } } while (true)`,
+		`for (x in xs) { if (x) { continue } else { 1 } }`,
+	} {
+		parseProgram(t, input)
+	}
+
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{`break;`, "break outside of a loop"},
+		{`if (x) { continue; }`, "continue outside of a loop"},
+		{`while (true) { fn() { break; } }`, "break outside of a loop"},
+		{`let break = 1;`, "cannot use keyword 'break' as identifier"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if errors := p.Errors(); len(errors) == 0 || errors[0] != tt.err {
+			t.Errorf("%s: wrong errors. want %q first, got=%q", tt.input, tt.err, errors)
+		}
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.5;", 1.5},
+		{".25;", 0.25},
+		{"1_000.5;", 1000.5},
+		{"2e3;", 2000},
+		{"3f;", 3},
+	}
+
+	for i, tt := range tests {
+		stmt := singleExpressionStatement(t, parseProgram(t, tt.input))
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("tests[%d] - exp not *ast.FloatLiteral. got=%T", i, stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("tests[%d] - literal.Value not %g. got=%g", i, tt.expected, literal.Value)
+		}
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	program := parseProgram(t, `match x { 1 => "one", 1 + 1 => "two", _ => "many", }`)
+	stmt := singleExpressionStatement(t, program)
+
+	exp, ok := stmt.Expression.(*ast.MatchExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MatchExpression. got=%T", stmt.Expression)
+	}
+	testIdentifier(t, exp.Subject, "x")
+	if len(exp.Arms) != 3 {
+		t.Fatalf("match doesn't have 3 arms. got=%d", len(exp.Arms))
+	}
+
+	testIntegerLiteral(t, exp.Arms[0].Pattern, 1)
+	testInfixExpression(t, exp.Arms[1].Pattern, 1, "+", 1)
+	if exp.Arms[2].Pattern != nil {
+		t.Errorf("wildcard arm has a pattern. got=%s", exp.Arms[2].Pattern)
+	}
+
+	for i, value := range []string{"one", "two", "many"} {
+		str, ok := exp.Arms[i].Value.(*ast.StringLiteral)
+		if !ok || str.Value != value {
+			t.Errorf("arm %d has wrong value. got=%s", i, exp.Arms[i].Value)
+		}
+	}
+
+	expected := `match x { 1 => one, (1 + 1) => two, _ => many }`
+	if exp.String() != expected {
+		t.Errorf("wrong String(). expected=%q, got=%q", expected, exp.String())
+	}
+}
+
+func TestMatchExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`match x { }`, "match without arms"},
+		{`match x { 1 "one" }`, "expected next token to be =>, got STRING instead"},
+		{`match x { 1 => 2 3 => 4 }`, "expected next token to be ,, got INT instead"},
+	}
+
+	for i, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if errors := p.Errors(); len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("tests[%d] - wrong errors. expected first=%q, got=%q", i, tt.expected, errors)
+		}
+	}
+}
+
+func TestIndexAssignment(t *testing.T) {
+	program := parseProgram(t, `a[i + 1] = 5`)
+	stmt := singleExpressionStatement(t, program)
+
+	assign, ok := stmt.Expression.(*ast.AssignExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.AssignExpression. got=%T", stmt.Expression)
+	}
+	if assign.Name != nil || assign.Index == nil {
+		t.Fatalf("expected an index target. got Name=%v", assign.Name)
+	}
+	testIdentifier(t, assign.Index.Left, "a")
+	testInfixExpression(t, assign.Index.Index, "i", "+", 1)
+	testIntegerLiteral(t, assign.Value, 5)
+
+	if program.String() != "((a[(i + 1)]) = 5)" {
+		t.Errorf("wrong String(). got=%q", program.String())
+	}
+}
+
+func TestInvalidAssignmentTarget(t *testing.T) {
+	p := New(lexer.New(`1 = 2`))
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) == 0 || errors[0] != "cannot assign to 1" {
+		t.Fatalf("wrong errors. got=%q", errors)
+	}
+}
+
+func TestAssignmentInConditionLint(t *testing.T) {
+	tests := []struct {
+		input    string
+		warnings []string
+	}{
+		{`if (x = 5) { x }`, []string{"1:5: assignment used as if condition, did you mean ==?"}},
+		{`while (y = f()) { y }`, []string{"1:8: assignment used as while condition, did you mean ==?"}},
+		{`if (x == 5) { x }`, []string{}},
+		{`if (f((x = 5))) { x }`, []string{}},
+		{`if (f(x = 5)) { x }`, []string{}},
+		{`x = 5; if (x) { x = 6 }`, []string{}},
+	}
+
+	Lint = true
+	defer func() { Lint = false }()
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		warnings := p.Warnings()
+		if len(warnings) != len(tt.warnings) {
+			t.Errorf("%s: wrong number of warnings. want=%d, got=%q", tt.input, len(tt.warnings), warnings)
+			continue
+		}
+		for i, want := range tt.warnings {
+			if warnings[i] != want {
+				t.Errorf("%s: warnings[%d] wrong. want=%q, got=%q", tt.input, i, want, warnings[i])
+			}
+		}
+
+		Lint = false
+		plain := parseProgram(t, tt.input)
+		Lint = true
+		if plain.String() != program.String() {
+			t.Errorf("%s: lint changed the parse result. want=%q, got=%q", tt.input, plain.String(), program.String())
+		}
+	}
+}
+
+func TestWarningsAreSeparateFromErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		errors   int
+		warnings int
+	}{
+		{`let x = 1; if (x = 2) { x }`, 0, 1},
+		{`if (x == 2) { x }; (1`, 1, 0},
+		{`if (x = 2) { x }; (1`, 1, 1},
+		{`let x = 1; x`, 0, 0},
+	}
+
+	Lint = true
+	defer func() { Lint = false }()
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) != tt.errors {
+			t.Errorf("%s: wrong number of errors. want=%d, got=%q", tt.input, tt.errors, p.Errors())
+		}
+		if len(p.Warnings()) != tt.warnings {
+			t.Errorf("%s: wrong number of warnings. want=%d, got=%q", tt.input, tt.warnings, p.Warnings())
+		}
+	}
+}
+
+func TestLintIsOffByDefault(t *testing.T) {
+	p := New(lexer.New(`if (x = 5) { x }`))
+	p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(p.Warnings()) != 0 {
+		t.Errorf("expected no warnings without Lint. got=%q", p.Warnings())
+	}
+}
+
+func TestTemplateLiteralParsing(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParts  []string
+		expectedValues []string
+	}{
+		{`"Hello ${name}!"`, []string{"Hello ", "!"}, []string{"name"}},
+		{`"${a}${b}"`, []string{"", "", ""}, []string{"a", "b"}},
+		{`"sum: ${1 + 2 * 3}"`, []string{"sum: ", ""}, []string{"(1 + (2 * 3))"}},
+		{`"${f("}")} and ${ {"a": 1}["a"] }"`, []string{"", " and ", ""}, []string{"f(})", "({a: 1}[a])"}},
+	}
+
+	for _, tt := range tests {
+		program := parseProgram(t, tt.input)
+		stmt := singleExpressionStatement(t, program)
+
+		lit, ok := stmt.Expression.(*ast.TemplateLiteral)
+		if !ok {
+			t.Fatalf("%s: exp is not ast.TemplateLiteral. got=%T", tt.input, stmt.Expression)
+		}
+		if len(lit.Parts) != len(tt.expectedParts) {
+			t.Fatalf("%s: wrong parts. want=%q, got=%q", tt.input, tt.expectedParts, lit.Parts)
+		}
+		for i, part := range tt.expectedParts {
+			if lit.Parts[i] != part {
+				t.Errorf("%s: parts[%d] wrong. want=%q, got=%q", tt.input, i, part, lit.Parts[i])
+			}
+		}
+		if len(lit.Values) != len(tt.expectedValues) {
+			t.Fatalf("%s: wrong number of values. want=%d, got=%d", tt.input, len(tt.expectedValues), len(lit.Values))
+		}
+		for i, value := range tt.expectedValues {
+			if lit.Values[i].String() != value {
+				t.Errorf("%s: values[%d] wrong. want=%q, got=%q", tt.input, i, value, lit.Values[i].String())
+			}
+		}
+	}
+}
+
+func TestTemplateValuePositions(t *testing.T) {
+	program := parseProgram(t, "let s = \"a\n ${x + y}\";")
+	stmt := program.Statements[0].(*ast.LetStatement)
+	lit := stmt.Value.(*ast.TemplateLiteral)
+	infix := lit.Values[0].(*ast.InfixExpression)
+
+	expected := token.Position{Offset: 14, Line: 2, Column: 4}
+	if infix.Left.Pos() != expected {
+		t.Errorf("position of x wrong. want=%+v, got=%+v", expected, infix.Left.Pos())
+	}
+}
+
+func TestTemplateLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a ${} b"`, "empty interpolation ${} in string"},
+		{`"${1 2}"`, "expected next token to be }, got INT instead"},
+		{`"${x`, "expected next token to be }, got EOF instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("%s: wrong errors. want=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestTaggedTemplateParsing(t *testing.T) {
+	program := parseProgram(t, `tag"Hello ${name}"`)
+	stmt := singleExpressionStatement(t, program)
+
+	call, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T", stmt.Expression)
+	}
+	if !testIdentifier(t, call.Function, "tag") {
+		return
+	}
+	if len(call.Arguments) != 2 {
+		t.Fatalf("wrong number of arguments. want=2, got=%d", len(call.Arguments))
+	}
+
+	parts, ok := call.Arguments[0].(*ast.ArrayLiteral)
+	if !ok || len(parts.Elements) != 2 {
+		t.Fatalf("parts is not an array of 2 elements. got=%s", call.Arguments[0])
+	}
+	for i, want := range []string{"Hello ", ""} {
+		str, ok := parts.Elements[i].(*ast.StringLiteral)
+		if !ok || str.Value != want {
+			t.Errorf("parts[%d] wrong. want=%q, got=%s", i, want, parts.Elements[i])
+		}
+	}
+
+	values, ok := call.Arguments[1].(*ast.ArrayLiteral)
+	if !ok || len(values.Elements) != 1 {
+		t.Fatalf("values is not an array of 1 element. got=%s", call.Arguments[1])
+	}
+	testIdentifier(t, values.Elements[0], "name")
+}
+
+func TestTagMustTouchTemplate(t *testing.T) {
+	p := New(lexer.New(`tag "Hello ${name}"`))
+	program := p.ParseProgram()
+	if errors := p.Errors(); len(errors) != 1 || errors[0] != "expected ; between statements on the same line, got TEMPLATE instead" {
+		t.Errorf("wrong errors. got=%q", errors)
+	}
+	if len(program.Statements) != 2 {
+		t.Fatalf("expected the identifier and the template as 2 statements. got=%d", len(program.Statements))
+	}
+	if _, ok := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.TemplateLiteral); !ok {
+		t.Errorf("second statement is not a template. got=%s", program.Statements[1])
+	}
+}
+
+// parseProgram parses input and fails the test if the parser reported errors.
+func parseProgram(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	return program
+}
+
+func singleExpressionStatement(t *testing.T, program *ast.Program) *ast.ExpressionStatement {
+	t.Helper()
+	if len(program.Statements) != 1 {
+		t.Fatalf("program has not enough statements. got=%d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+	return stmt
+}
+
+func checkParserErrors(t *testing.T, p *Parser) {
+	t.Helper()
+	errors := p.Errors()
+	if len(errors) == 0 {
+		return
+	}
+	t.Errorf("parser has %d errors", len(errors))
+	for _, msg := range errors {
+		t.Errorf("parser error: %q", msg)
+	}
+	t.FailNow()
+}
+
+func testLetStatement(t *testing.T, s ast.Statement, name string) bool {
+	t.Helper()
+	if s.TokenLiteral() != "let" {
+		t.Errorf("s.TokenLiteral not 'let'. got=%q", s.TokenLiteral())
+		return false
+	}
+	letStmt, ok := s.(*ast.LetStatement)
+	if !ok {
+		t.Errorf("s not *ast.LetStatement. got=%T", s)
+		return false
+	}
+	if letStmt.Name.Value != name {
+		t.Errorf("letStmt.Name.Value not '%s'. got=%s", name, letStmt.Name.Value)
+		return false
+	}
+	if letStmt.Name.TokenLiteral() != name {
+		t.Errorf("letStmt.Name.TokenLiteral() not '%s'. got=%s", name, letStmt.Name.TokenLiteral())
+		return false
+	}
+	return true
+}
+
+func testIntegerLiteral(t *testing.T, il ast.Expression, value int64) bool {
+	t.Helper()
+	integ, ok := il.(*ast.IntegerLiteral)
+	if !ok {
+		t.Errorf("il not *ast.IntegerLiteral. got=%T", il)
+		return false
+	}
+	if integ.Value != value {
+		t.Errorf("integ.Value not %d. got=%d", value, integ.Value)
+		return false
+	}
+	if integ.TokenLiteral() != fmt.Sprintf("%d", value) {
+		t.Errorf("integ.TokenLiteral not %d. got=%s", value, integ.TokenLiteral())
+		return false
+	}
+	return true
+}
+
+func testIdentifier(t *testing.T, exp ast.Expression, value string) bool {
+	t.Helper()
+	ident, ok := exp.(*ast.Identifier)
+	if !ok {
+		t.Errorf("exp not *ast.Identifier. got=%T", exp)
+		return false
+	}
+	if ident.Value != value {
+		t.Errorf("ident.Value not %s. got=%s", value, ident.Value)
+		return false
+	}
+	if ident.TokenLiteral() != value {
+		t.Errorf("ident.TokenLiteral not %s. got=%s", value, ident.TokenLiteral())
+		return false
+	}
+	return true
+}
+
+func testBooleanLiteral(t *testing.T, exp ast.Expression, value bool) bool {
+	t.Helper()
+	bo, ok := exp.(*ast.Boolean)
+	if !ok {
+		t.Errorf("exp not *ast.Boolean. got=%T", exp)
+		return false
+	}
+	if bo.Value != value {
+		t.Errorf("bo.Value not %t. got=%t", value, bo.Value)
+		return false
+	}
+	return true
+}
+
+func testLiteralExpression(t *testing.T, exp ast.Expression, expected interface{}) bool {
+	t.Helper()
+	switch v := expected.(type) {
+	case int:
+		return testIntegerLiteral(t, exp, int64(v))
+	case int64:
+		return testIntegerLiteral(t, exp, v)
+	case string:
+		return testIdentifier(t, exp, v)
+	case bool:
+		return testBooleanLiteral(t, exp, v)
+	}
+	t.Errorf("type of exp not handled. got=%T", exp)
+	return false
+}
+
+func testInfixExpression(t *testing.T, exp ast.Expression, left interface{}, operator string, right interface{}) bool {
+	t.Helper()
+	opExp, ok := exp.(*ast.InfixExpression)
+	if !ok {
+		t.Errorf("exp is not ast.InfixExpression. got=%T(%s)", exp, exp)
+		return false
+	}
+	if !testLiteralExpression(t, opExp.Left, left) {
+		return false
+	}
+	if opExp.Operator != operator {
+		t.Errorf("exp.Operator is not '%s'. got=%q", operator, opExp.Operator)
+		return false
+	}
+	if !testLiteralExpression(t, opExp.Right, right) {
+		return false
+	}
+	return true
+}
+
+func TestIsComplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let x = 5;", true},
+		{"", true},
+		{"let add = fn(a, b) {", false},
+		{"let add = fn(a, b) {\n  a + b\n", false},
+		{"let add = fn(a, b) {\n  a + b\n};", true},
+		{"[1, 2,", false},
+		{"puts((1 + 2)", false},
+		{"{\"a\": [1]}", true},
+		{`let s = "abc`, false},
+		{`let s = "abc"`, true},
+		{`"${ {`, false},
+		{"let x = (1];", true},
+		{"}", true},
+		{"let = 1;", true},
+		{"let s = <<<END", false},
+		{"let s = <<<END\nsome text\n", false},
+		{"let s = <<<END\nsome text\nEND", true},
+		{"let s = <<<END x\n", true},
+	}
+
+	for i, tt := range tests {
+		if got := IsComplete(tt.input); got != tt.expected {
+			t.Errorf("tests[%d] - IsComplete(%q) wrong. expected=%t, got=%t", i, tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		// missing names and operands
+		{`let = 5; let y = 1;`, []string{"expected next token to be IDENT, got = instead"}},
+		{`let x 5;`, []string{"expected next token to be =, got INT instead"}},
+		{`return );`, []string{"no prefix parse function for ) found"}},
+		{`let x = 1 +; let y = ;`, []string{
+			"no prefix parse function for ; found",
+			"no prefix parse function for ; found",
+		}},
+		// missing closing brackets and separators
+		{`let x = (1 + 2; let y = 3;`, []string{"expected next token to be ), got ; instead"}},
+		{`let a = [1, 2; a`, []string{"expected next token to be ], got ; instead"}},
+		{`let y = add(1, 2 3);`, []string{"expected next token to be ), got INT instead"}},
+		{`fn(a b) { a }`, []string{"expected next token to be ), got IDENT instead"}},
+		{`{1: 2, 3}`, []string{"expected next token to be :, got } instead"}},
+		{`if x { 1 }`, []string{"expected next token to be (, got IDENT instead"}},
+		// mistakes inside blocks don't leak out of them
+		{`if (x) { 1 + }`, []string{"no prefix parse function for } found"}},
+		{`if (x) { let = 1; 2 }`, []string{"expected next token to be IDENT, got = instead"}},
+		{`let f = fn() { if (a) { 1 + } }; f();`, []string{"no prefix parse function for } found"}},
+		{`let a = fn(x) { x + }; let b = 2; b`, []string{"no prefix parse function for } found"}},
+		{`let fn = 1; let y = 2;`, []string{"cannot use keyword 'fn' as identifier"}},
+		{`let [a, = x; let y = 2;`, []string{"expected a name or a pattern to bind, got = instead"}},
+	}
+
+	for i, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		// the tree of a program with errors is still safe for tools to use
+		for _, stmt := range program.Statements {
+			if stmt == nil || reflect.ValueOf(stmt).IsNil() {
+				t.Errorf("tests[%d] - nil statement in the program for %q", i, tt.input)
+			}
+		}
+		_ = program.String()
+		ast.Fingerprint(program)
+		ast.Walk(program, func(node ast.Node) bool {
+			_ = node.String()
+			return true
+		})
+		ast.BuildIndex(program).At(token.Position{Offset: len(tt.input) / 2})
+		errors := p.Errors()
+		if len(errors) != len(tt.expected) {
+			t.Errorf("tests[%d] - wrong number of errors for %q. expected=%q, got=%q", i, tt.input, tt.expected, errors)
+			continue
+		}
+		for j, msg := range tt.expected {
+			if errors[j] != msg {
+				t.Errorf("tests[%d] - wrong error %d. expected=%q, got=%q", i, j, msg, errors[j])
+			}
+		}
+	}
+}
+
+func TestKeywordAsIdentifier(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let fn = 1;`, "cannot use keyword 'fn' as identifier"},
+		{`fn(let) {}`, "cannot use keyword 'let' as identifier"},
+		{`fn(a, if) { a }`, "cannot use keyword 'if' as identifier"},
+		{`for (while in [1]) { 1 }`, "cannot use keyword 'while' as identifier"},
+		{`let [a, true] = [1, 2];`, "cannot use keyword 'true' as identifier"},
+		{`let {match} = {"match": 1};`, "cannot use keyword 'match' as identifier"},
+	}
+
+	for i, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("tests[%d] - wrong errors for %q. expected=%q, got=%q", i, tt.input, tt.expected, errors)
+		}
+	}
+
+	// names that merely start with a keyword are fine
+	program := parseProgram(t, `let fnord = fn(letter, iffy) { letter }; for (format in []) { 1 }`)
+	if len(program.Statements) != 2 {
+		t.Fatalf("program has wrong number of statements. got=%d", len(program.Statements))
+	}
+}
+
+func TestErrorRecoveryKeepsLaterStatements(t *testing.T) {
+	p := New(lexer.New(`let = 5; let y = 1; y`))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 1 {
+		t.Fatalf("expected 1 error. got=%q", p.Errors())
+	}
+
+	last := program.Statements[len(program.Statements)-1]
+	stmt, ok := last.(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("last statement is not ast.ExpressionStatement. got=%T", last)
+	}
+	testIdentifier(t, stmt.Expression, "y")
+}
+
+func TestReparse(t *testing.T) {
+	source := "let a = 1;\nlet b = 2;\nlet c = a + b;"
+
+	tests := []struct {
+		name       string
+		old        string
+		start, end int // the edited bytes of old
+		text       string
+		reused     []bool // whether each statement of the result is from the old tree
+	}{
+		{"same length", source, 19, 20, "5", []bool{true, false, true}},
+		{"longer", source, 19, 20, "20", []bool{true, false, false}},
+		{"first statement", source, 8, 9, "7", []bool{false, true, true}},
+		{"before the first statement", source, 0, 0, "x; ", []bool{false, false, false, false}},
+		{"new statement", source, 21, 21, " b", []bool{true, false, false, false}},
+		// without the semicolon, -b continues the statement before it
+		{"merging statements", "let a = 1;\n-b;", 9, 10, " ", []bool{false}},
+	}
+
+	for _, tt := range tests {
+		old := parseProgram(t, tt.old)
+		src := tt.old[:tt.start] + tt.text + tt.old[tt.end:]
+
+		program := Reparse(old, src, tt.start, tt.end)
+		expected := parseProgram(t, src)
+		if program.String() != expected.String() || program.End != expected.End {
+			t.Errorf("%s: wrong program. expected=%q, got=%q", tt.name, expected.String(), program.String())
+			continue
+		}
+		if len(program.Statements) != len(tt.reused) {
+			t.Errorf("%s: wrong number of statements. got=%d", tt.name, len(program.Statements))
+			continue
+		}
+		for i, stmt := range program.Statements {
+			if stmt.Pos() != expected.Statements[i].Pos() {
+				t.Errorf("%s: statement %d at wrong position. expected=%+v, got=%+v", tt.name, i, expected.Statements[i].Pos(), stmt.Pos())
+			}
+			if reused := containsStatement(old, stmt); reused != tt.reused[i] {
+				t.Errorf("%s: statement %d reused=%t, expected %t", tt.name, i, reused, tt.reused[i])
+			}
+		}
+	}
+}
+
+func TestReparseErrors(t *testing.T) {
+	// each edit breaks a statement, which leaves the whole source to the parser
+	tests := []struct {
+		old        string
+		start, end int
+		text       string
+	}{
+		{"let a = 1;\nlet b = 2;", 19, 20, ""},
+		{"let a = 1;\nlet b = 2; let c = 3;", 20, 21, " "},
+	}
+
+	for _, tt := range tests {
+		old := parseProgram(t, tt.old)
+		src := tt.old[:tt.start] + tt.text + tt.old[tt.end:]
+		program := Reparse(old, src, tt.start, tt.end)
+		if containsStatement(old, program.Statements[0]) {
+			t.Errorf("statement reused from %q, which failed to parse", src)
+		}
+	}
+}
+
+func TestReparseWithTrivia(t *testing.T) {
+	PreserveTrivia = true
+	defer func() { PreserveTrivia = false }()
+
+	oldSrc := "let a = 1;\nlet b = 2; // two\nlet c = 3;\n"
+	old := parseProgram(t, oldSrc)
+	src := "let a = 1;\nlet b = 7; // two\nlet c = 3;\n"
+
+	program := Reparse(old, src, 19, 20)
+	if got := program.Source(); got != src {
+		t.Errorf("wrong source after reparsing. expected=%q, got=%q", src, got)
+	}
+	if expected := parseProgram(t, src).String(); program.String() != expected {
+		t.Errorf("wrong program. expected=%q, got=%q", expected, program.String())
+	}
+}
+
+func TestReparseWithCommentStatements(t *testing.T) {
+	CommentStatements = true
+	defer func() { CommentStatements = false }()
+
+	old := parseProgram(t, "let a = 1;\nlet b = 2;\nlet c = 3;\n")
+	src := "let a = 1;\n// b\nlet c = 3;\n"
+
+	program := Reparse(old, src, 11, 21)
+	expected := parseProgram(t, src)
+	if len(program.Statements) != len(expected.Statements) {
+		t.Fatalf("wrong number of statements. expected=%d, got=%d",
+			len(expected.Statements), len(program.Statements))
+	}
+	if _, ok := program.Statements[1].(*ast.CommentStatement); !ok {
+		t.Errorf("statement 1 is not *ast.CommentStatement. got=%T", program.Statements[1])
+	}
+}
+
+func containsStatement(program *ast.Program, stmt ast.Statement) bool {
+	for _, s := range program.Statements {
+		if s == stmt {
+			return true
+		}
+	}
+	return false
+}
+
+func TestMethodCallExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`a.len()`, `(a.len)()`},
+		{`"hi".upper().len()`, `((hi.upper)().len)()`},
+		{`a[0].f(b, c)`, `((a[0]).f)(b, c)`},
+		{`-a.f()`, `(-(a.f)())`},
+		{`a + b.f()`, `(a + (b.f)())`},
+	}
+
+	for i, tt := range tests {
+		program := parseProgram(t, tt.input)
+		if got := program.String(); got != tt.expected {
+			t.Errorf("tests[%d] - wrong parse. expected=%q, got=%q", i, tt.expected, got)
+		}
+	}
+
+	p := New(lexer.New(`a.(b)`))
+	p.ParseProgram()
+	if errors := p.Errors(); len(errors) == 0 || errors[0] != "expected next token to be IDENT, got ( instead" {
+		t.Errorf("wrong errors for a missing method name. got=%q", errors)
+	}
+}
+
+func TestNewlineTerminates(t *testing.T) {
+	NewlineTerminates = true
+	defer func() { NewlineTerminates = false }()
+
+	tests := []struct {
+		withNewlines   string
+		withSemicolons string
+	}{
+		{"let a = 1\nlet b = a\n\n\na + b\n", "let a = 1; let b = a; a + b;"},
+		{"x\n-1", "x; -1;"},
+		{"f\n(1)", "f; (1);"},
+		{"let f = fn(a) {\n  let b = a * 2\n  b\n}\nf(1)", "let f = fn(a) { let b = a * 2; b; }; f(1);"},
+		{"if (a) {\n  b\n}\nelse {\n  c\n}", "if (a) { b; } else { c; }"},
+		// line breaks inside parentheses and brackets don't end anything
+		{"add(1,\n  2)\n[1,\n  2]", "add(1, 2); [1, 2];"},
+		{"(1\n+ 2)", "(1 + 2);"},
+		{"f(fn() {\n  a\n  -b\n})", "f(fn() { a; -b; });"},
+	}
+
+	for i, tt := range tests {
+		withNewlines := parseProgram(t, tt.withNewlines)
+		withSemicolons := parseProgram(t, tt.withSemicolons)
+		if withNewlines.String() != withSemicolons.String() ||
+			len(withNewlines.Statements) != len(withSemicolons.Statements) {
+			t.Errorf("tests[%d] - wrong program. expected=%q, got=%q", i, withSemicolons.String(), withNewlines.String())
+		}
+	}
+}
+
+func TestNewlinesDontTerminateByDefault(t *testing.T) {
+	program := parseProgram(t, "x\n-1")
+	if len(program.Statements) != 1 || program.String() != "(x - 1)" {
+		t.Errorf("expected a single statement. got=%q", program.String())
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a\tb\n"`, "a\tb\n"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"\${x}"`, "${x}"},
+		{"`raw \\n ${x}`", `raw \n ${x}`},
+		{"`two\nlines`", "two\nlines"},
+	}
+
+	for i, tt := range tests {
+		stmt := singleExpressionStatement(t, parseProgram(t, tt.input))
+		str, ok := stmt.Expression.(*ast.StringLiteral)
+		if !ok {
+			t.Fatalf("tests[%d] - exp not *ast.StringLiteral. got=%T", i, stmt.Expression)
+		}
+		if str.Value != tt.expected {
+			t.Errorf("tests[%d] - wrong value. expected=%q, got=%q", i, tt.expected, str.Value)
+		}
+	}
+
+	// the parts of a template are unescaped, an escaped $ isn't interpolated
+	stmt := singleExpressionStatement(t, parseProgram(t, `"\t${a}\${b}"`))
+	tl, ok := stmt.Expression.(*ast.TemplateLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.TemplateLiteral. got=%T", stmt.Expression)
+	}
+	if len(tl.Parts) != 2 || tl.Parts[0] != "\t" || tl.Parts[1] != "${b}" || len(tl.Values) != 1 {
+		t.Errorf("wrong template. parts=%q, values=%d", tl.Parts, len(tl.Values))
+	}
+
+	p := New(lexer.New(`"bad \q"`))
+	p.ParseProgram()
+	if errors := p.Errors(); len(errors) != 1 || errors[0] != `invalid string "bad \\q": unknown escape sequence \q` {
+		t.Errorf("wrong errors. got=%q", errors)
+	}
+}
@@ -0,0 +1,73 @@
+package parser
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/token"
+)
+
+// Reparse parses src, the source of old after an edit replaced the bytes
+// from editStart up to editEnd of the old source, and reuses the statements
+// of old the edit can't have changed. Reused statements are the very same
+// nodes as in old, so callers can tell what changed by comparing pointers.
+//
+// The source of a statement spans from its first token to the first token
+// of the next one. Statements ending before the edit are always reused.
+// Parsing resumes at the first statement touched by the edit and goes on
+// until it reaches an old statement after the edit again, from where old is
+// reused to the end. That only works if the edit kept the length of the
+// source: otherwise the positions of the later statements would be off, and
+// the rest of src is parsed anew.
+//
+// If anything fails to parse, src is parsed from scratch, so the result is
+// always the tree ParseProgram would build. So it is with PreserveTrivia
+// and CommentStatements, since the comments and trivia of a statement depend
+// on the ones around it.
+func Reparse(old *ast.Program, src string, editStart, editEnd int) *ast.Program {
+	n := len(old.Statements)
+	if PreserveTrivia || CommentStatements || n == 0 || editStart < 0 || editStart > editEnd || editEnd > old.End {
+		return New(lexer.New(src)).ParseProgram()
+	}
+
+	// the first statement whose source reaches the edit; text before the
+	// first statement belongs to it
+	first := 0
+	for first+1 < n && old.Statements[first+1].Pos().Offset < editStart {
+		first++
+	}
+	start := token.Position{Line: 1, Column: 1}
+	if first > 0 {
+		start = old.Statements[first].Pos()
+	}
+
+	// the old statements the parse can stop at, by offset
+	resume := map[int]int{}
+	if len(src) == old.End {
+		for i := first + 1; i < n; i++ {
+			if offset := old.Statements[i].Pos().Offset; offset >= editEnd {
+				resume[offset] = i
+			}
+		}
+	}
+
+	program := &ast.Program{Statements: append([]ast.Statement{}, old.Statements[:first]...)}
+	p := New(lexer.NewAt(src[start.Offset:], start))
+	for !p.curTokenIs(token.EOF) {
+		if i, ok := resume[p.curToken.Pos.Offset]; ok {
+			program.Statements = append(program.Statements, old.Statements[i:]...)
+			program.End = old.End
+			return program
+		}
+		stmt := p.parseStatement()
+		if len(p.errors) == 0 {
+			p.checkTerminator()
+		}
+		if len(p.errors) > 0 {
+			return New(lexer.New(src)).ParseProgram()
+		}
+		program.Statements = append(program.Statements, stmt)
+		p.nextToken()
+	}
+	program.End = p.curToken.Pos.Offset
+	return program
+}
@@ -0,0 +1,182 @@
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"os"
+	"strings"
+)
+
+const PROMPT = ">> "
+
+// CONTINUATION_PROMPT is shown while the input so far is incomplete, e.g. a
+// function whose closing brace hasn't been typed yet.
+const CONTINUATION_PROMPT = ".. "
+
+// Config controls how a REPL session looks.
+type Config struct {
+	Prompt             string // shown when a new input is expected
+	ContinuationPrompt string // shown while the input is incomplete
+	ShowTypes          bool   // print the type after each result, like `10 :: INTEGER`
+}
+
+// DefaultConfig returns the configuration of the standard REPL, with
+// PROMPT and CONTINUATION_PROMPT and no types.
+func DefaultConfig() Config {
+	return Config{Prompt: PROMPT, ContinuationPrompt: CONTINUATION_PROMPT}
+}
+
+// Start runs the read-eval-print loop: it reads a line from in, evaluates it
+// and writes the result to out until in is exhausted. All lines share one
+// environment, so bindings survive from one line to the next.
+//
+// Input that stops inside an open bracket or string (see parser.IsComplete)
+// is buffered, and more lines are read with the continuation prompt until it
+// is complete. A multiline function can be typed or pasted that way.
+//
+// Lines starting with ':' are REPL commands instead of Monkey code:
+//
+//	:load <path>  evaluates the file at path in the current environment
+//	:type <expr>  prints the type of the value of expr, leaving the bindings as they were
+func Start(in io.Reader, out io.Writer, config Config) {
+	reader := bufio.NewReader(in)
+	env := object.NewEnvironment()
+
+	// readLine() in a program reads the next line the user types
+	evaluator.SetInput(reader)
+
+	pending := ""
+	for {
+		if pending == "" {
+			fmt.Fprint(out, config.Prompt)
+		} else {
+			fmt.Fprint(out, config.ContinuationPrompt)
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
+			if pending != "" {
+				// the input ended early, show why it doesn't parse
+				evalLine(pending, env, out, config)
+			}
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		if pending == "" && strings.HasPrefix(strings.TrimSpace(line), ":") {
+			runCommand(strings.TrimSpace(line), env, out)
+			continue
+		}
+
+		src := pending + line
+		if !parser.IsComplete(src) {
+			pending = src + "\n"
+			continue
+		}
+		pending = ""
+		evalLine(src, env, out, config)
+	}
+}
+
+// evalLine evaluates the source of one complete input and prints the result.
+// Errors are never annotated with their type, they say what they are.
+func evalLine(src string, env *object.Environment, out io.Writer, config Config) {
+	evaluated, ok := evalSource(src, env, out)
+	if ok && evaluated != nil {
+		io.WriteString(out, evaluated.Inspect())
+		if config.ShowTypes && !isError(evaluated) {
+			io.WriteString(out, " :: "+string(evaluated.Type()))
+		}
+		io.WriteString(out, "\n")
+	}
+}
+
+// evalSource parses and evaluates src in env. Parser errors are written to out
+// and reported by returning false, in which case nothing is evaluated.
+// Warnings are written to out as well, but don't stop the evaluation.
+func evalSource(src string, env *object.Environment, out io.Writer) (object.Object, bool) {
+	p := parser.New(lexer.New(src))
+
+	program := p.ParseProgram()
+	printParserWarnings(out, p.Warnings())
+	if len(p.Errors()) != 0 {
+		printParserErrors(out, p.Errors())
+		return nil, false
+	}
+	return evaluator.Eval(program, env), true
+}
+
+// runCommand executes a single REPL command line such as `:load defs.mk`.
+func runCommand(line string, env *object.Environment, out io.Writer) {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch name {
+	case ":load":
+		if arg == "" {
+			io.WriteString(out, "usage: :load <path>\n")
+			return
+		}
+		loadFile(arg, env, out)
+	case ":type":
+		if arg == "" {
+			io.WriteString(out, "usage: :type <expression>\n")
+			return
+		}
+		printType(arg, env, out)
+	default:
+		fmt.Fprintf(out, "unknown command: %s\n", name)
+	}
+}
+
+// loadFile evaluates the file at path in env, so its bindings become part of
+// the session. Nothing is printed on success, errors don't end the session.
+func loadFile(path string, env *object.Environment, out io.Writer) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(out, "could not load %s: %s\n", path, err)
+		return
+	}
+
+	evaluated, ok := evalSource(string(src), env, out)
+	if ok && isError(evaluated) {
+		io.WriteString(out, evaluated.Inspect())
+		io.WriteString(out, "\n")
+	}
+}
+
+// printType evaluates src in a copy of env and prints the type of the
+// result, or the error it gives. The copy is a deep one, see DeepCopy, so
+// nothing src binds, assigns or changes in place, even through a closure,
+// lasts beyond it. Effects outside of the program's values, like output or
+// written files, do happen.
+func printType(src string, env *object.Environment, out io.Writer) {
+	evaluated, ok := evalSource(src, env.DeepCopy(), out)
+	switch {
+	case !ok || evaluated == nil:
+	case isError(evaluated):
+		io.WriteString(out, evaluated.Inspect()+"\n")
+	default:
+		io.WriteString(out, string(evaluated.Type())+"\n")
+	}
+}
+
+func isError(obj object.Object) bool {
+	return obj != nil && obj.Type() == object.ERROR_OBJ
+}
+
+func printParserErrors(out io.Writer, errors []string) {
+	for _, msg := range errors {
+		io.WriteString(out, "\t"+msg+"\n")
+	}
+}
+
+func printParserWarnings(out io.Writer, warnings []string) {
+	for _, msg := range warnings {
+		io.WriteString(out, "\twarning: "+msg+"\n")
+	}
+}
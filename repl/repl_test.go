@@ -0,0 +1,240 @@
+package repl
+
+import (
+	"bytes"
+	"monkey/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// run feeds input to a REPL session and returns everything it printed.
+func run(input string) string {
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out, DefaultConfig())
+	return out.String()
+}
+
+// writeFile creates a file with the given contents in a temporary directory.
+func writeFile(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("could not write %s: %s", path, err)
+	}
+	return path
+}
+
+func TestEvaluatesLines(t *testing.T) {
+	output := run("let a = 5;\na * 2\n")
+	expected := PROMPT + PROMPT + "10\n" + PROMPT
+	if output != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, output)
+	}
+}
+
+func TestLoadCommand(t *testing.T) {
+	path := writeFile(t, "defs.mk", "let add = fn(a, b) { a + b };\nlet ten = 10;\n")
+
+	output := run(":load " + path + "\nadd(ten, 5)\n")
+	expected := PROMPT + PROMPT + "15\n" + PROMPT
+	if output != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, output)
+	}
+}
+
+func TestLoadCommandErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		expected string
+	}{
+		{"parse.mk", "let = 1;", "\texpected next token to be IDENT, got = instead\n"},
+		{"runtime.mk", "let a = 1; a + true;", "ERROR: type mismatch: INTEGER + BOOLEAN\n"},
+	}
+
+	for _, tt := range tests {
+		path := writeFile(t, tt.name, tt.contents)
+
+		// the session keeps going after the error
+		output := run(":load " + path + "\n1 + 1\n")
+		if !strings.Contains(output, tt.expected) {
+			t.Errorf("%s: output does not contain %q. got=%q", tt.name, tt.expected, output)
+		}
+		if !strings.HasSuffix(output, PROMPT+"2\n"+PROMPT) {
+			t.Errorf("%s: session did not continue after the error. got=%q", tt.name, output)
+		}
+	}
+}
+
+func TestLoadCommandMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.mk")
+
+	output := run(":load " + path + "\n")
+	if !strings.Contains(output, "could not load "+path) {
+		t.Errorf("missing file not reported. got=%q", output)
+	}
+}
+
+func TestWarningsDontStopEvaluation(t *testing.T) {
+	parser.Lint = true
+	defer func() { parser.Lint = false }()
+
+	output := run("let x = 1;\nif (x = 2) { x * 10 }\n")
+	expected := PROMPT + PROMPT +
+		"\twarning: 1:5: assignment used as if condition, did you mean ==?\n" +
+		"20\n" + PROMPT
+	if output != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, output)
+	}
+}
+
+func TestErrorsStopEvaluation(t *testing.T) {
+	parser.Lint = true
+	defer func() { parser.Lint = false }()
+
+	// the assignment would fail at runtime, x isn't declared
+	output := run("if (x = 2) { x }; 1 +;\n")
+	expected := PROMPT +
+		"\twarning: 1:5: assignment used as if condition, did you mean ==?\n" +
+		"\tno prefix parse function for ; found\n" + PROMPT
+	if output != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, output)
+	}
+}
+
+func TestMultilineInput(t *testing.T) {
+	output := run("let add = fn(a, b) {\n  a + b\n};\nadd(1, 2)\n")
+	expected := PROMPT + CONTINUATION_PROMPT + CONTINUATION_PROMPT + PROMPT + "3\n" + PROMPT
+	if output != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, output)
+	}
+}
+
+func TestMultilineInputEvaluatesOnce(t *testing.T) {
+	// nothing is printed until the braces close, then the result once
+	output := run("fn(a) {\n  a * 2\n}(21)\n")
+	expected := PROMPT + CONTINUATION_PROMPT + CONTINUATION_PROMPT + "42\n" + PROMPT
+	if output != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, output)
+	}
+}
+
+func TestSyntaxErrorIsReportedImmediately(t *testing.T) {
+	output := run("let = 1;\n1\n")
+	expected := PROMPT + "\texpected next token to be IDENT, got = instead\n" + PROMPT + "1\n" + PROMPT
+	if output != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, output)
+	}
+}
+
+func TestIncompleteInputAtEOF(t *testing.T) {
+	output := run("let f = fn() {\n")
+	if !strings.HasPrefix(output, PROMPT+CONTINUATION_PROMPT+"\t") {
+		t.Errorf("expected the parser errors after the input ended. got=%q", output)
+	}
+}
+
+func TestCustomPrompts(t *testing.T) {
+	config := Config{Prompt: "monkey> ", ContinuationPrompt: "......> "}
+
+	var out bytes.Buffer
+	Start(strings.NewReader("let f = fn() {\n1 };\nf()\n"), &out, config)
+	expected := "monkey> ......> monkey> 1\nmonkey> "
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestShowTypes(t *testing.T) {
+	config := DefaultConfig()
+	config.ShowTypes = true
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"10", "10 :: INTEGER"},
+		{`"hi"`, "hi :: STRING"},
+		{"true", "true :: BOOLEAN"},
+		{"[1, 2]", "[1, 2] :: ARRAY"},
+		{"if (false) { 1 }", "null :: NULL"},
+		{"1 + true", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		Start(strings.NewReader(tt.input+"\n"), &out, config)
+		expected := PROMPT + tt.expected + "\n" + PROMPT
+		if out.String() != expected {
+			t.Errorf("wrong output for %s. expected=%q, got=%q", tt.input, expected, out.String())
+		}
+	}
+}
+
+func TestTypeCommand(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{":type 1 + 2\n", "INTEGER\n"},
+		{":type \"x\"\n", "STRING\n"},
+		{":type fn(x) { x }\n", "FUNCTION\n"},
+		{":type 1 + true\n", "ERROR: type mismatch: INTEGER + BOOLEAN\n"},
+		{":type\n", "usage: :type <expression>\n"},
+	}
+
+	for _, tt := range tests {
+		output := run(tt.input)
+		expected := PROMPT + tt.expected + PROMPT
+		if output != expected {
+			t.Errorf("wrong output for %q. expected=%q, got=%q", tt.input, expected, output)
+		}
+	}
+}
+
+func TestTypeCommandHasNoLastingEffects(t *testing.T) {
+	input := strings.Join([]string{
+		"let x = 1;",
+		"let bump = fn() { x = x + 10; x };",
+		":type x = \"changed\"",
+		":type bump()",
+		":type let y = 5",
+		"x",
+		"y",
+	}, "\n") + "\n"
+
+	output := run(input)
+	expected := PROMPT + PROMPT + PROMPT + "STRING\n" + PROMPT + "INTEGER\n" + PROMPT +
+		PROMPT + "1\n" + PROMPT + "ERROR: identifier not found: y\n" + PROMPT
+	if output != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, output)
+	}
+
+	// changes in place and through closures are undone as well
+	tests := []struct {
+		lines    []string
+		expected string
+	}{
+		{[]string{
+			"let counter = fn() { let c = 0; fn() { c = c + 1 } };",
+			"let inc = counter();",
+			"inc();",
+			":type inc()",
+			":type inc()",
+			"inc()",
+		}, "2"},
+		{[]string{"let a = [1, 2];", ":type a[0] = 9", "a"}, "[1, 2]"},
+		{[]string{`let h = {"k": [1]};`, `:type h["k"][0] = 5`, `:type h["n"] = 1`, "h"}, "{k: [1]}"},
+		{[]string{"let q = pqNew();", `:type pqPush(q, 1, "a")`, "q"}, "priority queue (0 items)"},
+		{[]string{"let a = [1];", "let f = fn() { a };", ":type f()[0] = 7", "[a, f()]"}, "[[1], [1]]"},
+	}
+	for _, tt := range tests {
+		output := run(strings.Join(tt.lines, "\n") + "\n")
+		lines := strings.Split(strings.TrimSuffix(output, PROMPT), PROMPT)
+		if got := strings.TrimSpace(lines[len(lines)-1]); got != tt.expected {
+			t.Errorf("wrong result after %q. expected=%q, got=%q", tt.lines, tt.expected, output)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package token
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// TabWidth is the distance between tab stops. A tab moves the column to the
+// next stop, so with a width of 4 the character after it is at column 5, 9,
+// 13 and so on. The default of 1 counts a tab like any other character.
+var TabWidth = 1
+
+// ColumnAfter returns the column of the character following the byte ch,
+// when ch is at column. This is the column model the lexer, the parser and
+// OffsetToPosition share: every UTF-8 encoded rune takes up one column,
+// however many bytes it has, and a tab advances to the next tab stop. The
+// bytes continuing a multibyte rune don't take up a column of their own, so
+// a tab after one is measured from the rune's column.
+func ColumnAfter(column int, ch byte) int {
+	switch {
+	case ch == '\t' && TabWidth > 1:
+		return column + TabWidth - (column-1)%TabWidth
+	case utf8.RuneStart(ch):
+		return column + 1
+	default:
+		return column
+	}
+}
+
+// OffsetToPosition returns the position of the byte at offset in src, with
+// its line and column counted the way the lexer counts them, see ColumnAfter.
+// A line break belongs to the line it ends. The offset len(src) is the
+// position of EOF. Offsets out of range are moved to the nearest end.
+func OffsetToPosition(src string, offset int) Position {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(src) {
+		offset = len(src)
+	}
+
+	before := src[:offset]
+	column := 1
+	for i := strings.LastIndexByte(before, '\n') + 1; i < offset; i++ {
+		column = ColumnAfter(column, src[i])
+	}
+	return Position{
+		Offset: offset,
+		Line:   strings.Count(before, "\n") + 1,
+		Column: column,
+	}
+}
@@ -0,0 +1,39 @@
+package token
+
+import "testing"
+
+func TestOffsetToPosition(t *testing.T) {
+	src := "let x = 1;\nx\n\n  y"
+
+	tests := []struct {
+		offset   int
+		expected Position
+	}{
+		{0, Position{Offset: 0, Line: 1, Column: 1}},
+		{4, Position{Offset: 4, Line: 1, Column: 5}},
+		// the line break is the last byte of its line
+		{10, Position{Offset: 10, Line: 1, Column: 11}},
+		{11, Position{Offset: 11, Line: 2, Column: 1}},
+		{13, Position{Offset: 13, Line: 3, Column: 1}},
+		{16, Position{Offset: 16, Line: 4, Column: 3}},
+		// EOF, and offsets out of range
+		{17, Position{Offset: 17, Line: 4, Column: 4}},
+		{100, Position{Offset: 17, Line: 4, Column: 4}},
+		{-1, Position{Offset: 0, Line: 1, Column: 1}},
+	}
+
+	for i, tt := range tests {
+		if got := OffsetToPosition(src, tt.offset); got != tt.expected {
+			t.Errorf("tests[%d] - wrong position for offset %d. expected=%+v, got=%+v", i, tt.offset, tt.expected, got)
+		}
+	}
+
+	if got := OffsetToPosition("", 0); got != (Position{Offset: 0, Line: 1, Column: 1}) {
+		t.Errorf("wrong position in empty source. got=%+v", got)
+	}
+
+	// runes are one column wide, however many bytes they take
+	if got := OffsetToPosition("\"né\" x", 6); got != (Position{Offset: 6, Line: 1, Column: 6}) {
+		t.Errorf("wrong position after a multibyte rune. got=%+v", got)
+	}
+}
@@ -4,6 +4,25 @@ type TokenType string
 type Token struct {
 	Type TokenType
 	Literal string
+	Pos Position // where the token starts in the input
+	Suffix string // type suffix of a number, like the u of 10u. Not part of Literal
+}
+
+// Span returns the offsets the token's literal takes up in the input, so
+// input[start:end] is Literal without copying it. That holds for the tokens
+// whose literal is written as is, like identifiers, numbers, operators and
+// delimiters. The literal of a string is its text without the quotes, and
+// the suffix of a number isn't part of it.
+func (t Token) Span() (start, end int) {
+	return t.Pos.Offset, t.Pos.Offset + len(t.Literal)
+}
+
+// Position is a location in the input. Offset is the byte offset starting at 0,
+// Line and Column start at 1, see ColumnAfter for how columns are counted.
+type Position struct {
+	Offset int
+	Line int
+	Column int
 }
 
 const (
@@ -13,6 +32,16 @@ const (
 	// identifiers + literals
 	IDENT = "IDENT"
 	INT = "INT"
+	FLOAT = "FLOAT"
+	STRING = "STRING"
+	TEMPLATE = "TEMPLATE" // a string with ${...} interpolations
+	RAW_STRING = "RAW_STRING" // a string in backquotes, without escapes
+	REGEX = "REGEX" // a regular expression between slashes, like /ab+/
+
+	// trivia, only emitted by lexers asked for it
+	WHITESPACE = "WHITESPACE"
+	COMMENT = "COMMENT" // from // to the end of the line
+	NEWLINE = "NEWLINE" // a line break, for parsers ending statements there
 
 	// operators
 	ASSIGN = "="
@@ -25,15 +54,43 @@ const (
 	GT = ">"
 	EQ = "=="
 	NOT_EQ = "!="
+	PERCENT = "%"
+	POWER = "**"
+	AMPERSAND = "&"
+	PIPE = "|"
+	CARET = "^"
+	SHIFT_LEFT = "<<"
+	SHIFT_RIGHT = ">>"
+	PIPELINE = "|>" // x |> f(y) calls f(x, y)
+
+	// compound assignments, x op= y
+	PLUS_ASSIGN = "+="
+	MINUS_ASSIGN = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN = "/="
+	PERCENT_ASSIGN = "%="
+	POWER_ASSIGN = "**="
+	AMPERSAND_ASSIGN = "&="
+	PIPE_ASSIGN = "|="
+	CARET_ASSIGN = "^="
+	SHIFT_LEFT_ASSIGN = "<<="
+	SHIFT_RIGHT_ASSIGN = ">>="
 
 	// DELIMITERS
 	COMMA = ","
 	SEMICOLON = ";"
+	COLON = ":"
+	DOT = "."
+	ELLIPSIS = "..."
+	OPTIONAL_CHAIN = "?." // x?.y is null when x is null
+	ARROW = "=>"
 
 	LPAREN = "("
 	RPAREN = ")"
 	LBRACE = "{"
 	RBRACE = "}"
+	LBRACKET = "["
+	RBRACKET = "]"
 
 	// KEYWORDS
 	FUNCTION = "FUNCTION"
@@ -43,6 +100,14 @@ const (
 	IF = "IF"
 	ELSE = "ELSE"
 	RETURN = "RETURN"
+	WHILE = "WHILE"
+	DO = "DO"
+	MATCH = "MATCH"
+	FOR = "FOR"
+	IN = "IN"
+	BREAK = "BREAK"
+	CONTINUE = "CONTINUE"
+	UNDERSCORE = "_" // the wildcard pattern
 )
 
 var keywords = map[string]TokenType{
@@ -53,6 +118,14 @@ var keywords = map[string]TokenType{
 	"if": IF,
 	"else": ELSE,
 	"return": RETURN,
+	"while": WHILE,
+	"do": DO,
+	"match": MATCH,
+	"for": FOR,
+	"in": IN,
+	"break": BREAK,
+	"continue": CONTINUE,
+	"_": UNDERSCORE,
 }
 
 // if a word is ident or keyword
@@ -0,0 +1,41 @@
+package token
+
+import "fmt"
+
+// types lists every token type. A new type has to be added here as well, so
+// Validate can check it.
+var types = []TokenType{
+	ILLEGAL, EOF,
+	IDENT, INT, FLOAT, STRING, TEMPLATE, RAW_STRING, REGEX,
+	WHITESPACE, COMMENT, NEWLINE,
+	ASSIGN, PLUS, MINUS, BANG, ASTERISK, SLASH, LT, GT, EQ, NOT_EQ,
+	PERCENT, POWER, AMPERSAND, PIPE, CARET, SHIFT_LEFT, SHIFT_RIGHT, PIPELINE,
+	PLUS_ASSIGN, MINUS_ASSIGN, ASTERISK_ASSIGN, SLASH_ASSIGN, PERCENT_ASSIGN, POWER_ASSIGN,
+	AMPERSAND_ASSIGN, PIPE_ASSIGN, CARET_ASSIGN, SHIFT_LEFT_ASSIGN, SHIFT_RIGHT_ASSIGN,
+	COMMA, SEMICOLON, COLON, DOT, ELLIPSIS, OPTIONAL_CHAIN, ARROW,
+	LPAREN, RPAREN, LBRACE, RBRACE, LBRACKET, RBRACKET,
+	FUNCTION, LET, TRUE, FALSE, IF, ELSE, RETURN, WHILE, DO, MATCH, FOR, IN, BREAK, CONTINUE, UNDERSCORE,
+}
+
+// Validate checks that no two token types share a value, which would make
+// the lexer and parser confuse them, and that every keyword maps to a
+// defined token type.
+func Validate() error {
+	return validate(types, keywords)
+}
+
+func validate(types []TokenType, keywords map[string]TokenType) error {
+	defined := map[TokenType]bool{}
+	for _, t := range types {
+		if defined[t] {
+			return fmt.Errorf("duplicate token type %q", t)
+		}
+		defined[t] = true
+	}
+	for word, t := range keywords {
+		if !defined[t] {
+			return fmt.Errorf("keyword %q maps to undefined token type %q", word, t)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package token
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	if err := Validate(); err != nil {
+		t.Fatalf("Validate returned error: %s", err)
+	}
+}
+
+func TestValidateDetectsMistakes(t *testing.T) {
+	tests := []struct {
+		types    []TokenType
+		keywords map[string]TokenType
+		expected string
+	}{
+		{
+			// like POWER = "*" by mistake
+			[]TokenType{ASTERISK, "*"},
+			map[string]TokenType{},
+			`duplicate token type "*"`,
+		},
+		{
+			[]TokenType{LET},
+			map[string]TokenType{"let": LET, "loop": "LOOP"},
+			`keyword "loop" maps to undefined token type "LOOP"`,
+		},
+	}
+
+	for i, tt := range tests {
+		err := validate(tt.types, tt.keywords)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("tests[%d] - wrong error. expected=%q, got=%v", i, tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,506 @@
+// Package transpile turns Monkey programs into Go source code.
+package transpile
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"monkey/ast"
+	"strings"
+)
+
+// ToGo translates p into a Go program whose main function does what p does.
+// Only a subset of Monkey is supported: integers and booleans, let bindings,
+// functions with integer parameters, calls, arithmetic and comparisons, if and
+// return. Everything else, and programs Go's types can't express (like an if
+// without else used as a value), make ToGo fail with an error naming the
+// position of the offending node.
+func ToGo(p *ast.Program) (string, error) {
+	t := &transpiler{scope: newScope(nil)}
+
+	t.line("package main")
+	t.line("")
+	t.line("func main() {")
+	for _, stmt := range p.Statements {
+		if err := t.statement(stmt); err != nil {
+			return "", err
+		}
+	}
+	t.line("}")
+
+	src, err := format.Source(t.out.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("transpile: generated invalid Go: %s", err)
+	}
+	return string(src), nil
+}
+
+// goType is the Go type of a Monkey value: int64, bool or a function taking
+// int64 parameters.
+type goType struct {
+	name   string
+	params int     // functions only
+	result *goType // functions only
+}
+
+var (
+	intType  = &goType{name: "int64"}
+	boolType = &goType{name: "bool"}
+)
+
+func funcType(params int, result *goType) *goType {
+	return &goType{name: "func", params: params, result: result}
+}
+
+func (t *goType) String() string {
+	if t.name != "func" {
+		return t.name
+	}
+	params := make([]string, t.params)
+	for i := range params {
+		params[i] = "int64"
+	}
+	return "func(" + strings.Join(params, ", ") + ") " + t.result.String()
+}
+
+func (t *goType) equals(other *goType) bool {
+	return t.String() == other.String()
+}
+
+// scope holds the types of the names bound in a block.
+type scope struct {
+	names map[string]*goType
+	outer *scope
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{names: map[string]*goType{}, outer: outer}
+}
+
+func (s *scope) lookup(name string) (*goType, bool) {
+	for ; s != nil; s = s.outer {
+		if typ, ok := s.names[name]; ok {
+			return typ, true
+		}
+	}
+	return nil, false
+}
+
+type transpiler struct {
+	out   bytes.Buffer
+	scope *scope
+}
+
+func (t *transpiler) line(format string, a ...interface{}) {
+	fmt.Fprintf(&t.out, format+"\n", a...)
+}
+
+// unsupported returns the error for a node ToGo can't translate.
+func unsupported(node ast.Node, format string, a ...interface{}) error {
+	pos := node.Pos()
+	return fmt.Errorf("transpile: %d:%d: %s", pos.Line, pos.Column, fmt.Sprintf(format, a...))
+}
+
+// statement writes a statement whose value, if any, isn't used.
+func (t *transpiler) statement(stmt ast.Statement) error {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return t.let(stmt)
+
+	case *ast.ExpressionStatement:
+		if ifExp, ok := stmt.Expression.(*ast.IfExpression); ok {
+			return t.ifStatement(ifExp, false)
+		}
+		code, _, err := t.expression(stmt.Expression)
+		if err != nil {
+			return err
+		}
+		t.line("_ = %s", code)
+		return nil
+
+	case *ast.ReturnStatement:
+		return unsupported(stmt, "return outside of a function is not supported")
+
+	default:
+		return unsupported(stmt, "%s is not supported", nodeName(stmt))
+	}
+}
+
+// let writes a binding. Functions are declared before they are assigned, so
+// they can call themselves. Binding a name again in the same scope becomes
+// an assignment, since Go doesn't allow declaring it twice.
+func (t *transpiler) let(stmt *ast.LetStatement) error {
+	if stmt.Pattern != nil {
+		return unsupported(stmt.Pattern, "destructuring is not supported")
+	}
+	name := stmt.Name.Value
+	if token.IsKeyword(name) {
+		return unsupported(stmt.Name, "%s is a keyword in Go", name)
+	}
+
+	if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		code, typ, err := t.function(fn, name)
+		if err != nil {
+			return err
+		}
+		if err := t.declare(stmt, name, typ); err != nil {
+			return err
+		}
+		t.line("%s = %s", name, code)
+		t.line("_ = %s", name)
+		return nil
+	}
+
+	code, typ, err := t.expression(stmt.Value)
+	if err != nil {
+		return err
+	}
+	if old, ok := t.scope.names[name]; ok {
+		if !old.equals(typ) {
+			return unsupported(stmt, "%s is bound again with a different type, %s instead of %s", name, typ, old)
+		}
+		t.line("%s = %s", name, code)
+		return nil
+	}
+	t.scope.names[name] = typ
+	t.line("%s := %s", name, code)
+	t.line("_ = %s", name)
+	return nil
+}
+
+// declare writes `var name type` unless name is already bound to a value of
+// that type in the current scope.
+func (t *transpiler) declare(node ast.Node, name string, typ *goType) error {
+	if old, ok := t.scope.names[name]; ok {
+		if !old.equals(typ) {
+			return unsupported(node, "%s is bound again with a different type, %s instead of %s", name, typ, old)
+		}
+		return nil
+	}
+	t.scope.names[name] = typ
+	t.line("var %s %s", name, typ)
+	return nil
+}
+
+// function returns the Go func literal for fn. When fn is bound to name, the
+// body can refer to it. The result type is inferred from the body, assuming
+// int64 first for recursive calls and trying again if that was wrong.
+func (t *transpiler) function(fn *ast.FunctionLiteral, name string) (string, *goType, error) {
+	for _, param := range fn.Parameters {
+		if token.IsKeyword(param.Value) {
+			return "", nil, unsupported(param, "%s is a keyword in Go", param.Value)
+		}
+	}
+	for _, def := range fn.Defaults {
+		if def != nil {
+			return "", nil, unsupported(def, "default parameter values are not supported")
+		}
+	}
+
+	assumed := intType
+	for {
+		code, result, err := t.functionWithResult(fn, name, assumed)
+		if err != nil {
+			return "", nil, err
+		}
+		if result.equals(assumed) || name == "" {
+			return code, funcType(len(fn.Parameters), result), nil
+		}
+		assumed = result
+	}
+}
+
+func (t *transpiler) functionWithResult(fn *ast.FunctionLiteral, name string, assumed *goType) (string, *goType, error) {
+	outer, outerOut := t.scope, t.out
+	defer func() { t.scope, t.out = outer, outerOut }()
+
+	t.scope = newScope(outer)
+	t.out = bytes.Buffer{}
+	if name != "" {
+		t.scope.names[name] = funcType(len(fn.Parameters), assumed)
+	}
+
+	params := []string{}
+	body := newScope(t.scope)
+	for _, param := range fn.Parameters {
+		params = append(params, param.Value+" int64")
+		body.names[param.Value] = intType
+	}
+	t.scope = body
+
+	result, err := t.tail(fn.Body, fn)
+	if err != nil {
+		return "", nil, err
+	}
+	code := "func(" + strings.Join(params, ", ") + ") " + result.String() + " {\n" + t.out.String() + "}"
+	return code, result, nil
+}
+
+// tail writes the statements of a function body or of a branch whose value is
+// the value of the function, so the last expression becomes a return. It
+// returns the type of that value.
+func (t *transpiler) tail(block *ast.BlockStatement, owner ast.Node) (*goType, error) {
+	stmts := block.Statements
+	if len(stmts) == 0 {
+		return nil, unsupported(owner, "an empty block has no value")
+	}
+	for _, stmt := range stmts[:len(stmts)-1] {
+		if err := t.bodyStatement(stmt); err != nil {
+			return nil, err
+		}
+	}
+
+	switch last := stmts[len(stmts)-1].(type) {
+	case *ast.ReturnStatement:
+		return t.returnStatement(last)
+	case *ast.ExpressionStatement:
+		if ifExp, ok := last.Expression.(*ast.IfExpression); ok {
+			return t.tailIf(ifExp)
+		}
+		code, typ, err := t.expression(last.Expression)
+		if err != nil {
+			return nil, err
+		}
+		t.line("return %s", code)
+		return typ, nil
+	default:
+		return nil, unsupported(last, "a block ending in a %s has no value", nodeName(last))
+	}
+}
+
+// bodyStatement writes a statement inside a function, where returns are allowed.
+func (t *transpiler) bodyStatement(stmt ast.Statement) error {
+	switch stmt := stmt.(type) {
+	case *ast.ReturnStatement:
+		_, err := t.returnStatement(stmt)
+		return err
+	case *ast.ExpressionStatement:
+		if ifExp, ok := stmt.Expression.(*ast.IfExpression); ok {
+			return t.ifStatement(ifExp, true)
+		}
+	}
+	return t.statement(stmt)
+}
+
+func (t *transpiler) returnStatement(stmt *ast.ReturnStatement) (*goType, error) {
+	code, typ, err := t.expression(stmt.ReturnValue)
+	if err != nil {
+		return nil, err
+	}
+	t.line("return %s", code)
+	return typ, nil
+}
+
+// ifStatement writes an if whose value isn't used.
+func (t *transpiler) ifStatement(ie *ast.IfExpression, inFunction bool) error {
+	cond, err := t.condition(ie)
+	if err != nil {
+		return err
+	}
+
+	branch := func(block *ast.BlockStatement) error {
+		outer := t.scope
+		t.scope = newScope(outer)
+		defer func() { t.scope = outer }()
+		for _, stmt := range block.Statements {
+			var err error
+			if inFunction {
+				err = t.bodyStatement(stmt)
+			} else {
+				err = t.statement(stmt)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	t.line("if %s {", cond)
+	if err := branch(ie.Consequence); err != nil {
+		return err
+	}
+	if ie.Alternative != nil {
+		t.line("} else {")
+		if err := branch(ie.Alternative); err != nil {
+			return err
+		}
+	}
+	t.line("}")
+	return nil
+}
+
+// tailIf writes an if whose branches produce the value of the function.
+func (t *transpiler) tailIf(ie *ast.IfExpression) (*goType, error) {
+	if ie.Alternative == nil {
+		return nil, unsupported(ie, "an if without else has no value when its condition is false")
+	}
+	cond, err := t.condition(ie)
+	if err != nil {
+		return nil, err
+	}
+
+	branch := func(block *ast.BlockStatement) (*goType, error) {
+		outer := t.scope
+		t.scope = newScope(outer)
+		defer func() { t.scope = outer }()
+		return t.tail(block, ie)
+	}
+
+	t.line("if %s {", cond)
+	consequence, err := branch(ie.Consequence)
+	if err != nil {
+		return nil, err
+	}
+	t.line("} else {")
+	alternative, err := branch(ie.Alternative)
+	if err != nil {
+		return nil, err
+	}
+	t.line("}")
+
+	if !consequence.equals(alternative) {
+		return nil, unsupported(ie, "the branches of the if have different types, %s and %s", consequence, alternative)
+	}
+	return consequence, nil
+}
+
+func (t *transpiler) condition(ie *ast.IfExpression) (string, error) {
+	cond, typ, err := t.expression(ie.Condition)
+	if err != nil {
+		return "", err
+	}
+	if !typ.equals(boolType) {
+		return "", unsupported(ie.Condition, "the condition must be a boolean, got %s", typ)
+	}
+	return cond, nil
+}
+
+// expression returns the Go code for exp and its type.
+func (t *transpiler) expression(exp ast.Expression) (string, *goType, error) {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return fmt.Sprintf("int64(%d)", exp.Value), intType, nil
+
+	case *ast.Boolean:
+		return fmt.Sprintf("%t", exp.Value), boolType, nil
+
+	case *ast.Identifier:
+		typ, ok := t.scope.lookup(exp.Value)
+		if !ok {
+			return "", nil, unsupported(exp, "unknown name %s", exp.Value)
+		}
+		return exp.Value, typ, nil
+
+	case *ast.PrefixExpression:
+		return t.prefix(exp)
+
+	case *ast.InfixExpression:
+		return t.infix(exp)
+
+	case *ast.FunctionLiteral:
+		return t.function(exp, "")
+
+	case *ast.CallExpression:
+		return t.call(exp)
+
+	case *ast.IfExpression:
+		// an if used as a value becomes a function literal that is called
+		// right away: func() int64 { if c { return 1 } else { return 2 } }()
+		outer, outerOut := t.scope, t.out
+		t.scope, t.out = newScope(outer), bytes.Buffer{}
+		typ, err := t.tailIf(exp)
+		body := t.out.String()
+		t.scope, t.out = outer, outerOut
+		if err != nil {
+			return "", nil, err
+		}
+		return "func() " + typ.String() + " {\n" + body + "}()", typ, nil
+
+	default:
+		return "", nil, unsupported(exp, "%s is not supported", nodeName(exp))
+	}
+}
+
+func (t *transpiler) prefix(exp *ast.PrefixExpression) (string, *goType, error) {
+	right, typ, err := t.expression(exp.Right)
+	if err != nil {
+		return "", nil, err
+	}
+	want := intType
+	if exp.Operator == "!" {
+		want = boolType
+	}
+	if !typ.equals(want) {
+		return "", nil, unsupported(exp, "%s needs %s, got %s", exp.Operator, want, typ)
+	}
+	return "(" + exp.Operator + right + ")", typ, nil
+}
+
+func (t *transpiler) infix(exp *ast.InfixExpression) (string, *goType, error) {
+	left, leftType, err := t.expression(exp.Left)
+	if err != nil {
+		return "", nil, err
+	}
+	right, rightType, err := t.expression(exp.Right)
+	if err != nil {
+		return "", nil, err
+	}
+	if !leftType.equals(rightType) {
+		return "", nil, unsupported(exp, "type mismatch: %s %s %s", leftType, exp.Operator, rightType)
+	}
+
+	code := "(" + left + " " + exp.Operator + " " + right + ")"
+	switch exp.Operator {
+	case "+", "-", "*", "/", "%", "<", ">":
+		if !leftType.equals(intType) {
+			return "", nil, unsupported(exp, "%s needs int64 operands, got %s", exp.Operator, leftType)
+		}
+		if exp.Operator == "<" || exp.Operator == ">" {
+			return code, boolType, nil
+		}
+		return code, intType, nil
+	case "==", "!=":
+		if leftType.name == "func" {
+			return "", nil, unsupported(exp, "functions can't be compared")
+		}
+		return code, boolType, nil
+	default:
+		return "", nil, unsupported(exp, "operator %s is not supported", exp.Operator)
+	}
+}
+
+func (t *transpiler) call(exp *ast.CallExpression) (string, *goType, error) {
+	fn, fnType, err := t.expression(exp.Function)
+	if err != nil {
+		return "", nil, err
+	}
+	if fnType.name != "func" {
+		return "", nil, unsupported(exp, "%s is not a function", exp.Function)
+	}
+	if len(exp.Arguments) != fnType.params {
+		return "", nil, unsupported(exp, "wrong number of arguments: want=%d, got=%d", fnType.params, len(exp.Arguments))
+	}
+
+	args := []string{}
+	for _, arg := range exp.Arguments {
+		if _, ok := arg.(*ast.NamedArgument); ok {
+			return "", nil, unsupported(arg, "named arguments are not supported")
+		}
+		code, typ, err := t.expression(arg)
+		if err != nil {
+			return "", nil, err
+		}
+		if !typ.equals(intType) {
+			return "", nil, unsupported(arg, "arguments must be int64, got %s", typ)
+		}
+		args = append(args, code)
+	}
+	return fn + "(" + strings.Join(args, ", ") + ")", fnType.result, nil
+}
+
+// nodeName returns the type name of node for error messages, e.g. StringLiteral.
+func nodeName(node ast.Node) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+}
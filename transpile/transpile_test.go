@@ -0,0 +1,114 @@
+package transpile
+
+import (
+	goparser "go/parser"
+	gotoken "go/token"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"strings"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program
+}
+
+// checkGo fails the test if src isn't valid Go syntax.
+func checkGo(t *testing.T, src string) {
+	t.Helper()
+	if _, err := goparser.ParseFile(gotoken.NewFileSet(), "main.go", src, 0); err != nil {
+		t.Fatalf("output is not valid Go: %s\n%s", err, src)
+	}
+}
+
+func TestToGo(t *testing.T) {
+	tests := []struct {
+		input    string
+		contains []string
+	}{
+		{
+			"let add = fn(a, b) { a + b }; let x = add(1, 2);",
+			[]string{
+				"var add func(int64, int64) int64",
+				"add = func(a int64, b int64) int64 {",
+				"return (a + b)",
+				"x := add(int64(1), int64(2))",
+			},
+		},
+		{
+			"let max = fn(a, b) { if (a > b) { return a; } return b; }; max(3, 4);",
+			[]string{
+				"if a > b {",
+				"return a",
+				"return b",
+				"_ = max(int64(3), int64(4))",
+			},
+		},
+		{
+			"let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(5);",
+			[]string{
+				"var fact func(int64) int64",
+				"return (n * fact((n - int64(1))))",
+			},
+		},
+		{
+			"let positive = fn(n) { n > 0 }; let x = 1; let x = 2; let y = if (positive(x)) { 10 } else { 20 };",
+			[]string{
+				"var positive func(int64) bool",
+				"x = int64(2)",
+				"y := func() int64 {",
+			},
+		},
+		{
+			"let x = 1; if (x == 1) { let y = x; }",
+			[]string{"if x == int64(1) {", "y := x"},
+		},
+	}
+
+	for i, tt := range tests {
+		src, err := ToGo(parse(t, tt.input))
+		if err != nil {
+			t.Fatalf("tests[%d] - ToGo returned error: %s", i, err)
+		}
+		checkGo(t, src)
+		for _, want := range tt.contains {
+			if !strings.Contains(src, want) {
+				t.Errorf("tests[%d] - output doesn't contain %q:\n%s", i, want, src)
+			}
+		}
+	}
+}
+
+func TestToGoUnsupported(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let s = "hi";`, "transpile: 1:9: StringLiteral is not supported"},
+		{"let a = [1, 2];", "transpile: 1:9: ArrayLiteral is not supported"},
+		{"return 1;", "transpile: 1:1: return outside of a function is not supported"},
+		{"len(1);", "transpile: 1:1: unknown name len"},
+		{"let f = fn(x) { if (x > 1) { x } };", "transpile: 1:17: an if without else has no value when its condition is false"},
+		{"let x = 1 + true;", "transpile: 1:11: type mismatch: int64 + bool"},
+		{"if (1) { 2 }", "transpile: 1:5: the condition must be a boolean, got int64"},
+		{"let func = 1;", "transpile: 1:5: func is a keyword in Go"},
+		{"let f = fn(x) { x }; f(1, 2);", "transpile: 1:23: wrong number of arguments: want=1, got=2"},
+	}
+
+	for i, tt := range tests {
+		_, err := ToGo(parse(t, tt.input))
+		if err == nil {
+			t.Fatalf("tests[%d] - expected error for %q", i, tt.input)
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("tests[%d] - wrong error. expected=%q, got=%q", i, tt.expected, err.Error())
+		}
+	}
+}
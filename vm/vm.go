@@ -0,0 +1,229 @@
+// Package vm runs the bytecode produced by the compiler on a stack machine.
+package vm
+
+import (
+	"fmt"
+	"monkey/code"
+	"monkey/compiler"
+	"monkey/object"
+)
+
+// StackSize is the maximum number of values on the stack.
+const StackSize = 2048
+
+// GlobalsSize is the maximum number of global bindings.
+const GlobalsSize = 65536
+
+var (
+	True  = &object.Boolean{Value: true}
+	False = &object.Boolean{Value: false}
+	Null  = &object.Null{}
+)
+
+// VM executes one Bytecode.
+type VM struct {
+	constants    []object.Object
+	instructions code.Instructions
+
+	stack []object.Object
+	sp    int // the next free slot, the top of the stack is stack[sp-1]
+
+	globals []object.Object
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	return &VM{
+		constants:    bytecode.Constants,
+		instructions: bytecode.Instructions,
+		stack:        make([]object.Object, StackSize),
+		globals:      make([]object.Object, GlobalsSize),
+	}
+}
+
+// StackTop returns the value on top of the stack, or nil if it's empty.
+func (vm *VM) StackTop() object.Object {
+	if vm.sp == 0 {
+		return nil
+	}
+	return vm.stack[vm.sp-1]
+}
+
+// LastPoppedStackElem returns the value most recently popped off the stack.
+// Every expression statement pops its value, so after Run this is the value
+// of the last one.
+func (vm *VM) LastPoppedStackElem() object.Object {
+	return vm.stack[vm.sp]
+}
+
+// Run executes the instructions, stopping at the first error.
+func (vm *VM) Run() error {
+	for ip := 0; ip < len(vm.instructions); ip++ {
+		op := code.Opcode(vm.instructions[ip])
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(vm.instructions[ip+1:])
+			ip += 2
+			if err := vm.push(vm.constants[constIndex]); err != nil {
+				return err
+			}
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv:
+			if err := vm.executeBinaryOperation(op); err != nil {
+				return err
+			}
+
+		case code.OpEqual, code.OpNotEqual, code.OpGreaterThan:
+			if err := vm.executeComparison(op); err != nil {
+				return err
+			}
+
+		case code.OpTrue:
+			if err := vm.push(True); err != nil {
+				return err
+			}
+
+		case code.OpFalse:
+			if err := vm.push(False); err != nil {
+				return err
+			}
+
+		case code.OpBang:
+			if err := vm.push(nativeBoolToBooleanObject(!isTruthy(vm.pop()))); err != nil {
+				return err
+			}
+
+		case code.OpMinus:
+			operand := vm.pop()
+			integer, ok := operand.(*object.Integer)
+			if !ok {
+				return fmt.Errorf("unsupported type for negation: %s", operand.Type())
+			}
+			if err := vm.push(object.NewInteger(-integer.Value)); err != nil {
+				return err
+			}
+
+		case code.OpPop:
+			vm.pop()
+
+		case code.OpJump:
+			pos := int(code.ReadUint16(vm.instructions[ip+1:]))
+			ip = pos - 1 // the loop increments ip
+
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(vm.instructions[ip+1:]))
+			ip += 2
+			if !isTruthy(vm.pop()) {
+				ip = pos - 1
+			}
+
+		case code.OpNull:
+			if err := vm.push(Null); err != nil {
+				return err
+			}
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(vm.instructions[ip+1:])
+			ip += 2
+			vm.globals[globalIndex] = vm.pop()
+
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(vm.instructions[ip+1:])
+			ip += 2
+			if err := vm.push(vm.globals[globalIndex]); err != nil {
+				return err
+			}
+
+		default:
+			return fmt.Errorf("opcode %d undefined", op)
+		}
+	}
+	return nil
+}
+
+func (vm *VM) executeBinaryOperation(op code.Opcode) error {
+	right := vm.pop()
+	left := vm.pop()
+
+	leftValue, ok := left.(*object.Integer)
+	rightValue, ok2 := right.(*object.Integer)
+	if !ok || !ok2 {
+		return fmt.Errorf("unsupported types for binary operation: %s %s", left.Type(), right.Type())
+	}
+
+	var result int64
+	switch op {
+	case code.OpAdd:
+		result = leftValue.Value + rightValue.Value
+	case code.OpSub:
+		result = leftValue.Value - rightValue.Value
+	case code.OpMul:
+		result = leftValue.Value * rightValue.Value
+	case code.OpDiv:
+		if rightValue.Value == 0 {
+			return fmt.Errorf("division by zero")
+		}
+		result = leftValue.Value / rightValue.Value
+	}
+	return vm.push(object.NewInteger(result))
+}
+
+func (vm *VM) executeComparison(op code.Opcode) error {
+	right := vm.pop()
+	left := vm.pop()
+
+	if left, ok := left.(*object.Integer); ok {
+		if right, ok := right.(*object.Integer); ok {
+			switch op {
+			case code.OpEqual:
+				return vm.push(nativeBoolToBooleanObject(left.Value == right.Value))
+			case code.OpNotEqual:
+				return vm.push(nativeBoolToBooleanObject(left.Value != right.Value))
+			case code.OpGreaterThan:
+				return vm.push(nativeBoolToBooleanObject(left.Value > right.Value))
+			}
+		}
+	}
+
+	// booleans are singletons, so comparing pointers compares values
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(left == right))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(left != right))
+	default:
+		return fmt.Errorf("unknown operator: %d (%s %s)", op, left.Type(), right.Type())
+	}
+}
+
+func (vm *VM) push(o object.Object) error {
+	if vm.sp >= StackSize {
+		return fmt.Errorf("stack overflow")
+	}
+	vm.stack[vm.sp] = o
+	vm.sp++
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return True
+	}
+	return False
+}
+
+// isTruthy follows the evaluator: everything but false and null is truthy.
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case False, Null:
+		return false
+	default:
+		return true
+	}
+}
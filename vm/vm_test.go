@@ -0,0 +1,153 @@
+package vm
+
+import (
+	"monkey/compiler"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"testing"
+)
+
+type vmTestCase struct {
+	input    string
+	expected interface{}
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"1", 1},
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"50 / 2 * 2 + 10 - 5", 55},
+		{"-5 + 10", 5},
+		{"1; 2", 2},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBooleanExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"true", true},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"true == false", false},
+		{"(1 < 2) == true", true},
+		{"!true", false},
+		{"!!5", true},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []vmTestCase{
+		{"let x = 5; if (x > 3) { 10 } else { 20 }", 10},
+		{"let x = 2; if (x > 3) { 10 } else { 20 }", 20},
+		{"if (true) { 10 }", 10},
+		{"if (1 > 2) { 10 }", nil},
+		{"if (false) { 10 } else { let y = 1; }", nil},
+		{"if ((if (false) { 10 })) { 10 } else { 20 }", 20},
+		{"let x = if (1 < 2) { 3 }; x * 2", 6},
+		{"!(if (false) { 5 })", true},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestGlobalLetStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"let one = 1; one", 1},
+		{"let one = 1; let two = one + one; one + two", 3},
+		{"let x = 1; let x = x + 1; x", 2},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 / 0", "division by zero"},
+		{"-true", "unsupported type for negation: BOOLEAN"},
+		{"1 + true", "unsupported types for binary operation: INTEGER BOOLEAN"},
+	}
+
+	for i, tt := range tests {
+		bytecode, err := compiler.Compile(parser.New(lexer.New(tt.input)).ParseProgram())
+		if err != nil {
+			t.Fatalf("tests[%d] - compiler error: %s", i, err)
+		}
+		err = New(bytecode).Run()
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("tests[%d] - wrong error. want=%q, got=%v", i, tt.expected, err)
+		}
+	}
+}
+
+func TestSmallIntsAreInterned(t *testing.T) {
+	for _, input := range []string{"5", "2 + 3", "-(0 - 5)"} {
+		bytecode, err := compiler.Compile(parser.New(lexer.New(input)).ParseProgram())
+		if err != nil {
+			t.Fatalf("%s: compiler error: %s", input, err)
+		}
+		vm := New(bytecode)
+		if err := vm.Run(); err != nil {
+			t.Fatalf("%s: vm error: %s", input, err)
+		}
+		if vm.LastPoppedStackElem() != object.NewInteger(5) {
+			t.Errorf("%s: result isn't the interned 5", input)
+		}
+	}
+}
+
+func runVmTests(t *testing.T, tests []vmTestCase) {
+	t.Helper()
+
+	for i, tt := range tests {
+		bytecode, err := compiler.Compile(parser.New(lexer.New(tt.input)).ParseProgram())
+		if err != nil {
+			t.Fatalf("tests[%d] - compiler error: %s", i, err)
+		}
+
+		vm := New(bytecode)
+		if err := vm.Run(); err != nil {
+			t.Fatalf("tests[%d] - vm error: %s", i, err)
+		}
+
+		testExpectedObject(t, i, tt.expected, vm.LastPoppedStackElem())
+	}
+}
+
+func testExpectedObject(t *testing.T, i int, expected interface{}, actual object.Object) {
+	t.Helper()
+
+	switch expected := expected.(type) {
+	case nil:
+		if actual != Null {
+			t.Errorf("tests[%d] - object is not Null. got=%T (%+v)", i, actual, actual)
+		}
+	case int:
+		result, ok := actual.(*object.Integer)
+		if !ok {
+			t.Errorf("tests[%d] - object is not Integer. got=%T (%+v)", i, actual, actual)
+			return
+		}
+		if result.Value != int64(expected) {
+			t.Errorf("tests[%d] - object has wrong value. got=%d, want=%d", i, result.Value, expected)
+		}
+	case bool:
+		result, ok := actual.(*object.Boolean)
+		if !ok {
+			t.Errorf("tests[%d] - object is not Boolean. got=%T (%+v)", i, actual, actual)
+			return
+		}
+		if result.Value != expected {
+			t.Errorf("tests[%d] - object has wrong value. got=%t, want=%t", i, result.Value, expected)
+		}
+	}
+}